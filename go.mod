@@ -15,8 +15,10 @@ require (
 	github.com/google/go-containerregistry v0.1.4
 	github.com/google/go-github/v27 v27.0.6
 	github.com/google/licenseclassifier v0.0.0-20200708223521-3d09a0ea2f39
+	github.com/google/uuid v1.1.1
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/mattn/go-sqlite3 v2.0.1+incompatible
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.0.0
 	golang.org/x/mod v0.3.0
@@ -28,11 +30,14 @@ require (
 	google.golang.org/grpc v1.31.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0
 	k8s.io/apimachinery v0.18.8
+	k8s.io/client-go v11.0.1-0.20190805182717-6502b5e7b1b5+incompatible
 	k8s.io/test-infra v0.0.0-20200803112140-d8aa4e063646 // indirect
 	sigs.k8s.io/boskos v0.0.0-20200729174948-794df80db9c9
 )
 
 replace (
+	// v2.0.x was tagged by mistake, gorm requiring it is pinned to the maintained v1.14.x
+	github.com/mattn/go-sqlite3 => github.com/mattn/go-sqlite3 v1.14.6
 	k8s.io/apimachinery => k8s.io/apimachinery v0.18.8
 	k8s.io/client-go => k8s.io/client-go v0.18.8
 	k8s.io/code-generator => k8s.io/code-generator v0.18.8
@@ -22,18 +22,30 @@ import (
 	"cloud.google.com/go/storage"
 )
 
+// Storage defines the interface for reading and writing objects, implemented
+// by both GCS and the local filesystem.
+type Storage interface {
+	// Exists check if an object exists under a bucket, assuming bucket exists
+	Exists(ctx context.Context, bkt, objPath string) bool
+	// ListDirectChildren lists direct children paths (incl. files and dir)
+	ListDirectChildren(ctx context.Context, bkt, dirPath string) ([]string, error)
+	// ReadObject reads a GCS object and returns then contents in []byte
+	ReadObject(ctx context.Context, bkt, objPath string) ([]byte, error)
+	// WriteObject writes []byte content to a GCS object
+	WriteObject(ctx context.Context, bkt, objPath string, content []byte) (int, error)
+	// Upload uploads a local file to a GCS object, assuming bucket exists
+	Upload(ctx context.Context, bktName, objPath, filePath string) error
+}
+
 // Client defines the interface for GCS operations.
 type Client interface {
+	Storage
 	// NewStorageBucket creates a new bucket in GCS with uniform access policy
 	NewStorageBucket(ctx context.Context, bkt, project string) error
 	// DeleteStorageBucket removes all children objects, force if not empty
 	DeleteStorageBucket(ctx context.Context, bkt string, force bool) error
-	// Exists check if an object exists under a bucket, assuming bucket exists
-	Exists(ctx context.Context, bkt, objPath string) bool
 	// ListChildrenFiles recursively lists all children files
 	ListChildrenFiles(ctx context.Context, bkt, dirPath string) ([]string, error)
-	// ListDirectChildren lists direct children paths (incl. files and dir)
-	ListDirectChildren(ctx context.Context, bkt, dirPath string) ([]string, error)
 	// AttrObject returns the object attributes
 	AttrObject(ctx context.Context, bkt, objPath string) (*storage.ObjectAttrs, error)
 	// CopyObject copies objects from one location to another, assuming both src and dst
@@ -41,14 +53,8 @@ type Client interface {
 	CopyObject(ctx context.Context, srcBkt, srcObjPath, dstBkt, dstObjPath string) error
 	// NewReader creates a new Reader of a gcs file.
 	NewReader(ctx context.Context, bucketName, objPath string) (*storage.Reader, error)
-	// ReadObject reads a GCS object and returns then contents in []byte
-	ReadObject(ctx context.Context, bkt, objPath string) ([]byte, error)
-	// WriteObject writes []byte content to a GCS object
-	WriteObject(ctx context.Context, bkt, objPath string, content []byte) (int, error)
 	// DeleteObject deletes an object
 	DeleteObject(ctx context.Context, bkt, objPath string) error
 	// Download downloads GCS object to a local file, assuming bucket exists
 	Download(ctx context.Context, bktName, objPath, filePath string) error
-	// Upload uploads a local file to a GCS object, assuming bucket exists
-	Upload(ctx context.Context, bktName, objPath, filePath string) error
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// localStorage stores objects in the local filesystem, each bucket being a
+// directory under the root directory
+type localStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a Storage in the local filesystem under the root
+// directory, for running without GCS, e.g. in tests
+func NewLocalStorage(root string) Storage {
+	return &localStorage{root: root}
+}
+
+// filePath returns the path of the object in the local filesystem
+func (l *localStorage) filePath(bucketName, objPath string) string {
+	return filepath.Join(l.root, bucketName, filepath.FromSlash(objPath))
+}
+
+// Exists check if an object or a directory exists under a bucket
+func (l *localStorage) Exists(ctx context.Context, bucketName, objPath string) bool {
+	_, err := os.Stat(l.filePath(bucketName, objPath))
+	return err == nil
+}
+
+// ListDirectChildren lists direct children paths (including files and directories).
+func (l *localStorage) ListDirectChildren(ctx context.Context, bucketName, dirPath string) ([]string, error) {
+	dirPath = strings.TrimRight(dirPath, " /")
+	infos, err := ioutil.ReadDir(l.filePath(bucketName, dirPath))
+	if os.IsNotExist(err) {
+		// gcs directories are virtual, so a missing directory has no children
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	children := make([]string, 0, len(infos))
+	for _, info := range infos {
+		children = append(children, path.Join(dirPath, info.Name()))
+	}
+	return children, nil
+}
+
+// ReadObject reads the content of an object, the error is
+// storage.ErrObjectNotExist if the object doesn't exist, same as in gcs
+func (l *localStorage) ReadObject(ctx context.Context, bucketName, objPath string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(l.filePath(bucketName, objPath))
+	if os.IsNotExist(err) {
+		return nil, storage.ErrObjectNotExist
+	}
+	return contents, err
+}
+
+// WriteObject writes the content to an object, creating its directory if needed
+func (l *localStorage) WriteObject(ctx context.Context, bucketName, objPath string,
+	content []byte) (int, error) {
+	filePath := l.filePath(bucketName, objPath)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(filePath, content, 0644); err != nil {
+		return 0, err
+	}
+	return len(content), nil
+}
+
+// Upload copies the local file to an object
+func (l *localStorage) Upload(ctx context.Context, bucketName, objPath, srcPath string) error {
+	content, err := ioutil.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+	_, err = l.WriteObject(ctx, bucketName, objPath, content)
+	return err
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root, err := ioutil.TempDir("", "local-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	ctx := context.Background()
+	s := NewLocalStorage(root)
+
+	if _, err := s.ReadObject(ctx, "bucket", "logs/job/1/file.txt"); err != storage.ErrObjectNotExist {
+		t.Errorf("ReadObject() of a missing object error = %v, want %v", err, storage.ErrObjectNotExist)
+	}
+	if n, err := s.WriteObject(ctx, "bucket", "logs/job/1/file.txt", []byte("content")); err != nil || n != 7 {
+		t.Fatalf("WriteObject() = %d, %v, want 7, nil", n, err)
+	}
+	src := path.Join(root, "src.txt")
+	ioutil.WriteFile(src, []byte("uploaded"), 0644)
+	if err := s.Upload(ctx, "bucket", "logs/job/2/file.txt", src); err != nil {
+		t.Fatalf("Upload() failed: %v", err)
+	}
+
+	if got, err := s.ReadObject(ctx, "bucket", "logs/job/2/file.txt"); err != nil || string(got) != "uploaded" {
+		t.Errorf("ReadObject() = %q, %v, want %q", got, err, "uploaded")
+	}
+	for _, tt := range []struct {
+		objPath string
+		want    bool
+	}{
+		{"logs/job/1/file.txt", true},
+		{"logs/job/1", true},
+		{"logs/job/3", false},
+	} {
+		if got := s.Exists(ctx, "bucket", tt.objPath); got != tt.want {
+			t.Errorf("Exists(%q) = %v, want %v", tt.objPath, got, tt.want)
+		}
+	}
+
+	children, err := s.ListDirectChildren(ctx, "bucket", "logs/job/")
+	if err != nil {
+		t.Fatalf("ListDirectChildren() failed: %v", err)
+	}
+	if want := []string{"logs/job/1", "logs/job/2"}; !reflect.DeepEqual(children, want) {
+		t.Errorf("ListDirectChildren() = %v, want %v", children, want)
+	}
+	if children, err := s.ListDirectChildren(ctx, "bucket", "logs/other"); err != nil || len(children) != 0 {
+		t.Errorf("ListDirectChildren() of a missing directory = %v, %v, want no children", children, err)
+	}
+}
@@ -308,6 +308,19 @@ func (b *Build) ReadFile(relPath string) ([]byte, error) {
 	return client.ReadObject(ctx, BucketName, path.Join(b.StoragePath, relPath))
 }
 
+// WriteFile writes given file of current build,
+// relPath is the file path relative to build directory
+func (b *Build) WriteFile(relPath string, contents []byte) error {
+	_, err := client.WriteObject(ctx, BucketName, path.Join(b.StoragePath, relPath), contents)
+	return err
+}
+
+// FileExists checks if given file of current build exists,
+// relPath is the file path relative to build directory
+func (b *Build) FileExists(relPath string) bool {
+	return client.Exists(ctx, BucketName, path.Join(b.StoragePath, relPath))
+}
+
 // ParseLog parses the build log and returns the lines where the checkLog func does not return an empty slice,
 // checkLog function should take in the log statement and return a part from that statement that should be in the log output.
 func (b *Build) ParseLog(checkLog func(s []string) *string) ([]string, error) {
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// blocks.go includes types for formatting messages with Slack blocks,
+// see https://api.slack.com/reference/block-kit/blocks
+
+package slackutil
+
+const (
+	plainTextType = "plain_text"
+	markdownType  = "mrkdwn"
+)
+
+// Message is a Slack message with optional blocks layout
+type Message struct {
+	// Text is required, it's used as the fallback of blocks in notifications
+	Text   string
+	Blocks []Block
+	// ThreadTS is the timestamp of the parent message, set it for replying in thread
+	ThreadTS string
+}
+
+// Block is a layout block of a Slack message
+type Block struct {
+	Type     string        `json:"type"`
+	Text     *TextObject   `json:"text,omitempty"`
+	Fields   []*TextObject `json:"fields,omitempty"`
+	Elements []*TextObject `json:"elements,omitempty"`
+}
+
+// TextObject is a text element used in blocks
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewHeaderBlock creates a header block with plain text
+func NewHeaderBlock(text string) Block {
+	return Block{Type: "header", Text: &TextObject{Type: plainTextType, Text: text}}
+}
+
+// NewSectionBlock creates a section block with markdown text
+func NewSectionBlock(markdown string) Block {
+	return Block{Type: "section", Text: &TextObject{Type: markdownType, Text: markdown}}
+}
+
+// NewFieldsBlock creates a section block with markdown fields, which are
+// displayed in two columns
+func NewFieldsBlock(fields ...string) Block {
+	b := Block{Type: "section"}
+	for _, f := range fields {
+		b.Fields = append(b.Fields, &TextObject{Type: markdownType, Text: f})
+	}
+	return b
+}
+
+// NewContextBlock creates a context block with markdown text, which is
+// displayed in small font
+func NewContextBlock(markdown string) Block {
+	return Block{Type: "context", Elements: []*TextObject{{Type: markdownType, Text: markdown}}}
+}
+
+// NewDividerBlock creates a divider block
+func NewDividerBlock() Block {
+	return Block{Type: "divider"}
+}
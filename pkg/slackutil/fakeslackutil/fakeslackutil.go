@@ -19,13 +19,18 @@ limitations under the License.
 package fakeslackutil
 
 import (
+	"fmt"
 	"sync"
 	"time"
+
+	"knative.dev/test-infra/pkg/slackutil"
 )
 
 type messageEntry struct {
 	text     string
 	sentTime time.Time
+	ts       string
+	message  slackutil.Message
 }
 
 // FakeSlackClient is a faked client, implements all functions of slackutil.ReadOperations and slackutil.WriteOperations
@@ -59,13 +64,32 @@ func (c *FakeSlackClient) MessageHistory(channel string, startTime time.Time) ([
 
 // Post sends the text as a message to the given channel
 func (c *FakeSlackClient) Post(text, channel string) error {
+	_, err := c.PostMessage(&slackutil.Message{Text: text}, channel)
+	return err
+}
+
+// PostMessage sends the message to the given channel, and returns a fake
+// timestamp unique in the channel
+func (c *FakeSlackClient) PostMessage(msg *slackutil.Message, channel string) (string, error) {
 	c.mutex.Lock()
 	messages := make([]messageEntry, 0)
 	if history, ok := c.History[channel]; ok {
 		messages = history
 	}
-	messages = append(messages, messageEntry{text: text, sentTime: time.Now()})
+	ts := fmt.Sprintf("%d.000000", len(messages)+1)
+	messages = append(messages, messageEntry{text: msg.Text, sentTime: time.Now(), ts: ts, message: *msg})
 	c.History[channel] = messages
 	c.mutex.Unlock()
-	return nil
+	return ts, nil
+}
+
+// Messages returns all messages sent to the given channel, in the order they were sent
+func (c *FakeSlackClient) Messages(channel string) []slackutil.Message {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var res []slackutil.Message
+	for _, entry := range c.History[channel] {
+		res = append(res, entry.message)
+	}
+	return res
 }
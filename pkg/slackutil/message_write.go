@@ -32,6 +32,9 @@ const postMessageURL = "https://slack.com/api/chat.postMessage"
 // WriteOperations defines the write operations that can be done to Slack
 type WriteOperations interface {
 	Post(text, channel string) error
+	// PostMessage posts the message to channel, and returns the timestamp of
+	// the posted message, which can be used for replying in thread
+	PostMessage(msg *Message, channel string) (string, error)
 }
 
 // writeClient contains Slack bot related information to perform write operations
@@ -54,24 +57,41 @@ func NewWriteClient(userName, tokenPath string) (WriteOperations, error) {
 
 // Post posts the given text to channel
 func (c *writeClient) Post(text, channel string) error {
+	_, err := c.PostMessage(&Message{Text: text}, channel)
+	return err
+}
+
+// PostMessage posts the given message to channel
+func (c *writeClient) PostMessage(msg *Message, channel string) (string, error) {
 	uv := url.Values{}
 	uv.Add("username", c.userName)
 	uv.Add("token", c.tokenStr)
 	uv.Add("channel", channel)
-	uv.Add("text", text)
+	uv.Add("text", msg.Text)
+	if len(msg.Blocks) > 0 {
+		blocks, err := json.Marshal(msg.Blocks)
+		if err != nil {
+			return "", err
+		}
+		uv.Add("blocks", string(blocks))
+	}
+	if msg.ThreadTS != "" {
+		uv.Add("thread_ts", msg.ThreadTS)
+	}
 
 	content, err := post(postMessageURL, uv)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// response code could also be 200 if channel doesn't exist, parse response body to find out
 	var b struct {
-		OK bool `json:"ok"`
+		OK bool   `json:"ok"`
+		TS string `json:"ts"`
 	}
 	if err = json.Unmarshal(content, &b); nil != err || !b.OK {
-		return fmt.Errorf("response not ok '%s'", string(content))
+		return "", fmt.Errorf("response not ok '%s'", string(content))
 	}
 
-	return nil
+	return b.TS, nil
 }
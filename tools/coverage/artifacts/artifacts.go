@@ -25,6 +25,8 @@ const (
 	CovProfileCompletionMarker = "profile-completed"
 	JunitXmlForTestgrid        = "junit_bazel.xml"
 	LineCovFileName            = "line-cov.html"
+	CoberturaXmlFileName       = "cobertura.xml"
+	LcovFileName               = "lcov.info"
 )
 
 type Intf interface {
@@ -80,3 +82,11 @@ func LineCovFilePath(directory string) string {
 func (arts *Artifacts) LineCovFilePath() string {
 	return LineCovFilePath(arts.directory)
 }
+
+func CoberturaXmlPath(directory string) string {
+	return path.Join(directory, CoberturaXmlFileName)
+}
+
+func LcovPath(directory string) string {
+	return path.Join(directory, LcovFileName)
+}
@@ -31,6 +31,8 @@ import (
 
 type codeBlock struct {
 	fileName      string // the file the code block is in
+	startLine     int    // the line the code block starts at
+	endLine       int    // the line the code block ends at
 	numStatements int    // number of statements in the code block
 	coverageCount int    // number of times the block is covered
 }
@@ -73,14 +75,23 @@ func updateConcernedFiles(concernedFiles map[string]bool, filePath string, isPre
 	return isConcerned
 }
 
-// convert a line in profile file to a codeBlock struct
+// convert a line in profile file to a codeBlock struct. A line is in the format of
+// "name.go:line.column,line.column numberOfStatements count"
 func toBlock(line string) (res *codeBlock) {
 	slice := strings.Split(line, " ")
 	blockName := slice[0]
 	nStmts, _ := strconv.Atoi(slice[1])
 	coverageCount, _ := strconv.Atoi(slice[2])
+	colon := strings.LastIndex(blockName, ":")
+	var startLine, endLine int
+	if positions := strings.Split(blockName[colon+1:], ","); len(positions) == 2 {
+		startLine, _ = strconv.Atoi(strings.Split(positions[0], ".")[0])
+		endLine, _ = strconv.Atoi(strings.Split(positions[1], ".")[0])
+	}
 	return &codeBlock{
-		fileName:      blockName[:strings.Index(blockName, ":")],
+		fileName:      blockName[:colon],
+		startLine:     startLine,
+		endLine:       endLine,
 		numStatements: nStmts,
 		coverageCount: coverageCount,
 	}
@@ -142,6 +153,16 @@ func (c *Coverage) String() string {
 	return "ratio not exist"
 }
 
+// NumCoveredStmts returns the number of covered statements
+func (c *Coverage) NumCoveredStmts() int {
+	return c.nCoveredStmts
+}
+
+// NumAllStmts returns the number of all statements
+func (c *Coverage) NumAllStmts() int {
+	return c.nAllStmts
+}
+
 func (c *Coverage) LineCovLink() string {
 	return c.lineCovLink
 }
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"sort"
 	"strings"
 
@@ -123,13 +124,16 @@ func NewGroupChanges(baseList *CoverageList, newList *CoverageList) *GroupChange
 // include in the github commit. If yes, then include that in the covbot report
 func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool) (string, bool, bool) {
 	log.Printf("\nFinding joining set of changed files from profile[count=%d] & github\n", len(changes.Changed))
-	rows := []string{
-		"The following is the coverage report on the affected files.",
-		fmt.Sprintf("Say `/test %s` to re-run this coverage report", os.Getenv("JOB_NAME")),
+	rows := []string{"The following is the coverage report on the affected files."}
+	// there is no job to re-run when running locally
+	if jobName := os.Getenv("JOB_NAME"); jobName != "" {
+		rows = append(rows, fmt.Sprintf("Say `/test %s` to re-run this coverage report", jobName))
+	}
+	rows = append(rows,
 		"",
 		"File | Old Coverage | New Coverage | Delta",
 		"---- |:------------:|:------------:|:-----:",
-	}
+	)
 
 	isEmpty, isCoverageLow := true, false
 
@@ -152,7 +156,7 @@ func (changes *GroupChanges) processChangedFiles(githubFilePaths map[string]bool
 			rows = append(rows, inc.githubBotRow(i, pathFromProfile))
 			isEmpty = false
 
-			if inc.new.IsCoverageLow(changes.NewGroup.covThresholdInt) {
+			if inc.new.IsCoverageLow(changes.NewGroup.ThresholdFor(path.Dir(inc.new.Name()))) {
 				fmt.Printf("\t(Coverage low!)")
 				isCoverageLow = true
 			}
@@ -186,3 +190,43 @@ func (changes *GroupChanges) ContentForGithubPost(files map[string]bool) (string
 	fmt.Printf("\n\n")
 	return changes.processChangedFiles(files)
 }
+
+// PackagesContentForGithubPost constructs the package coverage section of the
+// message covbot posts, rolling up the coverage of the packages containing
+// the given files, or of all packages if files is empty. It also returns
+// whether any of these packages has coverage below its threshold.
+func (g *CoverageList) PackagesContentForGithubPost(files map[string]bool) (string, bool) {
+	rows := []string{
+		"Package | Coverage | Threshold",
+		"------- |:--------:|:---------:",
+	}
+	isCoverageLow := false
+	for _, pkg := range g.Packages() {
+		dir := githubUtil.DirPathProfileToGithub(pkg.Name())
+		if len(files) > 0 && !hasFileInDir(files, dir) {
+			continue
+		}
+		threshold := g.ThresholdFor(pkg.Name())
+		coverage := pkg.Percentage()
+		if pkg.IsCoverageLow(threshold) {
+			coverage += " (low)"
+			isCoverageLow = true
+		}
+		rows = append(rows, fmt.Sprintf("%s | %s | %d%%", dir, coverage, threshold))
+	}
+	if len(rows) == 2 {
+		return "", false
+	}
+	rows = append(rows, "")
+	return strings.Join(rows, "\n"), isCoverageLow
+}
+
+// hasFileInDir checks whether any of the concerned files is in the directory
+func hasFileInDir(files map[string]bool, dir string) bool {
+	for file, isConcerned := range files {
+		if isConcerned && path.Dir(file) == dir {
+			return true
+		}
+	}
+	return false
+}
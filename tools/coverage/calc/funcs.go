@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// funcs.go calculates the coverage of functions and methods, by mapping code
+// blocks of the profile onto the functions parsed from the source files
+
+package calc
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"strings"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+)
+
+// FuncCoverage is the coverage of a function or method, named "Func" or
+// "Type.Method"
+type FuncCoverage struct {
+	Coverage
+	File      string // the file the function is in, as named in the profile
+	StartLine int
+	EndLine   int
+}
+
+// funcName returns the name of the function, prefixed by the receiver type
+// for methods
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	typ := fd.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// parseFuncs parses the functions and methods with a body in the source file,
+// in the order they are declared
+func parseFuncs(srcPath, fileName string) ([]FuncCoverage, error) {
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, srcPath, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []FuncCoverage
+	for _, decl := range af.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		funcs = append(funcs, FuncCoverage{
+			Coverage:  *newCoverage(funcName(fd)),
+			File:      fileName,
+			StartLine: fset.Position(fd.Pos()).Line,
+			EndLine:   fset.Position(fd.End()).Line,
+		})
+	}
+	return funcs, nil
+}
+
+// addBlock adds the block to the coverage of the function containing it,
+// function literals being part of the enclosing function
+func addBlock(funcs []FuncCoverage, blk *codeBlock) {
+	for i := range funcs {
+		if blk.startLine >= funcs[i].StartLine && blk.startLine <= funcs[i].EndLine {
+			funcs[i].nAllStmts += blk.numStatements
+			if blk.coverageCount > 0 {
+				funcs[i].nCoveredStmts += blk.numStatements
+			}
+			return
+		}
+	}
+}
+
+// FuncCovList reads profiling information from reader and calculates the
+// coverage of each function in the files of the CoverageList, in the order
+// of the files in the profile. Source files are read from srcRoot, joined
+// with the file path in github; files that can't be parsed are skipped.
+func FuncCovList(f *artifacts.ProfileReader, srcRoot string, files *CoverageList) []FuncCoverage {
+	defer f.Close()
+
+	concerned := files.Map()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // discard first line
+
+	// functions of each file, nil for files that can't be parsed
+	funcsOf := make(map[string][]FuncCoverage)
+	var order []string
+	for scanner.Scan() {
+		blk := toBlock(scanner.Text())
+		if _, ok := concerned[blk.fileName]; !ok {
+			continue
+		}
+		funcs, ok := funcsOf[blk.fileName]
+		if !ok {
+			var err error
+			if funcs, err = parseFuncs(path.Join(srcRoot, blk.filePathInGithub()), blk.fileName); err != nil {
+				log.Printf("Skipping functions of %s: %v\n", blk.fileName, err)
+			}
+			funcsOf[blk.fileName] = funcs
+			order = append(order, blk.fileName)
+		}
+		addBlock(funcs, blk)
+	}
+
+	var res []FuncCoverage
+	for _, fileName := range order {
+		res = append(res, funcsOf[fileName]...)
+	}
+	return res
+}
+
+// isChanged checks whether any of the changed lines is in the function
+func (fc *FuncCoverage) isChanged(changed []git.LineRange) bool {
+	for _, r := range changed {
+		if r.Start <= fc.EndLine && r.End >= fc.StartLine {
+			return true
+		}
+	}
+	return false
+}
+
+// FuncsContentForGithubPost constructs the section of the message covbot
+// posts listing the coverage of functions with changed lines, with
+// changedLines keyed by file path in github. Functions without any covered
+// statement are called out. It's empty if no function with statements is
+// changed.
+func FuncsContentForGithubPost(funcs []FuncCoverage, changedLines map[string][]git.LineRange) string {
+	var rows []string
+	nCovered, nChanged := 0, 0
+	for i := range funcs {
+		fc := &funcs[i]
+		file := githubUtil.FilePathProfileToGithub(fc.File)
+		if fc.nAllStmts == 0 || !fc.isChanged(changedLines[file]) {
+			continue
+		}
+		nChanged++
+		coverage := fc.Percentage()
+		if fc.nCoveredStmts == 0 {
+			coverage = fmt.Sprintf("**%s** (not covered)", coverage)
+		} else {
+			nCovered++
+		}
+		rows = append(rows, fmt.Sprintf("%s | `%s` | %s", file, fc.Name(), coverage))
+	}
+	if nChanged == 0 {
+		return ""
+	}
+	header := []string{
+		fmt.Sprintf("Changed functions: %d of %d covered", nCovered, nChanged),
+		"",
+		"File | Function | Coverage",
+		"---- | -------- |:--------:",
+	}
+	return strings.Join(append(append(header, rows...), ""), "\n")
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package calc
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+const funcsSrc = `package foo
+
+type T struct{}
+
+func (t *T) Get() int {
+	return 1
+}
+
+func Add(a, b int) int {
+	if a > 0 {
+		return a + b
+	}
+	return b
+}
+
+func unused() {}
+`
+
+const funcsProfile = `mode: set
+` + testPkg + `/foo/foo.go:5.23,7.2 1 1
+` + testPkg + `/foo/foo.go:9.24,10.11 1 0
+` + testPkg + `/foo/foo.go:10.11,12.3 1 0
+` + testPkg + `/foo/foo.go:13.2,13.10 1 0
+` + testPkg + `/foo/foo.go:16.15,16.17 0 0
+` + testPkg + `/missing/missing.go:3.10,5.2 1 1
+`
+
+func profileReader(profile string) *artifacts.ProfileReader {
+	return artifacts.NewProfileReader(ioutil.NopCloser(strings.NewReader(profile)))
+}
+
+func TestFuncCovList(t *testing.T) {
+	root, err := ioutil.TempDir("", "funcs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.MkdirAll(path.Join(root, "pkg/foo"), 0755)
+	if err := ioutil.WriteFile(path.Join(root, "pkg/foo/foo.go"), []byte(funcsSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := CovList(profileReader(funcsProfile), nil, nil, 50)
+	funcs := FuncCovList(profileReader(funcsProfile), root, files)
+
+	// functions of missing.go are skipped, as its source is missing
+	test.AssertEqual(t, 3, len(funcs))
+	expected := []struct {
+		name           string
+		start, end     int
+		nCovered, nAll int
+	}{
+		{"T.Get", 5, 7, 1, 1},
+		{"Add", 9, 14, 0, 3},
+		{"unused", 16, 16, 0, 0},
+	}
+	for i, want := range expected {
+		fc := funcs[i]
+		test.AssertEqual(t, want.name, fc.Name())
+		test.AssertEqual(t, testPkg+"/foo/foo.go", fc.File)
+		test.AssertEqual(t, want.start, fc.StartLine)
+		test.AssertEqual(t, want.end, fc.EndLine)
+		test.AssertEqual(t, want.nCovered, fc.nCoveredStmts)
+		test.AssertEqual(t, want.nAll, fc.nAllStmts)
+	}
+
+	post := FuncsContentForGithubPost(funcs, map[string][]git.LineRange{"pkg/foo/foo.go": {{Start: 6, End: 6}, {Start: 11, End: 11}}})
+	for _, want := range []string{
+		"Changed functions: 1 of 2 covered",
+		"pkg/foo/foo.go | `T.Get` | 100.0%",
+		"pkg/foo/foo.go | `Add` | **0.0%** (not covered)",
+	} {
+		if !strings.Contains(post, want) {
+			t.Errorf("post doesn't contain %q:\n%s", want, post)
+		}
+	}
+	// unused has no statement, and is not listed even if changed
+	test.AssertEqual(t, "", FuncsContentForGithubPost(funcs, map[string][]git.LineRange{"pkg/foo/foo.go": {{Start: 16, End: 16}}}))
+}
@@ -32,6 +32,8 @@ type CoverageList struct {
 	group           []Coverage
 	concernedFiles  map[string]bool
 	covThresholdInt int
+	// thresholdFor overrides covThresholdInt per directory, if set
+	thresholdFor func(dir string) int
 }
 
 // NewCoverageList constructs new (file) group Coverage
@@ -48,6 +50,20 @@ func (g *CoverageList) CovThresInt() int {
 	return g.covThresholdInt
 }
 
+// SetThresholdFunc sets the function deciding the coverage threshold of each
+// directory, overriding the threshold of the list
+func (g *CoverageList) SetThresholdFunc(f func(dir string) int) {
+	g.thresholdFor = f
+}
+
+// ThresholdFor returns the coverage threshold of the given directory
+func (g *CoverageList) ThresholdFor(dir string) int {
+	if g.thresholdFor != nil {
+		return g.thresholdFor(dir)
+	}
+	return g.covThresholdInt
+}
+
 // writeToFile writes file level coverage in a file
 func (g *CoverageList) writeToFile(filePath string) {
 	f, err := os.Create(filePath)
@@ -118,9 +134,17 @@ func (g *CoverageList) lastElement() *Coverage {
 
 // Subset returns the subset obtained through applying filter
 func (g *CoverageList) Subset(prefix string) *CoverageList {
+	return g.Filter(func(c *Coverage) bool {
+		return strings.HasPrefix(c.Name(), prefix)
+	})
+}
+
+// Filter returns the summarized subset of items kept by the filter
+func (g *CoverageList) Filter(keep func(c *Coverage) bool) *CoverageList {
 	s := NewCoverageList("Filtered Summary", g.concernedFiles, g.covThresholdInt)
+	s.thresholdFor = g.thresholdFor
 	for _, c := range g.group {
-		if strings.HasPrefix(c.Name(), prefix) {
+		if keep(&c) {
 			s.append(&c)
 		}
 	}
@@ -128,6 +152,23 @@ func (g *CoverageList) Subset(prefix string) *CoverageList {
 	return s
 }
 
+// Packages rolls up the file coverages by package, i.e. by the directory
+// containing the files, sorted by package name
+func (g *CoverageList) Packages() []Coverage {
+	packages := make(map[string]Coverage)
+	for _, c := range g.group {
+		dir := path.Dir(c.name)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = *newCoverage(dir)
+		}
+		pkg.nCoveredStmts += c.nCoveredStmts
+		pkg.nAllStmts += c.nAllStmts
+		packages[dir] = pkg
+	}
+	return sorted(packages)
+}
+
 // Map returns maps the file name to its coverage for faster retrieval
 // & membership check
 func (g *CoverageList) Map() map[string]Coverage {
@@ -14,3 +14,75 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 package calc
+
+import (
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+const testPkg = "knative.dev/test-infra/pkg"
+
+// groupForTest creates a list of files in two packages, with pkg/a at 75%
+// and pkg/b at 20% coverage
+func groupForTest() *CoverageList {
+	g := NewCoverageList("test", nil, 50)
+	for _, c := range []Coverage{
+		{name: testPkg + "/a/a1.go", nCoveredStmts: 2, nAllStmts: 2},
+		{name: testPkg + "/a/a2.go", nCoveredStmts: 1, nAllStmts: 2},
+		{name: testPkg + "/b/b.go", nCoveredStmts: 1, nAllStmts: 5},
+	} {
+		g.append(&c)
+	}
+	return g
+}
+
+func TestPackages(t *testing.T) {
+	pkgs := groupForTest().Packages()
+	test.AssertEqual(t, 2, len(pkgs))
+	test.AssertEqual(t, testPkg+"/a", pkgs[0].Name())
+	test.AssertEqual(t, 3, pkgs[0].nCoveredStmts)
+	test.AssertEqual(t, 4, pkgs[0].nAllStmts)
+	test.AssertEqual(t, testPkg+"/b", pkgs[1].Name())
+	test.AssertEqual(t, 1, pkgs[1].nCoveredStmts)
+	test.AssertEqual(t, 5, pkgs[1].nAllStmts)
+}
+
+func TestFilter(t *testing.T) {
+	g := groupForTest()
+	g.SetThresholdFunc(func(dir string) int {
+		if dir == testPkg+"/b" {
+			return 10
+		}
+		return 80
+	})
+	s := g.Filter(func(c *Coverage) bool { return c.Name() != testPkg+"/a/a1.go" })
+	test.AssertEqual(t, 2, len(s.group))
+	test.AssertEqual(t, 2, s.nCoveredStmts)
+	test.AssertEqual(t, 7, s.nAllStmts)
+	// thresholds are kept by the subset
+	test.AssertEqual(t, 10, s.ThresholdFor(testPkg+"/b"))
+	test.AssertEqual(t, 80, s.ThresholdFor(testPkg+"/a"))
+	test.AssertEqual(t, 50, groupForTest().ThresholdFor(testPkg+"/b"))
+}
+
+func TestPackagesContentForGithubPost(t *testing.T) {
+	content, isLow := groupForTest().PackagesContentForGithubPost(map[string]bool{"pkg/a/a1.go": true})
+	test.AssertEqual(t, false, isLow)
+	expected := "Package | Coverage | Threshold\n" +
+		"------- |:--------:|:---------:\n" +
+		"pkg/a | 75.0% | 50%\n"
+	test.AssertEqual(t, expected, content)
+
+	content, isLow = groupForTest().PackagesContentForGithubPost(nil)
+	test.AssertEqual(t, true, isLow)
+	expected = "Package | Coverage | Threshold\n" +
+		"------- |:--------:|:---------:\n" +
+		"pkg/a | 75.0% | 50%\n" +
+		"pkg/b | 20.0% (low) | 50%\n"
+	test.AssertEqual(t, expected, content)
+
+	content, isLow = groupForTest().PackagesContentForGithubPost(map[string]bool{"pkg/c/c.go": true})
+	test.AssertEqual(t, false, isLow)
+	test.AssertEqual(t, "", content)
+}
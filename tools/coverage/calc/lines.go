@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// lines.go calculates line by line coverage, for exporting coverage in
+// formats based on lines rather than statements
+
+package calc
+
+import (
+	"bufio"
+	"sort"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+)
+
+// FileLines stores the hit counts of the lines of a file
+type FileLines struct {
+	// Name is the file name in the profile
+	Name string
+	// Hits are the hit counts keyed by line number, only lines inside code
+	// blocks with statements are included
+	Hits map[int]int
+}
+
+// Lines returns the line numbers in ascending order
+func (fl *FileLines) Lines() []int {
+	lines := make([]int, 0, len(fl.Hits))
+	for line := range fl.Hits {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// NumCovered returns the number of lines hit at least once
+func (fl *FileLines) NumCovered() int {
+	n := 0
+	for _, hits := range fl.Hits {
+		if hits > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// LineCovList reads profiling information from reader and returns the line
+// coverage of each file, sorted by file name. The hit count of a line is the
+// highest count of the code blocks containing it.
+func LineCovList(f *artifacts.ProfileReader) []FileLines {
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // discard first line
+
+	files := make(map[string]*FileLines)
+	var names []string
+	for scanner.Scan() {
+		blk := toBlock(scanner.Text())
+		if blk.numStatements == 0 {
+			continue
+		}
+		fl, ok := files[blk.fileName]
+		if !ok {
+			fl = &FileLines{Name: blk.fileName, Hits: make(map[int]int)}
+			files[blk.fileName] = fl
+			names = append(names, blk.fileName)
+		}
+		for line := blk.startLine; line <= blk.endLine; line++ {
+			if hits, ok := fl.Hits[line]; !ok || blk.coverageCount > hits {
+				fl.Hits[line] = blk.coverageCount
+			}
+		}
+	}
+
+	sort.Strings(names)
+	result := make([]FileLines, 0, len(names))
+	for _, name := range names {
+		result = append(result, *files[name])
+	}
+	return result
+}
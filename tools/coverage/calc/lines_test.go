@@ -0,0 +1,54 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package calc
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/artifacts/artsTest"
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+func TestLineCovList(t *testing.T) {
+	f, err := os.Open(artsTest.LocalInputArtsForTest().KeyProfilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := LineCovList(artifacts.NewProfileReader(f))
+
+	// common.go has no statement
+	test.AssertEqual(t, 3, len(files))
+	expectedNames := []string{"newlyAddedFile.go", "onlySrcChange.go", "onlyTestChange.go"}
+	for i, name := range expectedNames {
+		test.AssertEqual(t, "knative.dev/test-infra/tools/coverage/testTarget/presubmit/"+name, files[i].Name)
+	}
+
+	src := files[1]
+	test.AssertEqual(t, 4, src.NumCovered())
+	if !reflect.DeepEqual([]int{4, 5, 6, 7, 9, 10, 11}, src.Lines()) {
+		t.Errorf("got lines %v, expected [4 5 6 7 9 10 11]", src.Lines())
+	}
+	test.AssertEqual(t, 1, src.Hits[7])
+	test.AssertEqual(t, 0, src.Hits[9])
+
+	added := files[0]
+	test.AssertEqual(t, 32, len(added.Hits))
+	test.AssertEqual(t, 22, added.NumCovered())
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// patch.go calculates patch coverage, i.e. the coverage of the lines changed
+// by a pull request
+
+package calc
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+)
+
+// patchLines collects the changed lines inside code blocks, and whether
+// they are covered, keyed by file name
+type patchLines map[string]map[int]bool
+
+// add adds the changed lines inside the block. A line is covered if any block
+// containing it is covered
+func (pl patchLines) add(blk *codeBlock, changed []git.LineRange) {
+	for _, r := range changed {
+		for line := r.Start; line <= r.End; line++ {
+			if line < blk.startLine || line > blk.endLine {
+				continue
+			}
+			if pl[blk.fileName] == nil {
+				pl[blk.fileName] = make(map[int]bool)
+			}
+			pl[blk.fileName][line] = pl[blk.fileName][line] || blk.coverageCount > 0
+		}
+	}
+}
+
+// coverageList converts the lines into a summarized CoverageList sorted by
+// file name
+func (pl patchLines) coverageList(covThresInt int) *CoverageList {
+	covs := make(map[string]Coverage)
+	for name, lines := range pl {
+		c := newCoverage(name)
+		for _, covered := range lines {
+			c.nAllStmts++
+			if covered {
+				c.nCoveredStmts++
+			}
+		}
+		covs[name] = *c
+	}
+	g := NewCoverageList("patchSummary", nil, covThresInt)
+	g.group = append(g.group, sorted(covs)...)
+	g.Summarize()
+	return g
+}
+
+// uncovered returns the ranges of uncovered lines of the file, in ascending
+// order, consecutive uncovered lines forming a range
+func (pl patchLines) uncovered(name string) []git.LineRange {
+	var lines []int
+	for line, covered := range pl[name] {
+		if !covered {
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+	var ranges []git.LineRange
+	for _, line := range lines {
+		if n := len(ranges); n > 0 && ranges[n-1].End == line-1 {
+			ranges[n-1].End = line
+			continue
+		}
+		ranges = append(ranges, git.LineRange{Start: line, End: line})
+	}
+	return ranges
+}
+
+// readPatchLines reads profiling information from reader and collects the
+// changed lines inside code blocks, with changedLines keyed by file path in
+// github
+func readPatchLines(f *artifacts.ProfileReader, changedLines map[string][]git.LineRange) patchLines {
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // discard first line
+
+	pl := make(patchLines)
+	for scanner.Scan() {
+		blk := toBlock(scanner.Text())
+		if changed, ok := changedLines[blk.filePathInGithub()]; ok {
+			pl.add(blk, changed)
+		}
+	}
+	return pl
+}
+
+// PatchCovList reads profiling information from reader and constructs the
+// patch CoverageList, with changedLines keyed by file path in github.
+// Coverage of the list and its items count changed lines instead of
+// statements. Only changed lines inside a code block of the profile are
+// counted, so that comments and declarations don't change the coverage.
+// The returned list is already summarized.
+func PatchCovList(f *artifacts.ProfileReader, changedLines map[string][]git.LineRange,
+	covThresInt int) *CoverageList {
+
+	return readPatchLines(f, changedLines).coverageList(covThresInt)
+}
+
+// UncoveredPatchLines reads profiling information from reader and returns
+// the ranges of changed lines inside code blocks that are not covered, keyed
+// by file path in github. Only files in the patch CoverageList are included,
+// so that files it excludes are not reported.
+func UncoveredPatchLines(f *artifacts.ProfileReader, changedLines map[string][]git.LineRange,
+	patch *CoverageList) map[string][]git.LineRange {
+
+	pl := readPatchLines(f, changedLines)
+	uncovered := make(map[string][]git.LineRange)
+	for name := range patch.Map() {
+		if ranges := pl.uncovered(name); len(ranges) > 0 {
+			uncovered[githubUtil.FilePathProfileToGithub(name)] = ranges
+		}
+	}
+	return uncovered
+}
+
+// PatchContentForGithubPost constructs the patch coverage section of the
+// message covbot posts, it's empty if no changed line is inside a code block
+func PatchContentForGithubPost(patch *CoverageList) string {
+	if patch.nAllStmts == 0 {
+		return ""
+	}
+	rows := []string{
+		fmt.Sprintf("Patch coverage: %s (%d of %d changed lines covered)",
+			patch.Coverage.Percentage(), patch.nCoveredStmts, patch.nAllStmts),
+		"",
+		"File | Covered Lines | Uncovered Lines | Patch Coverage",
+		"---- |:-------------:|:---------------:|:--------------:",
+	}
+	for _, c := range patch.group {
+		rows = append(rows, fmt.Sprintf("%s | %d | %d | %s",
+			githubUtil.FilePathProfileToGithub(c.Name()), c.nCoveredStmts,
+			c.nAllStmts-c.nCoveredStmts, c.Percentage()))
+	}
+	rows = append(rows, "")
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package calc
+
+import (
+	"reflect"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+func TestToBlock(t *testing.T) {
+	blk := toBlock("knative.dev/test-infra/pkg/fake.go:12.34,15.2 3 1")
+	expected := codeBlock{
+		fileName:      "knative.dev/test-infra/pkg/fake.go",
+		startLine:     12,
+		endLine:       15,
+		numStatements: 3,
+		coverageCount: 1,
+	}
+	if *blk != expected {
+		test.Fail(t, "toBlock", expected, *blk)
+	}
+}
+
+func TestPatchCoverage(t *testing.T) {
+	pl := make(patchLines)
+	// lines 5-6 and 20-22 changed in big.go, line 40 changed outside of any block
+	bigChanges := []git.LineRange{{Start: 5, End: 6}, {Start: 20, End: 22}, {Start: 40, End: 40}}
+	pl.add(toBlock("big.go:1.10,10.2 5 3"), bigChanges)
+	pl.add(toBlock("big.go:18.10,25.2 4 0"), bigChanges)
+	// line 22 is also in a covered block
+	pl.add(toBlock("big.go:22.5,24.3 1 1"), bigChanges)
+	// unchanged file is not counted
+	pl.add(toBlock("small.go:1.10,10.2 5 0"), nil)
+
+	g := pl.coverageList(50)
+	test.AssertEqual(t, 1, len(g.group))
+	big := g.Item(0)
+	test.AssertEqual(t, "big.go", big.Name())
+	test.AssertEqual(t, 5, big.nAllStmts)
+	test.AssertEqual(t, 3, big.nCoveredStmts)
+	test.AssertEqual(t, 5, g.nAllStmts)
+	test.AssertEqual(t, 3, g.nCoveredStmts)
+	test.AssertEqual(t, false, g.Coverage.IsCoverageLow(50))
+	test.AssertEqual(t, true, g.Coverage.IsCoverageLow(70))
+}
+
+func TestPatchCoverageEmpty(t *testing.T) {
+	g := make(patchLines).coverageList(50)
+	test.AssertEqual(t, false, g.Coverage.IsCoverageLow(50))
+	test.AssertEqual(t, "", PatchContentForGithubPost(g))
+}
+
+func TestPatchUncovered(t *testing.T) {
+	pl := make(patchLines)
+	changes := []git.LineRange{{Start: 2, End: 4}, {Start: 8, End: 12}}
+	pl.add(toBlock("big.go:1.10,5.2 3 0"), changes)
+	pl.add(toBlock("big.go:8.10,12.2 4 0"), changes)
+	// line 10 is also in a covered block
+	pl.add(toBlock("big.go:10.5,10.20 1 1"), changes)
+
+	want := []git.LineRange{{Start: 2, End: 4}, {Start: 8, End: 9}, {Start: 11, End: 12}}
+	if got := pl.uncovered("big.go"); !reflect.DeepEqual(got, want) {
+		test.Fail(t, "uncovered", want, got)
+	}
+	if got := pl.uncovered("small.go"); got != nil {
+		test.Fail(t, "uncovered", nil, got)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cobertura.go converts line coverage to Cobertura XML
+
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+)
+
+const coberturaDoctype = `<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`
+
+// use var for the following function so that it can be mocked in the unit test
+var now = time.Now
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        string             `xml:"line-rate,attr"`
+	BranchRate      string             `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      string             `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity string           `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity string          `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// lineRate formats the ratio of covered lines, 1 if there is no line
+func lineRate(covered, valid int) string {
+	if valid == 0 {
+		return "1"
+	}
+	return fmt.Sprintf("%.4f", float64(covered)/float64(valid))
+}
+
+// WriteCobertura writes the line coverage in Cobertura XML. Files are classes
+// grouped by their directory as packages, with file paths relative to the
+// repository root. Branches are not available in Go coverage profiles, so the
+// branch rate is always 0.
+func WriteCobertura(files []calc.FileLines, w io.Writer) error {
+	cov := coberturaCoverage{
+		BranchRate: "0",
+		Complexity: "0",
+		Version:    "knative-coverage",
+		Timestamp:  now().Unix(),
+		Sources:    []string{"."},
+	}
+	// files of a directory aren't necessarily adjacent once sorted, e.g.
+	// pkg/a.go < pkg/a/x.go < pkg/b.go, so classes are grouped by directory
+	pkgs := make(map[string]*coberturaPackage)
+	covered, valid := make(map[string]int), make(map[string]int)
+	for _, fl := range files {
+		filename := githubUtil.FilePathProfileToGithub(fl.Name)
+		dir := path.Dir(filename)
+		pkg, ok := pkgs[dir]
+		if !ok {
+			pkg = &coberturaPackage{Name: dir, BranchRate: "0", Complexity: "0"}
+			pkgs[dir] = pkg
+		}
+		class := coberturaClass{
+			Name:       path.Base(filename),
+			Filename:   filename,
+			LineRate:   lineRate(fl.NumCovered(), len(fl.Hits)),
+			BranchRate: "0",
+			Complexity: "0",
+		}
+		for _, line := range fl.Lines() {
+			class.Lines = append(class.Lines, coberturaLine{line, fl.Hits[line]})
+		}
+		pkg.Classes = append(pkg.Classes, class)
+		covered[dir] += fl.NumCovered()
+		valid[dir] += len(fl.Hits)
+	}
+	dirs := make([]string, 0, len(pkgs))
+	for dir := range pkgs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		pkg := pkgs[dir]
+		pkg.LineRate = lineRate(covered[dir], valid[dir])
+		cov.Packages = append(cov.Packages, *pkg)
+	}
+	cov.LinesCovered, cov.LinesValid = countLines(files)
+	cov.LineRate = lineRate(cov.LinesCovered, cov.LinesValid)
+
+	output, err := xml.MarshalIndent(cov, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling cobertura xml: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n%s\n", xml.Header, coberturaDoctype, output)
+	return err
+}
+
+// countLines returns the number of covered lines and of all lines in the files
+func countLines(files []calc.FileLines) (int, int) {
+	var covered, valid int
+	for _, fl := range files {
+		covered += fl.NumCovered()
+		valid += len(fl.Hits)
+	}
+	return covered, valid
+}
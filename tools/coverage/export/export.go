@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package export converts coverage profiles into formats consumed by
+// third-party coverage viewers and IDE plugins
+package export
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+)
+
+// CreateFiles converts the coverage profile into Cobertura XML and LCOV files
+// in the given directory
+func CreateFiles(profilePath, directory string) error {
+	f, err := os.Open(profilePath)
+	if err != nil {
+		return fmt.Errorf("failed opening coverage profile '%s': %v", profilePath, err)
+	}
+	files := calc.LineCovList(artifacts.NewProfileReader(f))
+
+	return helpers.CombineErrors([]error{
+		createFile(artifacts.CoberturaXmlPath(directory), files, WriteCobertura),
+		createFile(artifacts.LcovPath(directory), files, WriteLcov),
+	})
+}
+
+// createFile creates the file and writes the line coverage to it
+func createFile(filePath string, files []calc.FileLines, write func([]calc.FileLines, io.Writer) error) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed creating '%s': %v", filePath, err)
+	}
+	defer f.Close()
+	if err := write(files, f); err != nil {
+		return fmt.Errorf("failed writing '%s': %v", filePath, err)
+	}
+	log.Printf("coverage exported to '%s'", filePath)
+	return nil
+}
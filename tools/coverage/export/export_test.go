@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/artifacts/artsTest"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+const testProfile = `mode: count
+knative.dev/test-infra/pkg/a/a.go:3.20,5.2 1 2
+knative.dev/test-infra/pkg/a/a.go:5.2,6.3 1 0
+knative.dev/test-infra/pkg/b/b.go:1.10,2.2 1 0
+`
+
+func testFiles() []calc.FileLines {
+	return calc.LineCovList(artifacts.NewProfileReader(ioutil.NopCloser(strings.NewReader(testProfile))))
+}
+
+func TestWriteLcov(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLcov(testFiles(), &buf); err != nil {
+		t.Fatalf("WriteLcov() failed: %v", err)
+	}
+	expected := `TN:
+SF:pkg/a/a.go
+DA:3,2
+DA:4,2
+DA:5,2
+DA:6,0
+LF:4
+LH:3
+end_of_record
+SF:pkg/b/b.go
+DA:1,0
+DA:2,0
+LF:2
+LH:0
+end_of_record
+`
+	test.AssertEqual(t, expected, buf.String())
+}
+
+func TestWriteCobertura(t *testing.T) {
+	now = func() time.Time { return time.Unix(1600000000, 0) }
+	defer func() { now = time.Now }()
+
+	var buf bytes.Buffer
+	if err := WriteCobertura(testFiles(), &buf); err != nil {
+		t.Fatalf("WriteCobertura() failed: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">
+<coverage line-rate="0.5000" branch-rate="0" lines-covered="3" lines-valid="6" branches-covered="0" branches-valid="0" complexity="0" version="knative-coverage" timestamp="1600000000">
+  <sources>
+    <source>.</source>
+  </sources>
+  <packages>
+    <package name="pkg/a" line-rate="0.7500" branch-rate="0" complexity="0">
+      <classes>
+        <class name="a.go" filename="pkg/a/a.go" line-rate="0.7500" branch-rate="0" complexity="0">
+          <methods></methods>
+          <lines>
+            <line number="3" hits="2"></line>
+            <line number="4" hits="2"></line>
+            <line number="5" hits="2"></line>
+            <line number="6" hits="0"></line>
+          </lines>
+        </class>
+      </classes>
+    </package>
+    <package name="pkg/b" line-rate="0.0000" branch-rate="0" complexity="0">
+      <classes>
+        <class name="b.go" filename="pkg/b/b.go" line-rate="0.0000" branch-rate="0" complexity="0">
+          <methods></methods>
+          <lines>
+            <line number="1" hits="0"></line>
+            <line number="2" hits="0"></line>
+          </lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>
+`
+	test.AssertEqual(t, expected, buf.String())
+}
+
+func TestWriteCoberturaInterleavedDirs(t *testing.T) {
+	// pkg/a.go < pkg/a/x.go < pkg/b.go once sorted, files of pkg aren't adjacent
+	profile := `mode: count
+knative.dev/test-infra/pkg/a.go:1.10,2.2 1 1
+knative.dev/test-infra/pkg/a/x.go:1.10,2.2 1 0
+knative.dev/test-infra/pkg/b.go:1.10,2.2 1 0
+`
+	files := calc.LineCovList(artifacts.NewProfileReader(ioutil.NopCloser(strings.NewReader(profile))))
+	var buf bytes.Buffer
+	if err := WriteCobertura(files, &buf); err != nil {
+		t.Fatalf("WriteCobertura() failed: %v", err)
+	}
+	var cov coberturaCoverage
+	if err := xml.Unmarshal(buf.Bytes()[strings.Index(buf.String(), "<coverage"):], &cov); err != nil {
+		t.Fatalf("failed parsing cobertura xml: %v", err)
+	}
+	var got []string
+	for _, pkg := range cov.Packages {
+		var classes []string
+		for _, class := range pkg.Classes {
+			classes = append(classes, class.Filename)
+		}
+		got = append(got, pkg.Name+": "+strings.Join(classes, ",")+" "+pkg.LineRate)
+	}
+	expected := []string{
+		"pkg: pkg/a.go,pkg/b.go 0.5000",
+		"pkg/a: pkg/a/x.go 0.0000",
+	}
+	test.AssertEqual(t, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+}
+
+func TestCreateFiles(t *testing.T) {
+	arts := artsTest.LocalArtsForTest("TestCreateFiles")
+	defer test.DeleteDir(arts.Directory())
+	if err := CreateFiles(artsTest.LocalInputArtsForTest().KeyProfilePath(), arts.Directory()); err != nil {
+		t.Fatalf("CreateFiles() failed: %v", err)
+	}
+
+	contents, err := ioutil.ReadFile(artifacts.CoberturaXmlPath(arts.Directory()))
+	if err != nil {
+		t.Fatalf("failed reading cobertura xml: %v", err)
+	}
+	var cov coberturaCoverage
+	if err := xml.Unmarshal(contents, &cov); err != nil {
+		t.Fatalf("invalid cobertura xml: %v", err)
+	}
+	// 30 of the 46 lines with statements of the testTarget/presubmit fixtures are covered
+	test.AssertEqual(t, 30, cov.LinesCovered)
+	test.AssertEqual(t, 46, cov.LinesValid)
+	test.AssertEqual(t, 1, len(cov.Packages))
+	test.AssertEqual(t, "tools/coverage/testTarget/presubmit", cov.Packages[0].Name)
+	test.AssertEqual(t, 3, len(cov.Packages[0].Classes))
+
+	contents, err = ioutil.ReadFile(artifacts.LcovPath(arts.Directory()))
+	if err != nil {
+		t.Fatalf("failed reading lcov: %v", err)
+	}
+	test.AssertEqual(t, 3, strings.Count(string(contents), "end_of_record"))
+	if !strings.Contains(string(contents), "SF:tools/coverage/testTarget/presubmit/onlySrcChange.go\n") {
+		t.Errorf("lcov doesn't contain onlySrcChange.go:\n%s", contents)
+	}
+}
+
+func TestCreateFilesMissingProfile(t *testing.T) {
+	if err := CreateFiles("does-not-exist.txt", ""); err == nil {
+		t.Error("CreateFiles() with missing profile expected error")
+	}
+}
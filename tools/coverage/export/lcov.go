@@ -0,0 +1,45 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// lcov.go converts line coverage to the LCOV tracefile format
+
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+)
+
+// WriteLcov writes the line coverage as an LCOV tracefile, with file paths
+// relative to the repository root
+func WriteLcov(files []calc.FileLines, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TN:")
+	for _, fl := range files {
+		fmt.Fprintf(bw, "SF:%s\n", githubUtil.FilePathProfileToGithub(fl.Name))
+		for _, line := range fl.Lines() {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, fl.Hits[line])
+		}
+		fmt.Fprintf(bw, "LF:%d\n", len(fl.Hits))
+		fmt.Fprintf(bw, "LH:%d\n", fl.NumCovered())
+		fmt.Fprintln(bw, "end_of_record")
+	}
+	return bw.Flush()
+}
@@ -19,85 +19,50 @@ limitations under the License.
 package gcs
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"log"
 	"path"
 
-	"cloud.google.com/go/storage"
-	"google.golang.org/api/iterator"
+	pkggcs "knative.dev/test-infra/pkg/gcs"
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/logUtil"
 )
 
-type StorageClient interface {
-	ListGcsObjects(ctx context.Context, bucketName, prefix, delim string) []string
-	ProfileReader(ctx context.Context, bucket, object string) *artifacts.ProfileReader
-	DoesObjectExist(ctx context.Context, bucket, object string) bool
-}
-
-type Client struct {
-	*storage.Client
-}
-
-// Verify that client implements the interface
-var _ StorageClient = (*Client)(nil)
-
-func NewClient(ctx context.Context) *Client {
-	client, err := storage.NewClient(ctx)
-
+// NewClient creates the storage backend on GCS, with the default credentials
+func NewClient(ctx context.Context) pkggcs.Storage {
+	client, err := pkggcs.NewClient(ctx, "")
 	if err != nil {
 		logUtil.LogFatalf("Failed to create client: %v", err)
 	}
-	return &Client{client}
+	return client
 }
 
-func (c *Client) ListGcsObjects(ctx context.Context, bucketName, prefix, delim string) []string {
-	var objects []string
-	it := c.Bucket(bucketName).Objects(ctx, &storage.Query{
-		Prefix:    prefix,
-		Delimiter: delim,
-	})
-
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Error iterating: %v", err)
-		}
-
-		if attrs.Prefix != "" {
-			objects = append(objects, path.Base(attrs.Prefix))
-		}
+// NewStorage creates the storage backend, on the local filesystem under
+// localRoot if it's not empty, or on GCS otherwise
+func NewStorage(ctx context.Context, localRoot string) pkggcs.Storage {
+	if localRoot != "" {
+		log.Printf("Using local storage in '%s'\n", localRoot)
+		return pkggcs.NewLocalStorage(localRoot)
 	}
-	log.Println("end of ListGcsObjects(...)")
-	return objects
+	return NewClient(ctx)
 }
 
-func (c *Client) ProfileReader(ctx context.Context, bucket,
+// profileReader reads the profile stored in the object of the bucket
+func profileReader(ctx context.Context, client pkggcs.Storage, bucket,
 	object string) *artifacts.ProfileReader {
 	log.Printf("Running ProfileReader on bucket '%s', object='%s'\n", bucket, object)
 
-	o := c.Bucket(bucket).Object(object)
-	reader, err := o.NewReader(ctx)
+	contents, err := client.ReadObject(ctx, bucket, object)
 	if err != nil {
-		logUtil.LogFatalf("o.NewReader(Ctx) error: %v", err)
-	}
-	return artifacts.NewProfileReader(reader)
-}
-
-// DoesObjectExist checks whether an object exists in GCS bucket
-func (c *Client) DoesObjectExist(ctx context.Context, bucket, object string) bool {
-	if _, err := c.Bucket(bucket).Object(object).Attrs(ctx); err != nil {
-		log.Printf("Error getting attrs from object '%s': %v", object, err)
-		return false
+		logUtil.LogFatalf("ReadObject(bucket=%s, object=%s) error: %v", bucket, object, err)
 	}
-	return true
+	return artifacts.NewProfileReader(ioutil.NopCloser(bytes.NewReader(contents)))
 }
 
 type GcsBuild struct {
-	Client       StorageClient
+	Client       pkggcs.Storage
 	Bucket       string
 	Job          string
 	Build        int
@@ -107,15 +72,22 @@ type GcsBuild struct {
 type GcsArtifacts struct {
 	artifacts.Artifacts
 	Ctx    context.Context
-	Client StorageClient
+	Client pkggcs.Storage
 	Bucket string
 }
 
-func NewGcsArtifacts(ctx context.Context, client StorageClient,
+func NewGcsArtifacts(ctx context.Context, client pkggcs.Storage,
 	bucket string, baseArtifacts artifacts.Artifacts) *GcsArtifacts {
 	return &GcsArtifacts{baseArtifacts, ctx, client, bucket}
 }
 
 func (arts *GcsArtifacts) ProfileReader() *artifacts.ProfileReader {
-	return arts.Client.ProfileReader(arts.Ctx, arts.Bucket, arts.ProfilePath())
+	return profileReader(arts.Ctx, arts.Client, arts.Bucket, arts.ProfilePath())
+}
+
+// Upload uploads the file in the local directory to the artifacts directory
+func (arts *GcsArtifacts) Upload(localDir, fileName string) error {
+	object := path.Join(arts.Directory(), fileName)
+	log.Printf("Uploading '%s' to bucket '%s', object='%s'\n", fileName, arts.Bucket, object)
+	return arts.Client.Upload(arts.Ctx, arts.Bucket, object, path.Join(localDir, fileName))
 }
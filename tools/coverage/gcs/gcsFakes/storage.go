@@ -0,0 +1,43 @@
+package gcsFakes
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"path"
+	"strconv"
+
+	pkggcs "knative.dev/test-infra/pkg/gcs"
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/artifacts/artsTest"
+)
+
+// NewLocalStorage creates a local storage in a temporary directory, which the
+// caller is responsible for removing. The given builds of the fake post-submit
+// job are healthy, holding the input profile for tests.
+func NewLocalStorage(builds ...int) (pkggcs.Storage, string) {
+	root, err := ioutil.TempDir("", "coverage-storage")
+	if err != nil {
+		log.Fatalf("Error making TempDir for storage: %v\n", err)
+	}
+	storage := pkggcs.NewLocalStorage(root)
+
+	inputArts := artsTest.LocalInputArtsForTest()
+	profile, err := ioutil.ReadFile(inputArts.ProfilePath())
+	if err != nil {
+		log.Fatalf("Error reading input profile: %v\n", err)
+	}
+	ctx := context.Background()
+	for _, build := range builds {
+		dir := path.Join("logs", FakePostSubmitProwJobName, strconv.Itoa(build), "artifacts")
+		for name, contents := range map[string][]byte{
+			inputArts.ProfileName():               profile,
+			artifacts.CovProfileCompletionMarker: nil,
+		} {
+			if _, err := storage.WriteObject(ctx, FakeGcsBucketName, path.Join(dir, name), contents); err != nil {
+				log.Fatalf("Error writing fake build: %v\n", err)
+			}
+		}
+	}
+	return storage, root
+}
@@ -22,6 +22,7 @@ import (
 	"sort"
 	"strconv"
 
+	pkggcs "knative.dev/test-infra/pkg/gcs"
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/logUtil"
 )
@@ -34,10 +35,10 @@ type PostSubmit struct {
 	Ctx              context.Context
 }
 
-func NewPostSubmit(ctx context.Context, client StorageClient,
+func NewPostSubmit(ctx context.Context, client pkggcs.Storage,
 	bucket, prowJobName, artifactsDirName, covProfileName string) (p *PostSubmit) {
 
-	log.Println("NewPostSubmit(Ctx, client StorageClient, ...) started")
+	log.Println("NewPostSubmit(Ctx, client Storage, ...) started")
 	gcsBuild := GcsBuild{
 		Client: client,
 		Bucket: bucket,
@@ -59,8 +60,13 @@ func NewPostSubmit(ctx context.Context, client StorageClient,
 func (p *PostSubmit) listBuilds() []int {
 	var res []int
 	jobDir := path.Join("logs", p.Job)
-	lstBuildStrs := p.Client.ListGcsObjects(p.Ctx, p.Bucket, jobDir+"/", "/")
-	for _, buildStr := range lstBuildStrs {
+	children, err := p.Client.ListDirectChildren(p.Ctx, p.Bucket, jobDir)
+	if err != nil {
+		logUtil.LogFatalf("Failed listing builds for bucket '%s' and object '%s': %v\n",
+			p.Bucket, jobDir, err)
+	}
+	for _, child := range children {
+		buildStr := path.Base(child)
 		if num, err := strconv.Atoi(buildStr); err != nil {
 			log.Printf("None int build number found: '%s'", buildStr)
 		} else {
@@ -84,7 +90,7 @@ func (p *PostSubmit) dirOfArtifacts(build int) string {
 
 func (p *PostSubmit) isBuildHealthy(build int) bool {
 	marker := path.Join(p.dirOfArtifacts(build), artifacts.CovProfileCompletionMarker)
-	return p.Client.DoesObjectExist(p.Ctx, p.Bucket, marker)
+	return p.Client.Exists(p.Ctx, p.Bucket, marker)
 }
 
 func (p *PostSubmit) pathToGoodCoverageProfile() string {
@@ -107,5 +113,5 @@ func (p *PostSubmit) searchForLatestHealthyBuild() int {
 func (p *PostSubmit) ProfileReader() *artifacts.ProfileReader {
 	profilePath := p.pathToGoodCoverageProfile()
 	log.Printf("Reading base (master) coverage from <%s>...\n", profilePath)
-	return p.Client.ProfileReader(p.Ctx, p.Bucket, profilePath)
+	return profileReader(p.Ctx, p.Client, p.Bucket, profilePath)
 }
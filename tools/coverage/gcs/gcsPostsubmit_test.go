@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"testing"
 
 	"knative.dev/test-infra/tools/coverage/artifacts/artsTest"
@@ -27,22 +28,27 @@ import (
 	"knative.dev/test-infra/tools/coverage/test"
 )
 
-func testPostSubmit() (p *PostSubmit) {
+// testPostSubmit creates a PostSubmit of the fake job in a local storage, with
+// healthy builds 3, 9, 1 and 5. The returned function removes the storage.
+func testPostSubmit() (p *PostSubmit, cleanup func()) {
 	log.Printf("testPostSubmit() called")
 
-	p = NewPostSubmit(context.Background(), gcsFakes.NewFakeStorageClient(),
+	storage, root := gcsFakes.NewLocalStorage(3, 9, 1, 5)
+	cleanup = func() { os.RemoveAll(root) }
+	p = NewPostSubmit(context.Background(), storage,
 		gcsFakes.FakeGcsBucketName, gcsFakes.FakePostSubmitProwJobName, ArtifactsDirNameOnGcs, artsTest.LocalInputArtsForTest().ProfileName())
-	p.Build = -9
 	return
 }
 
 func TestGetLatestHealthyBuild(t *testing.T) {
-	b := testPostSubmit()
+	b, cleanup := testPostSubmit()
+	defer cleanup()
 	fmt.Printf("latestbuld='%d'\n", b.Build)
 }
 
 func TestPostSubmitProfileReader(t *testing.T) {
-	b := testPostSubmit()
+	b, cleanup := testPostSubmit()
+	defer cleanup()
 	fmt.Printf("latest healthy build='%d'\n", b.Build)
 	if b.ProfileReader() == nil {
 		t.Fatalf("PostSubmit.ProfileReader() is nil")
@@ -50,7 +56,8 @@ func TestPostSubmitProfileReader(t *testing.T) {
 }
 
 func TestListing(t *testing.T) {
-	p := testPostSubmit()
+	p, cleanup := testPostSubmit()
+	defer cleanup()
 	fmt.Printf("Find builds: ")
 	for _, build := range p.listBuilds() {
 		fmt.Printf("%v, ", build)
@@ -59,7 +66,8 @@ func TestListing(t *testing.T) {
 }
 
 func TestSearch(t *testing.T) {
-	p := testPostSubmit()
+	p, cleanup := testPostSubmit()
+	defer cleanup()
 	actual := p.searchForLatestHealthyBuild()
 	t.Logf("latest healthy build = %d\n", actual)
 	expected := 9
@@ -67,7 +75,8 @@ func TestSearch(t *testing.T) {
 }
 
 func TestDirOfArtifacts(t *testing.T) {
-	p := testPostSubmit()
+	p, cleanup := testPostSubmit()
+	defer cleanup()
 	actual := p.dirOfArtifacts(1984)
 	t.Logf("directory of artifacts for build 1984 = %s\n", actual)
 	expected := "logs/post-fakeRepoOwner-fakeRepoName-go-coverage/1984/artifacts"
@@ -77,16 +86,18 @@ func TestDirOfArtifacts(t *testing.T) {
 }
 
 func TestPathToGoodCoverageProfile(t *testing.T) {
-	p := testPostSubmit()
+	p, cleanup := testPostSubmit()
+	defer cleanup()
 	profilePath := p.pathToGoodCoverageProfile()
 	fmt.Printf("path to latest healthy build = %s\n", profilePath)
-	if !p.Client.DoesObjectExist(p.Ctx, p.Bucket, profilePath) {
+	if !p.Client.Exists(p.Ctx, p.Bucket, profilePath) {
 		t.Fatalf("path point to no object: %s", profilePath)
 	}
 }
 
 func TestSearchForLatestHealthyBuildFailure(t *testing.T) {
-	p := testPostSubmit()
+	p, cleanup := testPostSubmit()
+	defer cleanup()
 	p.Bucket = "do-not-exist"
 
 	logFatalSaved := logUtil.LogFatalf
@@ -38,6 +38,9 @@ type PresubmitBuild struct {
 	GcsBuild
 	Artifacts     GcsArtifacts
 	PostSubmitJob string
+	// UploadLineCov uploads line coverage to the artifacts directory in the
+	// storage, which is otherwise uploaded by Prow
+	UploadLineCov bool
 }
 
 type PreSubmit struct {
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// diff.go parses unified diffs into the lines added or changed in each file
+
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader captures the start line and line count of a hunk in the new file,
+// e.g. "@@ -10,2 +12,3 @@ func foo()"
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// LineRange is a range of lines in a file, from Start to End inclusively
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// Contains checks whether the line is in the range
+func (r LineRange) Contains(line int) bool {
+	return line >= r.Start && line <= r.End
+}
+
+// diffParser collects added lines of the hunks fed to it line by line
+type diffParser struct {
+	inHunk  bool
+	newLine int
+	ranges  []LineRange
+}
+
+// parseLine parses a line of a hunk, it returns false if the line is not
+// part of a hunk
+func (p *diffParser) parseLine(line string) bool {
+	if m := hunkHeader.FindStringSubmatch(line); m != nil {
+		p.inHunk = true
+		p.newLine, _ = strconv.Atoi(m[1])
+		return true
+	}
+	if !p.inHunk {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(line, "+"):
+		p.addLine(p.newLine)
+		p.newLine++
+	case strings.HasPrefix(line, " "), line == "":
+		p.newLine++
+	case strings.HasPrefix(line, "-"), strings.HasPrefix(line, `\`):
+		// deleted lines and "\ No newline at end of file" are not in the new file
+	default:
+		p.inHunk = false
+		return false
+	}
+	return true
+}
+
+// addLine adds the line to the ranges, lines must be added in ascending order
+func (p *diffParser) addLine(line int) {
+	if n := len(p.ranges); n > 0 && p.ranges[n-1].End == line-1 {
+		p.ranges[n-1].End = line
+		return
+	}
+	p.ranges = append(p.ranges, LineRange{line, line})
+}
+
+// ParsePatch returns the ranges of lines added or changed in the new version
+// of a file, from the patch of the file as listed in the files of a GitHub
+// pull request
+func ParsePatch(patch string) []LineRange {
+	p := &diffParser{}
+	for _, line := range strings.Split(patch, "\n") {
+		p.parseLine(line)
+	}
+	return p.ranges
+}
+
+// ParseDiff returns the ranges of lines added or changed in each file of the
+// output of git diff, keyed by file path relative to the repository root.
+// Deleted files are not included.
+func ParseDiff(diff string) map[string][]LineRange {
+	files := make(map[string][]LineRange)
+	var file string
+	var p *diffParser
+	for _, line := range strings.Split(diff, "\n") {
+		if p != nil && p.parseLine(line) {
+			continue
+		}
+		if strings.HasPrefix(line, "diff --git ") {
+			if p != nil && len(p.ranges) > 0 {
+				files[file] = p.ranges
+			}
+			file, p = "", nil
+		} else if strings.HasPrefix(line, "+++ ") {
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				file, p = strings.TrimPrefix(path, "b/"), &diffParser{}
+			}
+		}
+	}
+	if p != nil && len(p.ranges) > 0 {
+		files[file] = p.ranges
+	}
+	return files
+}
+
+// ChangedLines returns the ranges of lines changed in the working tree since
+// baseRef in each file, keyed by file path relative to the repository root
+func ChangedLines(baseRef string) (map[string][]LineRange, error) {
+	out, err := exec.Command("git", "diff", "--unified=0", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/", baseRef, "--").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed git diff against %s: %v", baseRef, err)
+	}
+	return ParseDiff(string(out)), nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		patch string
+		want  []LineRange
+	}{
+		{"empty", "", nil},
+		{"new file", "@@ -0,0 +1,3 @@\n+package foo\n+\n+func foo() {}", []LineRange{{1, 3}}},
+		{
+			"changed lines",
+			"@@ -3,5 +3,6 @@ func foo() {\n a := 1\n-b := 2\n+b := 3\n+c := 4\n d := 5\n" +
+				"@@ -20,3 +21,2 @@ func bar() {\n x := 1\n-y := 2\n z := 3\n+w := 4\n\\ No newline at end of file",
+			[]LineRange{{4, 5}, {23, 23}},
+		},
+		{"deleted lines only", "@@ -3,2 +2,0 @@\n-a := 1\n-b := 2", nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePatch(tt.patch); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/pkg/foo.go b/pkg/foo.go
+index 1111111..2222222 100644
+--- a/pkg/foo.go
++++ b/pkg/foo.go
+@@ -10 +10,2 @@ func foo() {
+-	return 1
++	a := 1
++	return a
+diff --git a/pkg/deleted.go b/pkg/deleted.go
+deleted file mode 100644
+--- a/pkg/deleted.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package pkg
+-
+diff --git a/pkg/bar.go b/pkg/bar.go
+new file mode 100644
+--- /dev/null
++++ b/pkg/bar.go
+@@ -0,0 +1,2 @@
++package pkg
+++++ not a header
+`
+	want := map[string][]LineRange{
+		"pkg/foo.go": {{10, 11}},
+		"pkg/bar.go": {{1, 2}},
+	}
+	if got := ParseDiff(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDiff() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// exclusion.go excludes files from coverage by the exclusions of the coverage
+// policy, in addition to git attributes, and by markers in the files themselves
+
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/tools/coverage/glob"
+)
+
+// ignoreMarker excludes a file from coverage if it's in a comment line before
+// the package clause
+const ignoreMarker = "coverage:ignore"
+
+// Exclusions are the rules of a repo excluding files from coverage, set in the
+// exclusions of its coverage policy
+type Exclusions struct {
+	// Files are globs of file paths relative to the repository root. Globs
+	// without "/" match the name of the file or of any of its directories,
+	// e.g. "zz_generated.*.go" or "testing", globs followed by "/..." match
+	// all files in a directory and its subdirectories, e.g. "pkg/mocks/...",
+	// other globs match the whole path.
+	Files []string `yaml:"files"`
+	// BuildTags excludes files whose build constraints require any of the tags
+	BuildTags []string `yaml:"buildTags"`
+	// MainPackages excludes files of main packages
+	MainPackages bool `yaml:"mainPackages"`
+	// IgnoreMarker excludes files with the ignoreMarker comment, true if unset
+	IgnoreMarker *bool `yaml:"ignoreMarker,omitempty"`
+}
+
+// exclusions are the rules applied by IsCoverageSkipped, none if nil
+var exclusions *Exclusions
+
+// SetExclusions sets the rules applied by IsCoverageSkipped, nil removes them
+func SetExclusions(e *Exclusions) {
+	exclusions = e
+}
+
+// Validate checks that all globs and build tags are valid
+func (e *Exclusions) Validate() error {
+	var errs []error
+	for i, g := range e.Files {
+		if err := glob.Validate(g); err != nil {
+			errs = append(errs, fmt.Errorf("file glob #%d: invalid glob '%s': %v", i, g, err))
+		}
+	}
+	for i, tag := range e.BuildTags {
+		if tag == "" || strings.ContainsAny(tag, " !,()&|") {
+			errs = append(errs, fmt.Errorf("build tag #%d: invalid tag '%s'", i, tag))
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// matchesFile checks whether the glob matches the file path. Globs without
+// "/" match the name of the file or of any of its directories, recursive globs
+// match the directory of the file, other globs match the whole path.
+func matchesFile(g, filePath string) bool {
+	switch {
+	case glob.IsRecursive(g):
+		return glob.Match(g, path.Dir(filePath))
+	case !strings.Contains(g, "/"):
+		for _, elem := range strings.Split(filePath, "/") {
+			if glob.Match(g, elem) {
+				return true
+			}
+		}
+		return false
+	default:
+		return glob.Match(g, filePath)
+	}
+}
+
+// fileHeader is what is declared in a Go file before its package clause
+type fileHeader struct {
+	pkg       string
+	buildTags []string // tags required by build constraints, negated tags are omitted
+	ignored   bool     // whether it has the ignore marker
+}
+
+// readHeader reads the comments and package clause of a Go file
+func readHeader(filePath string) (*fileHeader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &fileHeader{}
+	inBlockComment := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case inBlockComment:
+			inBlockComment = !strings.Contains(line, "*/")
+		case strings.HasPrefix(line, "/*"):
+			inBlockComment = !strings.Contains(line[2:], "*/")
+		case strings.HasPrefix(line, "//"):
+			comment := strings.TrimSpace(strings.TrimPrefix(line, "//"))
+			if comment == ignoreMarker {
+				h.ignored = true
+			} else if strings.HasPrefix(line, "// +build ") || strings.HasPrefix(line, "//go:build ") {
+				h.buildTags = append(h.buildTags, constraintTags(comment)...)
+			}
+		case strings.HasPrefix(line, "package "):
+			h.pkg = strings.Fields(line)[1]
+			return h, nil
+		}
+	}
+	return h, scanner.Err()
+}
+
+// constraintTags returns the tags in a build constraint that are not negated,
+// e.g. "e2e" and "linux" for "+build e2e,!race linux"
+func constraintTags(constraint string) []string {
+	var tags []string
+	fields := strings.FieldsFunc(constraint, func(r rune) bool {
+		return strings.ContainsRune(" ,()&|", r)
+	})
+	for _, field := range fields {
+		if field != "+build" && field != "go:build" && !strings.HasPrefix(field, "!") {
+			tags = append(tags, field)
+		}
+	}
+	return tags
+}
+
+// ignoresMarker checks whether files with the ignore marker are excluded
+func (e *Exclusions) ignoresMarker() bool {
+	return e == nil || e.IgnoreMarker == nil || *e.IgnoreMarker
+}
+
+// excludes checks whether the file, with path relative to the repository
+// root, is excluded from coverage by the rules or by the ignore marker, and
+// returns the reason. A nil Exclusions only checks the marker.
+func (e *Exclusions) excludes(filePath string) (string, bool) {
+	if e != nil {
+		for _, g := range e.Files {
+			if matchesFile(g, filePath) {
+				return fmt.Sprintf("it matches '%s'", g), true
+			}
+		}
+	}
+	h, err := readHeader(filePath)
+	if err != nil {
+		// e.g. deleted files, which are not reported anyway
+		return "", false
+	}
+	if h.ignored && e.ignoresMarker() {
+		return "it has the " + ignoreMarker + " marker", true
+	}
+	if e == nil {
+		return "", false
+	}
+	if e.MainPackages && h.pkg == "main" {
+		return "it's in a main package", true
+	}
+	for _, tag := range e.BuildTags {
+		for _, required := range h.buildTags {
+			if tag == required {
+				return fmt.Sprintf("it requires build tag '%s'", tag), true
+			}
+		}
+	}
+	return "", false
+}
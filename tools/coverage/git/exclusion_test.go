@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const license = `/*
+Copyright 2020 The Knative Authors
+package foo
+*/
+
+`
+
+func TestMatchesFile(t *testing.T) {
+	cases := []struct {
+		glob     string
+		filePath string
+		want     bool
+	}{
+		{"zz_generated.*.go", "pkg/apis/zz_generated.deepcopy.go", true},
+		{"zz_generated.*.go", "pkg/apis/types.go", false},
+		{"testing", "pkg/reconciler/testing/factory.go", true},
+		{"testing", "pkg/testing.go", false},
+		{"pkg/mocks/...", "pkg/mocks/sub/mock.go", true},
+		{"pkg/*/...", "pkg/mocks/mock.go", true},
+		{"pkg/mocks/...", "pkg/mocks.go", false},
+		{"...", "main.go", true},
+		{"cmd/*/main.go", "cmd/controller/main.go", true},
+		{"cmd/*/main.go", "cmd/controller/sub/main.go", false},
+	}
+	for _, tt := range cases {
+		if got := matchesFile(tt.glob, tt.filePath); got != tt.want {
+			t.Errorf("matchesFile(%q, %q) = %v, want %v", tt.glob, tt.filePath, got, tt.want)
+		}
+	}
+}
+
+func TestConstraintTags(t *testing.T) {
+	cases := []struct {
+		constraint string
+		want       []string
+	}{
+		{"+build e2e", []string{"e2e"}},
+		{"+build e2e,!race linux", []string{"e2e", "linux"}},
+		{"go:build (e2e || performance) && !race", []string{"e2e", "performance"}},
+		{"+build !e2e", nil},
+	}
+	for _, tt := range cases {
+		if got := constraintTags(tt.constraint); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("constraintTags(%q) = %v, want %v", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestExcludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exclusions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, contents string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	plain := write("plain.go", license+"package foo\n\n// coverage:ignore is only a marker before the package clause\n")
+	ignored := write("ignored.go", license+"// coverage:ignore\n\npackage foo\n")
+	e2e := write("e2e.go", license+"// +build e2e\n\npackage foo\n")
+	notE2e := write("not_e2e.go", license+"// +build !e2e\n\npackage foo\n")
+	mainPkg := write("main.go", license+"package main\n")
+	mock := write("mock_client.go", license+"package foo\n")
+
+	e := &Exclusions{Files: []string{"mock_*.go"}, BuildTags: []string{"e2e"}, MainPackages: true}
+	disabled := false
+	cases := []struct {
+		name       string
+		exclusions *Exclusions
+		filePath   string
+		want       bool
+	}{
+		{"not excluded", e, plain, false},
+		{"marker", e, ignored, true},
+		{"marker without exclusions", nil, ignored, true},
+		{"marker disabled", &Exclusions{IgnoreMarker: &disabled}, ignored, false},
+		{"build tag", e, e2e, true},
+		{"negated build tag", e, notE2e, false},
+		{"main package", e, mainPkg, true},
+		{"main package without exclusions", nil, mainPkg, false},
+		{"glob", e, mock, true},
+		{"missing file", e, filepath.Join(dir, "missing.go"), false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason, got := tt.exclusions.excludes(tt.filePath); got != tt.want {
+				t.Errorf("excludes(%s) = %v (%s), want %v", tt.filePath, got, reason, tt.want)
+			}
+		})
+	}
+}
@@ -43,6 +43,8 @@ func hasGitAttr(attr string, fileName string) bool {
 	return strings.HasSuffix(cleaned, "true") || strings.HasSuffix(cleaned, "set")
 }
 
+// IsCoverageSkipped checks whether the file is excluded from coverage by git
+// attributes, by the exclusions set with SetExclusions, or by its ignore marker
 func IsCoverageSkipped(filePath string) bool {
 	if hasGitAttr(gitAttrLinguistGenerated, filePath) {
 		log.Println("Skipping as file is linguist-generated: ", filePath)
@@ -50,6 +52,9 @@ func IsCoverageSkipped(filePath string) bool {
 	} else if hasGitAttr(gitAttrCoverageExcluded, filePath) {
 		log.Println("Skipping as file is coverage-excluded: ", filePath)
 		return true
+	} else if reason, excluded := exclusions.excludes(filePath); excluded {
+		log.Printf("Skipping as %s: %s\n", reason, filePath)
+		return true
 	}
 	return false
 }
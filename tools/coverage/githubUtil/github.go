@@ -18,6 +18,7 @@ package githubUtil
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
@@ -43,25 +44,47 @@ func FilePathProfileToGithub(file string) string {
 	return result
 }
 
+// DirPathProfileToGithub converts a directory path from profile format to github
+// format, the repository root being "."
+func DirPathProfileToGithub(dir string) string {
+	// convert a path in the directory, as the repository root itself is not
+	// prefixed by the repository path
+	return path.Dir(FilePathProfileToGithub(dir + "/"))
+}
+
 // use var for the following function so that it can be mocked in the unit test
 var getRepoRoot = func() (string, error) {
 	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
 	return string(out), err
 }
 
-// GetRepoPath gets repository path relative to GOPATH/src
+// GetRepoPath gets repository path relative to GOPATH/src, or the module path
+// of the repository if it's not in GOPATH
 func GetRepoPath() (string, error) {
 	repoRoot, err := getRepoRoot()
 	if err != nil {
 		return "", fmt.Errorf("failed git rev-parse --show-toplevel: '%v'", err)
 	}
-	gopath := os.Getenv("GOPATH")
-	if gopath == "" {
-		return "", errors.New("GOPATH is empty")
+	repoRoot = strings.TrimSpace(repoRoot)
+	if gopath := os.Getenv("GOPATH"); gopath != "" {
+		relPath, err := filepath.Rel(path.Join(gopath, "src"), repoRoot)
+		if err == nil && !strings.HasPrefix(relPath, "..") {
+			return relPath, nil
+		}
 	}
-	relPath, err := filepath.Rel(path.Join(gopath, "src"), string(repoRoot))
+	return modulePath(repoRoot)
+}
+
+// modulePath reads the module path from the go.mod file in the repository root
+func modulePath(repoRoot string) (string, error) {
+	contents, err := ioutil.ReadFile(path.Join(repoRoot, "go.mod"))
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("repo (%s) is neither in GOPATH nor a go module: %v", repoRoot, err)
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
 	}
-	return strings.TrimSpace(relPath), nil
+	return "", errors.New("no module path in go.mod of repo " + repoRoot)
 }
@@ -26,10 +26,11 @@ import (
 type GithubClient struct {
 	Issues       Issues
 	PullRequests PullRequests
+	Checks       Checks
 }
 
-func New(issues Issues, pullRequests PullRequests) *GithubClient {
-	return &GithubClient{issues, pullRequests}
+func New(issues Issues, pullRequests PullRequests, checks Checks) *GithubClient {
+	return &GithubClient{issues, pullRequests, checks}
 }
 
 // Get the github client
@@ -43,7 +44,7 @@ func Make(ctx context.Context, githubToken string) *GithubClient {
 	tc := oauth2.NewClient(ctx, ts)
 	client := github.NewClient(tc)
 
-	return New(client.Issues, client.PullRequests)
+	return New(client.Issues, client.PullRequests, client.Checks)
 }
 
 type Issues interface {
@@ -59,3 +60,12 @@ type PullRequests interface {
 	ListFiles(ctx context.Context, owner string, repo string, number int, opt *github.ListOptions) (
 		[]*github.CommitFile, *github.Response, error)
 }
+
+// Checks creates check runs, which requires the token to be of a GitHub App
+// with the checks:write permission
+type Checks interface {
+	CreateCheckRun(ctx context.Context, owner string, repo string, opt github.CreateCheckRunOptions) (
+		*github.CheckRun, *github.Response, error)
+	UpdateCheckRun(ctx context.Context, owner string, repo string, checkRunID int64,
+		opt github.UpdateCheckRunOptions) (*github.CheckRun, *github.Response, error)
+}
@@ -27,7 +27,7 @@ import (
 )
 
 func FakeGithubClient() *githubClient.GithubClient {
-	return githubClient.New(fakeGithubIssues(), fakePullRequests())
+	return githubClient.New(fakeGithubIssues(), fakePullRequests(), &FakeGithubChecks{})
 }
 
 func testCommitFile(filename string) *github.CommitFile {
@@ -37,14 +37,20 @@ func testCommitFile(filename string) *github.CommitFile {
 	}
 }
 
+func testCommitFileWithPatch(filename, patch string) *github.CommitFile {
+	f := testCommitFile(filename)
+	f.Patch = &patch
+	return f
+}
+
 func testCommitFiles() (res []*github.CommitFile) {
 	return []*github.CommitFile{
 		testCommitFile("onlySrcChange.go"),
-		testCommitFile("onlyTestChange_test.go"),
+		testCommitFileWithPatch("onlyTestChange_test.go", "@@ -5,3 +5,4 @@ func TestFoo() {\n a := 1\n-b := 2\n+b := 3\n+c := 4\n d := 5"),
 		testCommitFile("common.go"),
 		testCommitFile("cov-excl.go"),
 		testCommitFile("ling-gen_test.go"),
-		testCommitFile("newlyAddedFile.go"),
+		testCommitFileWithPatch("newlyAddedFile.go", "@@ -0,0 +1,3 @@\n+package presubmit\n+\n+func newFunc() {}"),
 		testCommitFile("newlyAddedFile_test.go"),
 	}
 }
@@ -57,6 +63,18 @@ type FakeGithubPullRequests struct {
 	githubClient.PullRequests
 }
 
+// FakeGithubChecks records the check runs created, and the annotations added
+// to them
+type FakeGithubChecks struct {
+	githubClient.Checks
+	// Err is returned by CreateCheckRun if set, e.g. for missing permissions
+	Err error
+	// UpdateErr is returned by UpdateCheckRun if set
+	UpdateErr   error
+	CheckRuns   []github.CreateCheckRunOptions
+	Annotations []*github.CheckRunAnnotation
+}
+
 func fakeGithubIssues() githubClient.Issues {
 	return &FakeGithubIssues{}
 }
@@ -99,6 +117,29 @@ func (pr *FakeGithubPullRequests) ListFiles(ctx context.Context, owner string, r
 
 }
 
+func (checks *FakeGithubChecks) CreateCheckRun(ctx context.Context, owner string, repo string,
+	opt github.CreateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	log.Printf("FakeGithubChecks.CreateCheckRun(Ctx, owner=%s, repo=%s, opt.HeadSHA=%s) called\n",
+		owner, repo, opt.HeadSHA)
+	if checks.Err != nil {
+		return nil, nil, checks.Err
+	}
+	checks.CheckRuns = append(checks.CheckRuns, opt)
+	checks.Annotations = append(checks.Annotations, opt.Output.Annotations...)
+	return &github.CheckRun{ID: github.Int64(int64(len(checks.CheckRuns)))}, nil, nil
+}
+
+func (checks *FakeGithubChecks) UpdateCheckRun(ctx context.Context, owner string, repo string, checkRunID int64,
+	opt github.UpdateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	log.Printf("FakeGithubChecks.UpdateCheckRun(Ctx, owner=%s, repo=%s, checkRunID=%d) called\n",
+		owner, repo, checkRunID)
+	if checks.UpdateErr != nil {
+		return nil, nil, checks.UpdateErr
+	}
+	checks.Annotations = append(checks.Annotations, opt.Output.Annotations...)
+	return &github.CheckRun{ID: github.Int64(checkRunID)}, nil, nil
+}
+
 func FakeRepoData() *githubPr.GithubPr {
 	ctx := context.Background()
 	log.Printf("creating fake repo data \n")
@@ -107,6 +148,7 @@ func FakeRepoData() *githubPr.GithubPr {
 		RepoOwner:     "fakeRepoOwner",
 		RepoName:      "fakeRepoName",
 		Pr:            7,
+		HeadSha:       "fakeHeadSha",
 		RobotUserName: "fakeCovbot",
 		GithubClient:  FakeGithubClient(),
 		Ctx:           ctx,
@@ -35,13 +35,10 @@ func sourceFilePath(path string) string {
 	return path
 }
 
-// Get the list of files in a commit, excluding those to be ignored by coverage
-func GetConcernedFiles(data *githubPr.GithubPr, filePathPrefix string) map[string]bool {
+// listCommitFiles lists all the files changed in the pull request
+func listCommitFiles(data *githubPr.GithubPr) []*github.CommitFile {
 	listOptions := &github.ListOptions{Page: 1}
 
-	fmt.Println()
-	log.Printf("GetConcernedFiles(...) started\n")
-
 	commitFiles := make([]*github.CommitFile, 0)
 	for {
 		files, rsp, err := data.GithubClient.PullRequests.ListFiles(data.Ctx, data.RepoOwner, data.RepoName,
@@ -58,9 +55,16 @@ func GetConcernedFiles(data *githubPr.GithubPr, filePathPrefix string) map[strin
 		}
 		listOptions.Page = rsp.NextPage
 	}
+	return commitFiles
+}
+
+// Get the list of files in a commit, excluding those to be ignored by coverage
+func GetConcernedFiles(data *githubPr.GithubPr, filePathPrefix string) map[string]bool {
+	fmt.Println()
+	log.Printf("GetConcernedFiles(...) started\n")
 
 	fileNames := make(map[string]bool)
-	for i, commitFile := range commitFiles {
+	for i, commitFile := range listCommitFiles(data) {
 		filePath := path.Join(filePathPrefix, sourceFilePath(*commitFile.Filename))
 		isFileConcerned := !git.IsCoverageSkipped(filePath)
 		log.Printf("github file #%d: %s, concerned=%v\n", i, filePath, isFileConcerned)
@@ -70,3 +74,21 @@ func GetConcernedFiles(data *githubPr.GithubPr, filePathPrefix string) map[strin
 	log.Printf("GetConcernedFiles(...) completed\n\n")
 	return fileNames
 }
+
+// GetChangedLines gets the ranges of lines added or changed in each file of the pull request,
+// parsed from the patches listed by github. Github omits the patches of large files, no lines
+// are reported for them.
+func GetChangedLines(data *githubPr.GithubPr, filePathPrefix string) map[string][]git.LineRange {
+	changedLines := make(map[string][]git.LineRange)
+	for _, commitFile := range listCommitFiles(data) {
+		filePath := path.Join(filePathPrefix, commitFile.GetFilename())
+		if commitFile.Patch == nil {
+			log.Printf("github file %s has no patch, skipping for patch coverage\n", filePath)
+			continue
+		}
+		if ranges := git.ParsePatch(commitFile.GetPatch()); len(ranges) > 0 {
+			changedLines[filePath] = ranges
+		}
+	}
+	return changedLines
+}
@@ -17,10 +17,12 @@ package githubUtil
 
 import (
 	"path"
+	"reflect"
 	"testing"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 
+	"knative.dev/test-infra/tools/coverage/git"
 	"knative.dev/test-infra/tools/coverage/githubUtil/githubFakes"
 	"knative.dev/test-infra/tools/coverage/test"
 )
@@ -48,6 +50,18 @@ func TestGetConcernedFiles(t *testing.T) {
 	}
 }
 
+func TestGetChangedLines(t *testing.T) {
+	data := githubFakes.FakeRepoData()
+	actual := GetChangedLines(data, "")
+	expected := map[string][]git.LineRange{
+		path.Join(test.CovTargetRelPath, "onlyTestChange_test.go"): {{Start: 6, End: 7}},
+		path.Join(test.CovTargetRelPath, "newlyAddedFile.go"):      {{Start: 1, End: 3}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changed lines: expected=%v; actual=%v\n", expected, actual)
+	}
+}
+
 func TestSourceFilePath(t *testing.T) {
 	input := "pkg/fake_test.go"
 	actual := sourceFilePath(input)
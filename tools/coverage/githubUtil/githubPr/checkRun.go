@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// checkRun.go reports coverage as a check run on the head commit of the pull
+// request, with annotations on lines
+
+package githubPr
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+)
+
+const (
+	// CheckRunName is the name of the check run reporting coverage
+	CheckRunName = "coverage"
+	// maxAnnotations is the maximum number of annotations github accepts in
+	// a request, more annotations are added by updating the check run
+	maxAnnotations = 50
+	// maxSummaryLength is the maximum length of the summary of a check run
+	maxSummaryLength = 65535
+)
+
+// Annotation annotates lines of a file in the check run
+type Annotation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Message   string
+}
+
+func (a Annotation) toGithub() *github.CheckRunAnnotation {
+	return &github.CheckRunAnnotation{
+		Path:            github.String(a.Path),
+		StartLine:       github.Int(a.StartLine),
+		EndLine:         github.Int(a.EndLine),
+		AnnotationLevel: github.String("warning"),
+		Message:         github.String(a.Message),
+	}
+}
+
+// CreateCheckRun creates a completed check run on the head commit with the
+// title and summary, failed if coverage is low. Annotations are added in
+// batches, as github limits the number of annotations in a request. Only
+// failing to create the check run returns an error: the check run is kept if
+// adding later batches fails, so that the report isn't also posted as a comment.
+func (data *GithubPr) CreateCheckRun(title, summary string, failed bool, annotations []Annotation) error {
+	if data.HeadSha == "" {
+		return fmt.Errorf("head commit of PR %d is unknown", data.Pr)
+	}
+	if len(summary) > maxSummaryLength {
+		summary = summary[:maxSummaryLength]
+	}
+	conclusion := "success"
+	if failed {
+		conclusion = "failure"
+	}
+	output := func(batch []Annotation) *github.CheckRunOutput {
+		o := &github.CheckRunOutput{Title: github.String(title), Summary: github.String(summary)}
+		for _, a := range batch {
+			o.Annotations = append(o.Annotations, a.toGithub())
+		}
+		return o
+	}
+	batch := func() []Annotation {
+		n := len(annotations)
+		if n > maxAnnotations {
+			n = maxAnnotations
+		}
+		b := annotations[:n]
+		annotations = annotations[n:]
+		return b
+	}
+
+	log.Printf("client.Checks.CreateCheckRun(Ctx, repoOwner=%s, RepoName=%s, headSha=%s, conclusion=%s)\n",
+		data.RepoOwner, data.RepoName, data.HeadSha, conclusion)
+	run, _, err := data.GithubClient.Checks.CreateCheckRun(data.Ctx, data.RepoOwner, data.RepoName,
+		github.CreateCheckRunOptions{
+			Name:        CheckRunName,
+			HeadSHA:     data.HeadSha,
+			Status:      github.String("completed"),
+			Conclusion:  github.String(conclusion),
+			CompletedAt: &github.Timestamp{Time: time.Now()},
+			Output:      output(batch()),
+		})
+	if err != nil {
+		return fmt.Errorf("failed creating check run on %s: %v", data.HeadSha, err)
+	}
+	for len(annotations) > 0 {
+		remaining := len(annotations)
+		if _, _, err := data.GithubClient.Checks.UpdateCheckRun(data.Ctx, data.RepoOwner, data.RepoName,
+			run.GetID(), github.UpdateCheckRunOptions{
+				Name:   CheckRunName,
+				Output: output(batch()),
+			}); err != nil {
+			log.Printf("Failed adding annotations to check run %d, %d annotations dropped: %v",
+				run.GetID(), remaining, err)
+			break
+		}
+	}
+	return nil
+}
@@ -32,6 +32,7 @@ type GithubPr struct {
 	RepoOwner     string
 	RepoName      string
 	Pr            int
+	HeadSha       string
 	Ctx           context.Context
 	GithubClient  *githubClient.GithubClient
 }
@@ -40,7 +41,7 @@ func (data *GithubPr) PrStr() string {
 	return strconv.Itoa(data.Pr)
 }
 
-func New(githubTokenLocation, repoOwner, repoName, prNumStr,
+func New(githubTokenLocation, repoOwner, repoName, prNumStr, headSha,
 	botUserName string) *GithubPr {
 	ctx := context.Background()
 
@@ -67,6 +68,7 @@ func New(githubTokenLocation, repoOwner, repoName, prNumStr,
 		RepoOwner:     repoOwner,
 		RepoName:      repoName,
 		Pr:            prNum,
+		HeadSha:       headSha,
 		RobotUserName: botUserName,
 		GithubClient:  client,
 		Ctx:           ctx}
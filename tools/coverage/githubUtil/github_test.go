@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package githubUtil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestGetRepoPath(t *testing.T) {
+	gopath, err := ioutil.TempDir("", "gopath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gopath)
+	moduleRoot := path.Join(gopath, "module")
+	os.MkdirAll(moduleRoot, 0755)
+	ioutil.WriteFile(path.Join(moduleRoot, "go.mod"), []byte("module knative.dev/fake\n\ngo 1.14\n"), 0644)
+
+	oldGetRepoRoot, oldGopath := getRepoRoot, os.Getenv("GOPATH")
+	defer func() {
+		getRepoRoot = oldGetRepoRoot
+		os.Setenv("GOPATH", oldGopath)
+	}()
+	os.Setenv("GOPATH", gopath)
+
+	cases := []struct {
+		repoRoot string
+		expected string
+		isErr    bool
+	}{
+		{path.Join(gopath, "src", "knative.dev", "serving") + "\n", "knative.dev/serving", false},
+		{moduleRoot + "\n", "knative.dev/fake", false},
+		{path.Join(gopath, "notmodule"), "", true},
+	}
+	for _, tt := range cases {
+		getRepoRoot = func() (string, error) { return tt.repoRoot, nil }
+		actual, err := GetRepoPath()
+		if (err != nil) != tt.isErr || actual != tt.expected {
+			t.Errorf("GetRepoPath() with repo root %q = %q, %v; expected %q", tt.repoRoot, actual, err, tt.expected)
+		}
+	}
+}
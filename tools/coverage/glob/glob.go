@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package glob matches paths relative to the repository root against the globs
+// of the coverage policy, shared by directory rules and file exclusions
+package glob
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+const (
+	// All matches all paths
+	All = "..."
+	// recursiveSuffix follows a glob matching a directory and all its subdirectories
+	recursiveSuffix = "/..."
+)
+
+// Validate checks that the glob is not empty and has a valid pattern
+func Validate(glob string) error {
+	if glob == "" {
+		return errors.New("empty glob")
+	}
+	_, err := path.Match(strings.TrimSuffix(glob, recursiveSuffix), "")
+	return err
+}
+
+// IsRecursive checks whether the glob matches all subdirectories of the
+// directories it matches
+func IsRecursive(glob string) bool {
+	return glob == All || strings.HasSuffix(glob, recursiveSuffix)
+}
+
+// Match checks whether the glob matches p. "..." matches all paths, a glob
+// followed by "/..." matches paths whose leading elements match the glob, e.g.
+// "third_party/..." matches "third_party" and "third_party/foo/bar", other globs
+// are matched against the whole path.
+func Match(glob, p string) bool {
+	if glob == All {
+		return true
+	}
+	if !strings.HasSuffix(glob, recursiveSuffix) {
+		matched, _ := path.Match(glob, p)
+		return matched
+	}
+	glob = strings.TrimSuffix(glob, recursiveSuffix)
+	elems := strings.Split(p, "/")
+	n := len(strings.Split(glob, "/"))
+	if len(elems) < n {
+		return false
+	}
+	matched, _ := path.Match(glob, strings.Join(elems[:n], "/"))
+	return matched
+}
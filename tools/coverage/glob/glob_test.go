@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		glob string
+		p    string
+		want bool
+	}{
+		{"...", ".", true},
+		{"...", "pkg/apis", true},
+		{"pkg/apis/*", "pkg/apis/serving", true},
+		{"pkg/apis/*", "pkg/apis/serving/v1", false},
+		{"pkg/apis/*", "pkg/apis", false},
+		{"third_party/...", "third_party", true},
+		{"third_party/...", "third_party/foo/bar", true},
+		{"third_party/...", "third_party_test", false},
+		{"cmd/*/...", "cmd/controller/sub", true},
+		{"cmd/*/...", "cmd", false},
+		{"cmd/*/main.go", "cmd/controller/main.go", true},
+	}
+	for _, tt := range cases {
+		if got := Match(tt.glob, tt.p); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.glob, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, glob := range []string{"...", "pkg/*", "third_party/...", "zz_generated.*.go"} {
+		if err := Validate(glob); err != nil {
+			t.Errorf("Validate(%q) failed: %v", glob, err)
+		}
+	}
+	for _, glob := range []string{"", "pkg/[", "[a/..."} {
+		if err := Validate(glob); err == nil {
+			t.Errorf("Validate(%q) expected error", glob)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// local.go runs the pre-submit coverage report on local profiles, without the
+// Prow environment, GCS or GitHub, so that developers can reproduce the
+// numbers posted by the coverage bot
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/policy"
+)
+
+const localCommand = "local"
+
+// localOptions are the options of the local command
+type localOptions struct {
+	baseProfile   string
+	newProfile    string
+	baseRef       string
+	covTarget     string
+	artifactsDir  string
+	covThreshold  int
+	thresholdMode string
+	covPolicy     string
+}
+
+func parseLocalOptions(args []string) (*localOptions, error) {
+	o := &localOptions{}
+	fs := flag.NewFlagSet(localCommand, flag.ContinueOnError)
+	fs.StringVar(&o.baseProfile, "base-profile", "", "coverage profile of the base, "+
+		"produced by running tests at --base-ref if empty")
+	fs.StringVar(&o.newProfile, "new-profile", "", "coverage profile of the change, "+
+		"produced by running tests in the working tree if empty")
+	fs.StringVar(&o.baseRef, "base-ref", "", "git ref of the base, for producing the base profile and "+
+		"for patch coverage of the lines changed since")
+	fs.StringVar(&o.covTarget, "cov-target", defaultCoverageTargetDir, "target directory for test coverage")
+	fs.StringVar(&o.artifactsDir, "artifacts", "./artifacts/local/", "directory for profiles produced by running tests")
+	fs.IntVar(&o.covThreshold, "cov-threshold-percentage", defaultCovThreshold, "coverage threshold in percentage")
+	fs.StringVar(&o.thresholdMode, "threshold-mode", thresholdModeFile, "what the coverage threshold applies to, "+
+		"one of 'file' (each changed file), 'patch' (all changed lines) or 'both'")
+	fs.StringVar(&o.covPolicy, "cov-policy", "", "path to the coverage policy file, "+
+		"setting thresholds and exclusions of directories, and excluding files by glob, build tag, package or marker")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if o.baseProfile == "" && o.baseRef == "" {
+		return nil, fmt.Errorf("one of --base-profile and --base-ref must be set")
+	}
+	if err := validateThresholdMode(o.thresholdMode); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// profileArtifacts returns the artifacts holding the given profile, the
+// profile is produced in the artifacts directory if it's not given
+func profileArtifacts(profile, dir string) (*artifacts.LocalArtifacts, bool) {
+	if profile != "" {
+		return artifacts.NewLocalArtifacts(filepath.Dir(profile), filepath.Base(profile),
+			keyCovProfileFileName, defaultStdoutRedirect), false
+	}
+	return artifacts.NewLocalArtifacts(dir, defaultCoverageProfileName,
+		keyCovProfileFileName, defaultStdoutRedirect), true
+}
+
+// repoRoot returns the root directory of the git repository of the working
+// directory
+func repoRoot() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed git rev-parse --show-toplevel: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// produceBaseProfile produces the base profile by running tests in a
+// temporary git worktree checked out at baseRef
+func produceBaseProfile(baseRef, covTarget string, arts *artifacts.LocalArtifacts) error {
+	repoRoot, err := repoRoot()
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// cov-target is relative to the working directory, which has the same
+	// relative path in the worktree
+	relDir, err := filepath.Rel(repoRoot, wd)
+	if err != nil {
+		return err
+	}
+
+	worktree, err := ioutil.TempDir("", "coverage-base")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(worktree)
+	if out, err := exec.Command("git", "worktree", "add", "--detach", worktree, baseRef).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed creating worktree at %s: %v: %s", baseRef, err, out)
+	}
+	defer exec.Command("git", "worktree", "remove", "--force", worktree).Run()
+
+	if err := os.Chdir(filepath.Join(worktree, relDir)); err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+	log.Printf("Producing base profile at %s\n", baseRef)
+	arts.ProduceProfileFile(covTarget)
+	return nil
+}
+
+// runLocal compares two local coverage profiles and prints the same report
+// posted by the coverage bot in pre-submit. It returns whether coverage is
+// below the threshold.
+func runLocal(o *localOptions) (bool, error) {
+	var pol *policy.Policy
+	if o.covPolicy != "" {
+		var err error
+		if pol, err = policy.Load(o.covPolicy); err != nil {
+			return false, err
+		}
+		git.SetExclusions(pol.Exclusions)
+		defer git.SetExclusions(nil)
+	}
+	artifactsDir, err := filepath.Abs(o.artifactsDir)
+	if err != nil {
+		return false, err
+	}
+	baseArts, produceBase := profileArtifacts(o.baseProfile, filepath.Join(artifactsDir, "base"))
+	newArts, produceNew := profileArtifacts(o.newProfile, filepath.Join(artifactsDir, "new"))
+	if produceBase {
+		if err := produceBaseProfile(o.baseRef, o.covTarget, baseArts); err != nil {
+			return false, err
+		}
+	}
+	if produceNew {
+		log.Println("Producing new profile in the working tree")
+		newArts.ProduceProfileFile(o.covTarget)
+	}
+
+	gBase := pol.Apply(calc.CovList(baseArts.ProfileReader(), nil, nil, o.covThreshold))
+	gNew := pol.Apply(calc.CovList(newArts.ProfileReader(), nil, nil, o.covThreshold))
+	changes := calc.NewGroupChanges(gBase, gNew)
+	report, _, isFileCoverageLow := changes.ContentForGithubPost(nil)
+	packagesContent, isPackageCoverageLow := gNew.PackagesContentForGithubPost(nil)
+	if packagesContent != "" {
+		report += "\n" + packagesContent
+	}
+	// package thresholds are only enforced if set by a coverage policy
+	isFileCoverageLow = isFileCoverageLow || (pol != nil && isPackageCoverageLow)
+
+	isPatchCoverageLow := false
+	if o.baseRef != "" {
+		changedLines, err := git.ChangedLines(o.baseRef)
+		if err != nil {
+			return false, err
+		}
+		changedLines = concernedChangedLines(changedLines, nil)
+		patch := pol.Apply(calc.PatchCovList(newArts.ProfileReader(), changedLines, o.covThreshold))
+		if patchContent := calc.PatchContentForGithubPost(patch); patchContent != "" {
+			report += "\n" + patchContent
+		}
+		isPatchCoverageLow = patch.Coverage.IsCoverageLow(o.covThreshold)
+
+		if root, err := repoRoot(); err != nil {
+			log.Printf("Cannot find the repo root, skipping function coverage: %v", err)
+		} else {
+			funcs := calc.FuncCovList(newArts.ProfileReader(), root, gNew)
+			if funcsContent := calc.FuncsContentForGithubPost(funcs, changedLines); funcsContent != "" {
+				report += "\n" + funcsContent
+			}
+		}
+	}
+
+	fmt.Printf("\n%s\n", report)
+	return isCoverageLow(o.thresholdMode, isFileCoverageLow, isPatchCoverageLow), nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const (
+	localBaseProfile = `mode: count
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:5.20,8.2 2 1
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:10.20,12.2 1 0
+`
+	localNewProfile = `mode: count
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:5.20,8.2 2 0
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:10.20,12.2 1 1
+`
+)
+
+func TestParseLocalOptions(t *testing.T) {
+	cases := []struct {
+		args  []string
+		isErr bool
+	}{
+		{[]string{"--base-profile", "base.txt", "--new-profile", "new.txt"}, false},
+		{[]string{"--base-ref", "master", "--threshold-mode", "patch"}, false},
+		{[]string{"--new-profile", "new.txt"}, true},
+		{[]string{"--base-ref", "master", "--threshold-mode", "lines"}, true},
+	}
+	for _, tt := range cases {
+		if _, err := parseLocalOptions(tt.args); (err != nil) != tt.isErr {
+			t.Errorf("parseLocalOptions(%v) error = %v, expected error: %v", tt.args, err, tt.isErr)
+		}
+	}
+}
+
+func TestRunLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	basePath, newPath := path.Join(dir, "base.txt"), path.Join(dir, "new.txt")
+	ioutil.WriteFile(basePath, []byte(localBaseProfile), 0644)
+	ioutil.WriteFile(newPath, []byte(localNewProfile), 0644)
+	lowerPolicy, excludePolicy := path.Join(dir, "lower.yaml"), path.Join(dir, "exclude.yaml")
+	ioutil.WriteFile(lowerPolicy, []byte("rules:\n- path: tools/coverage/testTarget/...\n  threshold: 30\n"), 0644)
+	ioutil.WriteFile(excludePolicy, []byte("rules:\n- path: tools/...\n  exclude: true\n"), 0644)
+
+	cases := []struct {
+		threshold int
+		covPolicy string
+		expected  bool
+	}{
+		{50, "", true},
+		{30, "", false},
+		{50, lowerPolicy, false},
+		{50, excludePolicy, false},
+	}
+	for _, tt := range cases {
+		o := &localOptions{
+			baseProfile:   basePath,
+			newProfile:    newPath,
+			artifactsDir:  dir,
+			covThreshold:  tt.threshold,
+			thresholdMode: thresholdModeFile,
+			covPolicy:     tt.covPolicy,
+		}
+		actual, err := runLocal(o)
+		if err != nil {
+			t.Fatalf("runLocal() failed: %v", err)
+		}
+		if actual != tt.expected {
+			t.Errorf("runLocal() with threshold %d and policy %q = %v, expected %v",
+				tt.threshold, tt.covPolicy, actual, tt.expected)
+		}
+	}
+}
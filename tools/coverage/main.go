@@ -24,8 +24,10 @@ import (
 
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/gcs"
+	"knative.dev/test-infra/tools/coverage/git"
 	"knative.dev/test-infra/tools/coverage/githubUtil/githubPr"
 	"knative.dev/test-infra/tools/coverage/logUtil"
+	"knative.dev/test-infra/tools/coverage/policy"
 	"knative.dev/test-infra/tools/coverage/testgrid"
 )
 
@@ -41,6 +43,31 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == localCommand {
+		o, err := parseLocalOptions(os.Args[2:])
+		if err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
+		isCoverageLow, err := runLocal(o)
+		if err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
+		if isCoverageLow {
+			logUtil.LogFatalf("Code coverage is below threshold (%d%%)", o.covThreshold)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == trendCommand {
+		o, err := parseTrendOptions(os.Args[2:])
+		if err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
+		if err := runTrend(o); err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
+		return
+	}
+
 	fmt.Println("entering code coverage main")
 
 	envOverriddenDefaultArtifactsDir := os.Getenv("ARTIFACTS")
@@ -56,13 +83,48 @@ func main() {
 	githubTokenPath := flag.String("github-token", "", "path to token to access github repo")
 	covThreshold := flag.Int("cov-threshold-percentage", defaultCovThreshold, "token to access GitHub repo")
 	postingBotUserName := flag.String("posting-robot", "knative-metrics-robot", "github user name for coverage robot")
+	thresholdMode := flag.String("threshold-mode", thresholdModeFile, "what the coverage threshold applies to in presubmit, "+
+		"one of 'file' (each changed file), 'patch' (all changed lines) or 'both'")
+	reportMode := flag.String("report-mode", reportModeComment, "how the presubmit report is posted, "+
+		"one of 'comment' (a PR comment) or 'check-run' (a check run annotating uncovered changed lines, "+
+		"posting a comment instead if the check run cannot be created)")
+	covPolicyPath := flag.String("cov-policy", "", "path to the coverage policy file, "+
+		"setting thresholds and exclusions of directories, and excluding files by glob, build tag, package or marker")
+	mergeProfilePaths := flag.String("merge-profiles", "", "comma separated paths to coverage profiles "+
+		"of other test runs, merged into the profile produced")
+	mergeGcsJobs := flag.String("merge-gcs-jobs", "", "comma separated names of prow jobs, whose latest "+
+		"healthy coverage profiles in the gcs bucket are merged into the profile produced")
+	localStorage := flag.String("local-storage", "", "root directory of a local filesystem storage used instead of "+
+		"GCS, each bucket being a subdirectory")
+	uploadLineCov := flag.Bool("upload-line-cov", false, "upload line coverage to the storage in presubmit, "+
+		"for runs whose artifacts directory is not uploaded by Prow")
+	trendOpts := &trendOptions{}
+	addTrendFlags(flag.CommandLine, trendOpts, "coverage history where post-submit records package coverage, "+
+		"either a local file or a GCS directory in the format of gs://bucket/dir")
 	flag.Parse()
 
+	if err := validateThresholdMode(*thresholdMode); err != nil {
+		logUtil.LogFatalf("%v", err)
+	}
+	if err := validateReportMode(*reportMode); err != nil {
+		logUtil.LogFatalf("%v", err)
+	}
+	var covPolicy *policy.Policy
+	if *covPolicyPath != "" {
+		var err error
+		if covPolicy, err = policy.Load(*covPolicyPath); err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
+		git.SetExclusions(covPolicy.Exclusions)
+	}
+
 	log.Printf("container flag list: postsubmit-gcs-bucket=%s; postSubmitJobName=%s; "+
 		"artifacts=%s; cov-target=%s; profile-name=%s; github-token=%s; "+
-		"cov-threshold-percentage=%d; posting-robot=%s;",
+		"cov-threshold-percentage=%d; posting-robot=%s; threshold-mode=%s; report-mode=%s; cov-policy=%s; "+
+		"merge-profiles=%s; merge-gcs-jobs=%s; trend-store=%s; local-storage=%s; upload-line-cov=%v;",
 		*gcsBucketName, *postSubmitJobName, *artifactsDir, *coverageTargetDir, *coverageProfileName,
-		*githubTokenPath, *covThreshold, *postingBotUserName)
+		*githubTokenPath, *covThreshold, *postingBotUserName, *thresholdMode, *reportMode, *covPolicyPath,
+		*mergeProfilePaths, *mergeGcsJobs, trendOpts.store, *localStorage, *uploadLineCov)
 
 	log.Println("Getting env values")
 	pr := os.Getenv("PULL_NUMBER")
@@ -83,6 +145,10 @@ func main() {
 	)
 
 	localArtifacts.ProduceProfileFile(*coverageTargetDir)
+	if err := mergeProfiles(localArtifacts, splitList(*mergeProfilePaths), splitList(*mergeGcsJobs),
+		*gcsBucketName, *localStorage); err != nil {
+		logUtil.LogFatalf("%v", err)
+	}
 
 	log.Printf("Running workflow: %s\n", jobType)
 	switch jobType {
@@ -94,15 +160,16 @@ func main() {
 				buildStr, err)
 		}
 
-		prData := githubPr.New(*githubTokenPath, repoOwner, repoName, pr, *postingBotUserName)
+		prData := githubPr.New(*githubTokenPath, repoOwner, repoName, pr, pullSha, *postingBotUserName)
 		gcsData := &gcs.PresubmitBuild{GcsBuild: gcs.GcsBuild{
-			Client:       gcs.NewClient(prData.Ctx),
+			Client:       gcs.NewStorage(prData.Ctx, *localStorage),
 			Bucket:       *gcsBucketName,
 			Job:          jobName,
 			Build:        build,
 			CovThreshold: *covThreshold,
 		},
 			PostSubmitJob: *postSubmitJobName,
+			UploadLineCov: *uploadLineCov,
 		}
 		presubmit := &gcs.PreSubmit{
 			GithubPr:       *prData,
@@ -110,7 +177,7 @@ func main() {
 		}
 
 		presubmit.Artifacts = *presubmit.MakeGcsArtifacts(*localArtifacts)
-		isCoverageLow, err := RunPresubmit(presubmit, localArtifacts)
+		isCoverageLow, err := RunPresubmit(presubmit, localArtifacts, *thresholdMode, *reportMode, baseSha, covPolicy)
 		if isCoverageLow {
 			logUtil.LogFatalf("Code coverage is below threshold (%d%%), "+
 				"fail presubmit workflow intentionally", *covThreshold)
@@ -118,9 +185,21 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+	case "postsubmit":
+		if trendOpts.store == "" {
+			break
+		}
+		build, err := strconv.Atoi(os.Getenv("BUILD_NUMBER"))
+		if err != nil {
+			logUtil.LogFatalf("BUILD_NUMBER(%s) cannot be converted to int, err=%v",
+				os.Getenv("BUILD_NUMBER"), err)
+		}
+		if err := recordTrend(trendOpts, localArtifacts, build, baseSha, *covThreshold, covPolicy); err != nil {
+			logUtil.LogFatalf("%v", err)
+		}
 	case "periodic":
 		log.Printf("job type is %v, producing testsuite xml...\n", jobType)
-		testgrid.ProfileToTestsuiteXML(localArtifacts, *covThreshold)
+		testgrid.ProfileToTestsuiteXML(localArtifacts, *covThreshold, covPolicy)
 	}
 
 	fmt.Println("end of code coverage main")
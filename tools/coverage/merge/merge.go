@@ -0,0 +1,205 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package merge merges the coverage profiles of multiple test runs, e.g. unit,
+// integration and e2e tests, into a single profile
+package merge
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+)
+
+const (
+	modeSet    = "set"
+	modeCount  = "count"
+	modeAtomic = "atomic"
+)
+
+// blockLine matches a line of a coverage profile, in the format of
+// "name.go:line.column,line.column numberOfStatements count"
+var blockLine = regexp.MustCompile(`^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$`)
+
+// position is a position in a source file
+type position struct {
+	line, column int
+}
+
+func (p position) before(o position) bool {
+	return p.line < o.line || (p.line == o.line && p.column < o.column)
+}
+
+// block is a code block of a coverage profile
+type block struct {
+	start, end position
+	numStmt    int
+	count      int
+}
+
+// profile is the union of the blocks of merged profiles, keyed by file name
+type profile struct {
+	mode  string
+	files map[string]map[[2]position]*block
+}
+
+// mergeMode returns the mode of the merged profile. Counts can't be recovered
+// from set mode, so any set profile makes the merged profile set. Atomic and
+// count modes only differ in how counts were collected.
+func mergeMode(current, mode string) string {
+	switch {
+	case current == "" || current == mode:
+		return mode
+	case current == modeSet || mode == modeSet:
+		return modeSet
+	default:
+		return modeAtomic
+	}
+}
+
+// add adds the blocks of the profile read from reader
+func (p *profile) add(name string, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() {
+		return fmt.Errorf("profile %s is empty", name)
+	}
+	mode := strings.TrimPrefix(scanner.Text(), "mode: ")
+	switch mode {
+	case modeSet, modeCount, modeAtomic:
+	default:
+		return fmt.Errorf("profile %s has invalid mode line '%s'", name, scanner.Text())
+	}
+	p.mode = mergeMode(p.mode, mode)
+
+	for lineNum := 2; scanner.Scan(); lineNum++ {
+		row := scanner.Text()
+		if row == "" {
+			continue
+		}
+		m := blockLine.FindStringSubmatch(row)
+		if m == nil {
+			return fmt.Errorf("profile %s line %d: invalid block '%s'", name, lineNum, row)
+		}
+		nums := make([]int, 6)
+		for i := range nums {
+			nums[i], _ = strconv.Atoi(m[i+2])
+		}
+		blk := &block{
+			start:   position{nums[0], nums[1]},
+			end:     position{nums[2], nums[3]},
+			numStmt: nums[4],
+			count:   nums[5],
+		}
+		if p.files[m[1]] == nil {
+			p.files[m[1]] = make(map[[2]position]*block)
+		}
+		key := [2]position{blk.start, blk.end}
+		existing, ok := p.files[m[1]][key]
+		if !ok {
+			p.files[m[1]][key] = blk
+			continue
+		}
+		if existing.numStmt != blk.numStmt {
+			return fmt.Errorf("profile %s line %d: block has %d statements, but %d in other profiles",
+				name, lineNum, blk.numStmt, existing.numStmt)
+		}
+		existing.count += blk.count
+	}
+	return scanner.Err()
+}
+
+// sortedBlocks returns the blocks of the file sorted by position, and an error
+// if blocks partially overlap, which happens when profiles were produced from
+// different versions of the source
+func (p *profile) sortedBlocks(file string) ([]*block, error) {
+	var blocks []*block
+	for _, blk := range p.files[file] {
+		blocks = append(blocks, blk)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].start != blocks[j].start {
+			return blocks[i].start.before(blocks[j].start)
+		}
+		return blocks[i].end.before(blocks[j].end)
+	})
+	for i := 1; i < len(blocks); i++ {
+		if prev := blocks[i-1]; blocks[i].start.before(prev.end) {
+			return nil, fmt.Errorf("overlapping blocks %d.%d,%d.%d and %d.%d,%d.%d in %s, "+
+				"profiles are from different versions of the source",
+				prev.start.line, prev.start.column, prev.end.line, prev.end.column,
+				blocks[i].start.line, blocks[i].start.column, blocks[i].end.line, blocks[i].end.column, file)
+		}
+	}
+	return blocks, nil
+}
+
+// write writes the merged profile, with files sorted by name
+func (p *profile) write(w io.Writer) error {
+	var files []string
+	for file := range p.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", p.mode)
+	for _, file := range files {
+		blocks, err := p.sortedBlocks(file)
+		if err != nil {
+			return err
+		}
+		for _, blk := range blocks {
+			count := blk.count
+			if p.mode == modeSet && count > 0 {
+				count = 1
+			}
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", file, blk.start.line, blk.start.column,
+				blk.end.line, blk.end.column, blk.numStmt, count)
+		}
+	}
+	return bw.Flush()
+}
+
+// Profiles merges the coverage profiles read from readers, keyed by a name
+// used in errors, and writes the merged profile to w. Counts of the same
+// block are summed in count and atomic modes, and a block is covered if it's
+// covered in any profile in set mode. All readers are closed.
+func Profiles(readers map[string]*artifacts.ProfileReader, w io.Writer) error {
+	var names []string
+	for name := range readers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p := &profile{files: make(map[string]map[[2]position]*block)}
+	var err error
+	for _, name := range names {
+		if err == nil {
+			err = p.add(name, readers[name])
+		}
+		readers[name].Close()
+	}
+	if err != nil {
+		return err
+	}
+	return p.write(w)
+}
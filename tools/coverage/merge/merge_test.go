@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merge
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+)
+
+func TestProfiles(t *testing.T) {
+	cases := []struct {
+		name     string
+		profiles []string
+		expected string
+		isErr    bool
+	}{{
+		name: "count",
+		profiles: []string{
+			"mode: count\na.go:1.10,3.2 2 1\na.go:3.2,5.3 1 0\n",
+			"mode: count\na.go:3.2,5.3 1 4\na.go:1.10,3.2 2 2\nb.go:1.1,2.2 1 0\n",
+		},
+		expected: "mode: count\na.go:1.10,3.2 2 3\na.go:3.2,5.3 1 4\nb.go:1.1,2.2 1 0\n",
+	}, {
+		name: "count and atomic",
+		profiles: []string{
+			"mode: count\na.go:1.10,3.2 2 1\n",
+			"mode: atomic\na.go:1.10,3.2 2 2\n",
+		},
+		expected: "mode: atomic\na.go:1.10,3.2 2 3\n",
+	}, {
+		name: "set and count",
+		profiles: []string{
+			"mode: set\na.go:1.10,3.2 2 0\na.go:3.2,5.3 1 1\n",
+			"mode: count\na.go:1.10,3.2 2 5\na.go:3.2,5.3 1 0\n",
+		},
+		expected: "mode: set\na.go:1.10,3.2 2 1\na.go:3.2,5.3 1 1\n",
+	}, {
+		name: "different statements",
+		profiles: []string{
+			"mode: set\na.go:1.10,3.2 2 0\n",
+			"mode: set\na.go:1.10,3.2 3 1\n",
+		},
+		isErr: true,
+	}, {
+		name: "overlapping blocks",
+		profiles: []string{
+			"mode: set\na.go:1.10,3.2 2 0\n",
+			"mode: set\na.go:2.10,4.2 2 1\n",
+		},
+		isErr: true,
+	}, {
+		name:     "invalid mode",
+		profiles: []string{"mode: lines\n"},
+		isErr:    true,
+	}, {
+		name:     "invalid block",
+		profiles: []string{"mode: set\na.go:1.10 2 0\n"},
+		isErr:    true,
+	}, {
+		name:     "empty",
+		profiles: []string{""},
+		isErr:    true,
+	}}
+	for _, tt := range cases {
+		readers := make(map[string]*artifacts.ProfileReader)
+		for i, p := range tt.profiles {
+			readers[string(rune('a'+i))] = artifacts.NewProfileReader(ioutil.NopCloser(strings.NewReader(p)))
+		}
+		var buf bytes.Buffer
+		err := Profiles(readers, &buf)
+		if (err != nil) != tt.isErr {
+			t.Errorf("%s: Profiles() error = %v, expected error: %v", tt.name, err, tt.isErr)
+		}
+		if err == nil && buf.String() != tt.expected {
+			t.Errorf("%s: Profiles() = %q, expected %q", tt.name, buf.String(), tt.expected)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy reads coverage policies checked in by repos, mapping
+// directories to minimum coverage thresholds and exclusions, and excluding
+// files by glob, build tag, package or marker
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+	"knative.dev/test-infra/tools/coverage/glob"
+)
+
+// Policy is a list of rules, later rules override earlier ones for the
+// directories they both match, and exclusions of files in any directory
+type Policy struct {
+	Rules      []Rule          `yaml:"rules"`
+	Exclusions *git.Exclusions `yaml:"exclusions,omitempty"`
+}
+
+// Rule sets the threshold of directories matching Path, or excludes them
+// from coverage. Path is relative to the repository root, and is either a
+// glob matching a directory, e.g. "pkg/apis/*", or a glob followed by "/..."
+// matching a directory and all its subdirectories, e.g. "third_party/...".
+type Rule struct {
+	Path      string `yaml:"path"`
+	Threshold *int   `yaml:"threshold,omitempty"`
+	Exclude   *bool  `yaml:"exclude,omitempty"`
+}
+
+// Load reads and validates the policy file
+func Load(file string) (*Policy, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading coverage policy '%s': %v", file, err)
+	}
+	p := &Policy{}
+	if err := yaml.UnmarshalStrict(contents, p); err != nil {
+		return nil, fmt.Errorf("failed parsing coverage policy '%s': %v", file, err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid coverage policy '%s': %v", file, err)
+	}
+	return p, nil
+}
+
+// Validate checks that all rules have a valid path and threshold, and that the
+// exclusions are valid
+func (p *Policy) Validate() error {
+	var errs []error
+	for i, r := range p.Rules {
+		if r.Path == "" {
+			errs = append(errs, fmt.Errorf("rule #%d: missing path", i))
+		} else if err := glob.Validate(r.Path); err != nil {
+			errs = append(errs, fmt.Errorf("rule #%d: invalid path '%s': %v", i, r.Path, err))
+		}
+		if r.Threshold != nil && (*r.Threshold < 0 || *r.Threshold > 100) {
+			errs = append(errs, fmt.Errorf("rule #%d: invalid threshold '%d', must be in range [0, 100]", i, *r.Threshold))
+		}
+		if r.Threshold == nil && r.Exclude == nil {
+			errs = append(errs, fmt.Errorf("rule #%d: neither threshold nor exclude is set", i))
+		}
+	}
+	if p.Exclusions != nil {
+		if err := p.Exclusions.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("exclusions: %v", err))
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// matches checks whether the rule matches the directory, "." being the
+// repository root
+func (r Rule) matches(dir string) bool {
+	return glob.Match(r.Path, dir)
+}
+
+// Threshold returns the threshold of the directory set by the last matching
+// rule, or defaultThreshold if no rule sets it
+func (p *Policy) Threshold(dir string, defaultThreshold int) int {
+	threshold := defaultThreshold
+	for _, r := range p.Rules {
+		if r.Threshold != nil && r.matches(dir) {
+			threshold = *r.Threshold
+		}
+	}
+	return threshold
+}
+
+// IsExcluded checks whether the directory is excluded from coverage by the
+// last matching rule
+func (p *Policy) IsExcluded(dir string) bool {
+	excluded := false
+	for _, r := range p.Rules {
+		if r.Exclude != nil && r.matches(dir) {
+			excluded = *r.Exclude
+		}
+	}
+	return excluded
+}
+
+// Apply returns the list without the files in excluded directories, with
+// thresholds of directories set by the policy. A nil policy returns the list
+// as is.
+func (p *Policy) Apply(g *calc.CoverageList) *calc.CoverageList {
+	if p == nil {
+		return g
+	}
+	filtered := g.Filter(func(c *calc.Coverage) bool {
+		return !p.IsExcluded(githubUtil.DirPathProfileToGithub(path.Dir(c.Name())))
+	})
+	filtered.SetThresholdFunc(func(dir string) int {
+		return p.Threshold(githubUtil.DirPathProfileToGithub(dir), g.CovThresInt())
+	})
+	return filtered
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/test"
+)
+
+const testPolicy = `rules:
+- path: "..."
+  threshold: 60
+- path: pkg/apis/*
+  threshold: 30
+- path: third_party/...
+  exclude: true
+- path: third_party/kept
+  exclude: false
+- path: cmd/*/...
+  threshold: 10
+`
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		contents string
+		isErr    bool
+	}{
+		{"valid", testPolicy, false},
+		{"unknown field", "rules:\n- path: pkg\n  treshold: 30\n", true},
+		{"missing path", "rules:\n- threshold: 30\n", true},
+		{"invalid glob", "rules:\n- path: pkg/[\n  threshold: 30\n", true},
+		{"invalid threshold", "rules:\n- path: pkg\n  threshold: 130\n", true},
+		{"nothing set", "rules:\n- path: pkg\n", true},
+		{"exclusions", "exclusions:\n  files:\n  - zz_generated.*.go\n  buildTags:\n  - e2e\n  mainPackages: true\n", false},
+		{"invalid exclusion glob", "exclusions:\n  files:\n  - '[a'\n", true},
+		{"invalid exclusion tag", "exclusions:\n  buildTags:\n  - '!e2e'\n", true},
+		{"unknown exclusion field", "exclusions:\n  dirs:\n  - pkg\n", true},
+	}
+	for _, tt := range cases {
+		file := path.Join(dir, "policy.yaml")
+		ioutil.WriteFile(file, []byte(tt.contents), 0644)
+		if _, err := Load(file); (err != nil) != tt.isErr {
+			t.Errorf("Load() with %s policy error = %v, expected error: %v", tt.name, err, tt.isErr)
+		}
+	}
+	if _, err := Load(path.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Load() with missing file expected error")
+	}
+}
+
+func TestLoadExclusions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "policy.yaml")
+	contents := "rules:\n- path: third_party/...\n  exclude: true\n" +
+		"exclusions:\n  files:\n  - zz_generated.*.go\n  buildTags:\n  - e2e\n  mainPackages: true\n  ignoreMarker: false\n"
+	if err := ioutil.WriteFile(file, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(file)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	ignoreMarker := false
+	expected := &git.Exclusions{
+		Files:        []string{"zz_generated.*.go"},
+		BuildTags:    []string{"e2e"},
+		MainPackages: true,
+		IgnoreMarker: &ignoreMarker,
+	}
+	if !reflect.DeepEqual(p.Exclusions, expected) {
+		t.Errorf("Load() exclusions = %+v, expected %+v", p.Exclusions, expected)
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	p := &Policy{Rules: []Rule{
+		{Path: "...", Threshold: intPtr(60)},
+		{Path: "pkg/apis/*", Threshold: intPtr(30)},
+		{Path: "cmd/*/...", Threshold: intPtr(10)},
+	}}
+	cases := []struct {
+		dir      string
+		expected int
+	}{
+		{".", 60},
+		{"pkg", 60},
+		{"pkg/apis", 60},
+		{"pkg/apis/serving", 30},
+		{"pkg/apis/serving/v1", 60},
+		{"cmd", 60},
+		{"cmd/controller", 10},
+		{"cmd/controller/app", 10},
+	}
+	for _, tt := range cases {
+		test.AssertEqual(t, tt.expected, p.Threshold(tt.dir, 50))
+	}
+	test.AssertEqual(t, 50, (&Policy{}).Threshold("pkg", 50))
+}
+
+func TestIsExcluded(t *testing.T) {
+	p := &Policy{Rules: []Rule{
+		{Path: "third_party/...", Exclude: boolPtr(true)},
+		{Path: "third_party/kept", Exclude: boolPtr(false)},
+	}}
+	cases := []struct {
+		dir      string
+		expected bool
+	}{
+		{".", false},
+		{"pkg", false},
+		{"third_party", true},
+		{"third_party/lib", true},
+		{"third_party/kept", false},
+		{"third_party/kept/sub", true},
+	}
+	for _, tt := range cases {
+		test.AssertEqual(t, tt.expected, p.IsExcluded(tt.dir))
+	}
+}
+
+func TestApply(t *testing.T) {
+	profile := "mode: count\n" +
+		"knative.dev/test-infra/pkg/apis/serving/types.go:5.20,8.2 2 1\n" +
+		"knative.dev/test-infra/third_party/lib/lib.go:5.20,8.2 2 0\n"
+	g := calc.CovList(artifacts.NewProfileReader(ioutil.NopCloser(strings.NewReader(profile))), nil, nil, 50)
+	p := &Policy{Rules: []Rule{
+		{Path: "pkg/apis/*", Threshold: intPtr(30)},
+		{Path: "third_party/...", Exclude: boolPtr(true)},
+	}}
+
+	applied := p.Apply(g)
+	test.AssertEqual(t, 1, len(*applied.Group()))
+	test.AssertEqual(t, "knative.dev/test-infra/pkg/apis/serving/types.go", applied.Item(0).Name())
+	test.AssertEqual(t, 30, applied.ThresholdFor("knative.dev/test-infra/pkg/apis/serving"))
+	test.AssertEqual(t, 50, applied.ThresholdFor("knative.dev/test-infra"))
+
+	var nilPolicy *Policy
+	test.AssertEqual(t, g, nilPolicy.Apply(g))
+}
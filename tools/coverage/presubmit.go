@@ -17,22 +17,53 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"log"
+	"sort"
 
+	"knative.dev/test-infra/pkg/helpers"
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/export"
 	"knative.dev/test-infra/tools/coverage/gcs"
+	"knative.dev/test-infra/tools/coverage/git"
 	"knative.dev/test-infra/tools/coverage/githubUtil"
+	"knative.dev/test-infra/tools/coverage/githubUtil/githubPr"
 	"knative.dev/test-infra/tools/coverage/io"
 	"knative.dev/test-infra/tools/coverage/line"
+	"knative.dev/test-infra/tools/coverage/policy"
 )
 
-// RunPresubmit runs the pre-submit procedure
-func RunPresubmit(p *gcs.PreSubmit, arts *artifacts.LocalArtifacts) (bool, error) {
+const (
+	// thresholdModeFile applies the coverage threshold to each changed file
+	thresholdModeFile = "file"
+	// thresholdModePatch applies the coverage threshold to the changed lines
+	thresholdModePatch = "patch"
+	// thresholdModeBoth applies the coverage threshold to both
+	thresholdModeBoth = "both"
+
+	// reportModeComment reports coverage in a comment on the pull request
+	reportModeComment = "comment"
+	// reportModeCheckRun reports coverage in a check run, with annotations on
+	// uncovered changed lines
+	reportModeCheckRun = "check-run"
+)
+
+// RunPresubmit runs the pre-submit procedure. thresholdMode selects whether
+// the coverage threshold applies to changed files, changed lines, or both.
+// Changed lines are read from github, or from the diff against baseSha when
+// running without a github connection. If the coverage policy is not nil,
+// its thresholds and exclusions apply to changed files, and packages of
+// changed files are also required to meet their thresholds. reportMode selects
+// whether the report is posted as a comment or a check run.
+func RunPresubmit(p *gcs.PreSubmit, arts *artifacts.LocalArtifacts, thresholdMode, reportMode, baseSha string,
+	pol *policy.Policy) (bool, error) {
 	log.Println("starting PreSubmit.RunPresubmit(...)")
 
 	// concerned files is a collection of all the files whose coverage change will be reported
 	var concernedFiles map[string]bool
+	// changed lines are the ranges of lines changed in each file, for patch coverage
+	var changedLines map[string][]git.LineRange
 
 	if p.GithubClient != nil {
 		concernedFiles = githubUtil.GetConcernedFiles(&p.GithubPr, "")
@@ -41,26 +72,159 @@ func RunPresubmit(p *gcs.PreSubmit, arts *artifacts.LocalArtifacts) (bool, error
 				"don't need to run coverage profile in presubmit\n")
 			return false, nil
 		}
+		changedLines = githubUtil.GetChangedLines(&p.GithubPr, "")
+	} else if baseSha != "" {
+		var err error
+		if changedLines, err = git.ChangedLines(baseSha); err != nil {
+			log.Printf("Cannot get changed lines, skipping patch coverage: %v", err)
+		}
 	}
+	changedLines = concernedChangedLines(changedLines, concernedFiles)
 
 	gNew := calc.CovList(arts.ProfileReader(), arts.KeyProfileCreator(),
 		concernedFiles, p.CovThreshold)
-	err := line.CreateLineCovFile(arts)
+	lineCovErr := line.CreateLineCovFile(arts)
+	if lineCovErr == nil && p.UploadLineCov {
+		lineCovErr = p.Artifacts.Upload(arts.Directory(), artifacts.LineCovFileName)
+	}
+	if lineCovErr != nil {
+		log.Printf("Failed creating or uploading line coverage: %v", lineCovErr)
+	}
+	if exportErr := export.CreateFiles(arts.KeyProfilePath(), arts.Directory()); exportErr != nil {
+		log.Printf("Failed exporting coverage: %v", exportErr)
+	}
 	line.GenerateLineCovLinks(p, gNew)
+	gNew = pol.Apply(gNew)
 
 	base := gcs.NewPostSubmit(p.Ctx, p.Client, p.Bucket,
 		p.PostSubmitJob, gcs.ArtifactsDirNameOnGcs, arts.ProfileName())
-	gBase := calc.CovList(base.ProfileReader(), nil, concernedFiles, p.CovThreshold)
+	gBase := pol.Apply(calc.CovList(base.ProfileReader(), nil, concernedFiles, p.CovThreshold))
 	changes := calc.NewGroupChanges(gBase, gNew)
 
-	postContent, isEmpty, isCoverageLow := changes.ContentForGithubPost(concernedFiles)
+	postContent, isEmpty, isFileCoverageLow := changes.ContentForGithubPost(concernedFiles)
+	// packages are rolled up from all their files, not only the concerned ones
+	gAll := pol.Apply(calc.CovList(arts.ProfileReader(), nil, nil, p.CovThreshold))
+	packagesContent, isPackageCoverageLow := gAll.PackagesContentForGithubPost(concernedFiles)
+	if !isEmpty && packagesContent != "" {
+		postContent += "\n" + packagesContent
+	}
+	// package thresholds are only enforced if set by a coverage policy
+	isFileCoverageLow = isFileCoverageLow || (pol != nil && isPackageCoverageLow)
+
+	patch := pol.Apply(calc.PatchCovList(arts.ProfileReader(), changedLines, p.CovThreshold))
+	if patchContent := calc.PatchContentForGithubPost(patch); patchContent != "" {
+		postContent += "\n" + patchContent
+		isEmpty = false
+	}
+	isPatchCoverageLow := patch.Coverage.IsCoverageLow(p.CovThreshold)
+	// source files are read from the working directory, the root of the repo
+	funcs := calc.FuncCovList(arts.ProfileReader(), "", gNew)
+	if funcsContent := calc.FuncsContentForGithubPost(funcs, changedLines); funcsContent != "" {
+		postContent += "\n" + funcsContent
+	}
+	isLow := isCoverageLow(thresholdMode, isFileCoverageLow, isPatchCoverageLow)
 
 	io.Write(&postContent, arts.Directory(), "bot-post")
 
+	var err error
 	if !isEmpty && p.GithubClient != nil {
-		err = p.GithubPr.CleanAndPostComment(postContent)
+		if reportMode == reportModeCheckRun {
+			uncovered := calc.UncoveredPatchLines(arts.ProfileReader(), changedLines, patch)
+			err = p.GithubPr.CreateCheckRun(checkRunTitle(patch, uncovered), postContent, isLow,
+				annotations(uncovered))
+			if err != nil {
+				log.Printf("Cannot report coverage in a check run, posting a comment instead: %v", err)
+			}
+		}
+		if reportMode != reportModeCheckRun || err != nil {
+			err = p.GithubPr.CleanAndPostComment(postContent)
+		}
 	}
 
 	log.Println("completed PreSubmit.RunPresubmit(...)")
-	return isCoverageLow, err
+	return isLow, helpers.CombineErrors([]error{lineCovErr, err})
+}
+
+// concernedChangedLines keeps the changed lines of the files whose coverage is reported,
+// so that generated and excluded files don't count toward patch coverage. Files are
+// looked up in concernedFiles if not nil, otherwise they are checked with
+// git.IsCoverageSkipped.
+func concernedChangedLines(changedLines map[string][]git.LineRange, concernedFiles map[string]bool) map[string][]git.LineRange {
+	res := make(map[string][]git.LineRange)
+	for file, ranges := range changedLines {
+		if concernedFiles != nil && !concernedFiles[file] {
+			continue
+		}
+		if concernedFiles == nil && git.IsCoverageSkipped(file) {
+			continue
+		}
+		res[file] = ranges
+	}
+	return res
+}
+
+// checkRunTitle summarizes the patch coverage in the title of the check run
+func checkRunTitle(patch *calc.CoverageList, uncovered map[string][]git.LineRange) string {
+	if _, err := patch.Coverage.Ratio(); err != nil {
+		return "No changed lines in code blocks"
+	}
+	nUncovered := 0
+	for _, ranges := range uncovered {
+		for _, r := range ranges {
+			nUncovered += r.End - r.Start + 1
+		}
+	}
+	return fmt.Sprintf("Patch coverage %s, %d changed lines not covered", patch.Coverage.Percentage(), nUncovered)
+}
+
+// annotations annotates the ranges of uncovered lines, sorted by file
+func annotations(uncovered map[string][]git.LineRange) []githubPr.Annotation {
+	files := make([]string, 0, len(uncovered))
+	for file := range uncovered {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var res []githubPr.Annotation
+	for _, file := range files {
+		for _, r := range uncovered[file] {
+			msg := fmt.Sprintf("Line %d is not covered by tests", r.Start)
+			if r.End > r.Start {
+				msg = fmt.Sprintf("Lines %d-%d are not covered by tests", r.Start, r.End)
+			}
+			res = append(res, githubPr.Annotation{Path: file, StartLine: r.Start, EndLine: r.End, Message: msg})
+		}
+	}
+	return res
+}
+
+// validateThresholdMode checks that the threshold mode is one of the supported modes
+func validateThresholdMode(thresholdMode string) error {
+	switch thresholdMode {
+	case thresholdModeFile, thresholdModePatch, thresholdModeBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid threshold-mode '%s', must be one of '%s', '%s' or '%s'",
+		thresholdMode, thresholdModeFile, thresholdModePatch, thresholdModeBoth)
+}
+
+// validateReportMode checks that the report mode is one of the supported modes
+func validateReportMode(reportMode string) error {
+	switch reportMode {
+	case reportModeComment, reportModeCheckRun:
+		return nil
+	}
+	return fmt.Errorf("invalid report-mode '%s', must be one of '%s' or '%s'",
+		reportMode, reportModeComment, reportModeCheckRun)
+}
+
+// isCoverageLow checks whether the coverage the threshold mode applies to is low
+func isCoverageLow(thresholdMode string, isFileCoverageLow, isPatchCoverageLow bool) bool {
+	switch thresholdMode {
+	case thresholdModePatch:
+		return isPatchCoverageLow
+	case thresholdModeBoth:
+		return isFileCoverageLow || isPatchCoverageLow
+	default:
+		return isFileCoverageLow
+	}
 }
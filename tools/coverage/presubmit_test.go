@@ -14,3 +14,271 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/gcs"
+	"knative.dev/test-infra/tools/coverage/gcs/gcsFakes"
+	"knative.dev/test-infra/tools/coverage/git"
+	"knative.dev/test-infra/tools/coverage/githubUtil/githubFakes"
+	"knative.dev/test-infra/tools/coverage/githubUtil/githubPr"
+)
+
+func TestIsCoverageLow(t *testing.T) {
+	cases := []struct {
+		mode              string
+		fileLow, patchLow bool
+		expected          bool
+	}{
+		{thresholdModeFile, true, false, true},
+		{thresholdModeFile, false, true, false},
+		{thresholdModePatch, true, false, false},
+		{thresholdModePatch, false, true, true},
+		{thresholdModeBoth, false, true, true},
+		{thresholdModeBoth, false, false, false},
+	}
+	for _, tt := range cases {
+		if actual := isCoverageLow(tt.mode, tt.fileLow, tt.patchLow); actual != tt.expected {
+			t.Errorf("isCoverageLow(%s, %v, %v) = %v, expected %v", tt.mode, tt.fileLow, tt.patchLow, actual, tt.expected)
+		}
+	}
+	if !strings.Contains(validateThresholdMode("lines").Error(), "invalid threshold-mode 'lines'") {
+		t.Error("validateThresholdMode() should reject unknown modes")
+	}
+}
+
+// newPresubmitForTest returns a presubmit of PR 7 on a local storage holding
+// the post-submit builds, and the artifacts of a change covering the second
+// block of onlySrcChange.go. The returned function removes both.
+func newPresubmitForTest(t *testing.T) (*gcs.PreSubmit, *artifacts.LocalArtifacts, func()) {
+	storage, root := gcsFakes.NewLocalStorage(1, 2)
+	dir, err := ioutil.TempDir("", "coverage-presubmit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		os.RemoveAll(root)
+		os.RemoveAll(dir)
+	}
+
+	base, err := ioutil.ReadFile("testdata/artifacts/cov-profile.txt")
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	newProfile := strings.Replace(string(base), "onlySrcChange.go:10.20,12.2 1 0", "onlySrcChange.go:10.20,12.2 1 1", 1)
+	ioutil.WriteFile(path.Join(dir, "cov-profile.txt"), []byte(newProfile), 0644)
+	arts := artifacts.NewLocalArtifacts(dir, "cov-profile.txt", keyCovProfileFileName, defaultStdoutRedirect)
+
+	p := &gcs.PreSubmit{
+		GithubPr: githubPr.GithubPr{RepoOwner: "fakeRepoOwner", RepoName: "fakeRepoName", Pr: 7, Ctx: context.Background()},
+		PresubmitBuild: gcs.PresubmitBuild{
+			GcsBuild: gcs.GcsBuild{
+				Client:       storage,
+				Bucket:       gcsFakes.FakeGcsBucketName,
+				Job:          gcsFakes.FakePreSubmitProwJobName,
+				Build:        3,
+				CovThreshold: 50,
+			},
+			PostSubmitJob: gcsFakes.FakePostSubmitProwJobName,
+			UploadLineCov: true,
+		},
+	}
+	p.Artifacts = *p.MakeGcsArtifacts(*arts)
+	return p, arts, cleanup
+}
+
+// TestRunPresubmit runs the presubmit workflow end to end on a local storage:
+// fetching the base profile of the post-submit job, computing the delta, and
+// uploading line coverage
+func TestRunPresubmit(t *testing.T) {
+	p, arts, cleanup := newPresubmitForTest(t)
+	defer cleanup()
+
+	isLow, err := RunPresubmit(p, arts, thresholdModeFile, reportModeComment, "", nil)
+	if err != nil {
+		t.Fatalf("RunPresubmit() failed: %v", err)
+	}
+	if isLow {
+		t.Error("RunPresubmit() reported low coverage, expected none")
+	}
+
+	post, err := ioutil.ReadFile(path.Join(arts.Directory(), "bot-post"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[tools/coverage/testTarget/presubmit/onlySrcChange.go]", ") | 66.7% | 100.0% | 33.3"} {
+		if !strings.Contains(string(post), want) {
+			t.Errorf("bot post doesn't contain %q:\n%s", want, post)
+		}
+	}
+	lineCov := path.Join("pr-logs/pull/fakeRepoOwner_fakeRepoName/7", gcsFakes.FakePreSubmitProwJobName, "3/artifacts/line-cov.html")
+	if !p.Client.Exists(p.Ctx, gcsFakes.FakeGcsBucketName, lineCov) {
+		t.Errorf("line coverage is not uploaded to %s", lineCov)
+	}
+}
+
+// TestRunPresubmitLineCovError checks that a failure to create or upload line
+// coverage is returned, even though coverage is then reported on the PR
+func TestRunPresubmitLineCovError(t *testing.T) {
+	p, arts, cleanup := newPresubmitForTest(t)
+	defer cleanup()
+	p.GithubPr = *githubFakes.FakeRepoData()
+	checks := p.GithubClient.Checks.(*githubFakes.FakeGithubChecks)
+	// match the files of the fake PR, which go tool cover cannot find
+	profile, err := ioutil.ReadFile(arts.ProfilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile = []byte(strings.ReplaceAll(string(profile), "test-infra/tools/coverage/testTarget", "test-infra/testTarget"))
+	ioutil.WriteFile(arts.ProfilePath(), profile, 0644)
+
+	if _, err := RunPresubmit(p, arts, thresholdModeFile, reportModeCheckRun, "", nil); err == nil {
+		t.Error("RunPresubmit() should fail when line coverage cannot be created")
+	}
+	if len(checks.CheckRuns) != 1 {
+		t.Errorf("got %d check runs, want coverage reported despite the error", len(checks.CheckRuns))
+	}
+}
+
+func TestConcernedChangedLines(t *testing.T) {
+	changed := []git.LineRange{{Start: 3, End: 5}}
+	cases := []struct {
+		name           string
+		changedLines   map[string][]git.LineRange
+		concernedFiles map[string]bool
+		want           map[string][]git.LineRange
+	}{
+		{"concerned files of the pull request",
+			map[string][]git.LineRange{"pkg/a.go": changed, "pkg/zz_generated.deepcopy.go": changed, "pkg/b.go": changed},
+			map[string]bool{"pkg/a.go": true, "pkg/zz_generated.deepcopy.go": false},
+			map[string][]git.LineRange{"pkg/a.go": changed}},
+		{"files skipped by git attributes",
+			map[string][]git.LineRange{"testTarget/presubmit/common.go": changed, "testTarget/presubmit/ling-gen.go": changed,
+				"testTarget/presubmit/cov-excl.go": changed},
+			nil,
+			map[string][]git.LineRange{"testTarget/presubmit/common.go": changed}},
+	}
+	for _, tt := range cases {
+		if got := concernedChangedLines(tt.changedLines, tt.concernedFiles); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: concernedChangedLines() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestConcernedChangedLinesExclusions checks that the exclusions also apply to
+// patch coverage, both in presubmit runs without github and in local runs
+func TestConcernedChangedLinesExclusions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-exclusions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ignored, kept := path.Join(dir, "fake.go"), path.Join(dir, "real.go")
+	ioutil.WriteFile(ignored, []byte("// coverage:ignore\n\npackage fake\n"), 0644)
+	ioutil.WriteFile(kept, []byte("package fake\n"), 0644)
+	git.SetExclusions(&git.Exclusions{Files: []string{"zz_generated.*.go"}})
+	defer git.SetExclusions(nil)
+
+	changed := []git.LineRange{{Start: 3, End: 5}}
+	changedLines := map[string][]git.LineRange{
+		"pkg/apis/zz_generated.deepcopy.go": changed,
+		ignored:                             changed,
+		kept:                                changed,
+	}
+	want := map[string][]git.LineRange{kept: changed}
+	if got := concernedChangedLines(changedLines, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("concernedChangedLines() = %v, want %v", got, want)
+	}
+}
+
+// TestPatchCoverageExcludedFile checks that uncovered changed lines of an excluded
+// file in the diff don't fail the patch threshold, nor get annotated
+func TestPatchCoverageExcludedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(path.Join(dir, "cov-profile.txt"), []byte(`mode: count
+knative.dev/test-infra/pkg/a.go:3.20,5.2 2 1
+knative.dev/test-infra/pkg/zz_generated.deepcopy.go:3.20,20.2 10 0
+`), 0644)
+	arts := artifacts.NewLocalArtifacts(dir, "cov-profile.txt", keyCovProfileFileName, defaultStdoutRedirect)
+	changed := []git.LineRange{{Start: 3, End: 20}}
+	changedLines := concernedChangedLines(
+		map[string][]git.LineRange{"pkg/a.go": changed, "pkg/zz_generated.deepcopy.go": changed},
+		map[string]bool{"pkg/a.go": true, "pkg/zz_generated.deepcopy.go": false})
+
+	patch := calc.PatchCovList(arts.ProfileReader(), changedLines, 50)
+	if patch.Coverage.IsCoverageLow(50) {
+		t.Errorf("patch coverage %s is low, the excluded file should not count", patch.Coverage.Percentage())
+	}
+	if uncovered := calc.UncoveredPatchLines(arts.ProfileReader(), changedLines, patch); len(uncovered) != 0 {
+		t.Errorf("got uncovered lines %v, want none in the excluded file", uncovered)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	uncovered := map[string][]git.LineRange{
+		"pkg/b.go": {{Start: 3, End: 3}},
+		"pkg/a.go": {{Start: 5, End: 7}},
+	}
+	want := []githubPr.Annotation{
+		{Path: "pkg/a.go", StartLine: 5, EndLine: 7, Message: "Lines 5-7 are not covered by tests"},
+		{Path: "pkg/b.go", StartLine: 3, EndLine: 3, Message: "Line 3 is not covered by tests"},
+	}
+	if got := annotations(uncovered); !reflect.DeepEqual(got, want) {
+		t.Errorf("annotations() = %+v, want %+v", got, want)
+	}
+	if !strings.Contains(validateReportMode("status").Error(), "invalid report-mode 'status'") {
+		t.Error("validateReportMode() should reject unknown modes")
+	}
+}
+
+func TestCreateCheckRun(t *testing.T) {
+	data := githubFakes.FakeRepoData()
+	checks := data.GithubClient.Checks.(*githubFakes.FakeGithubChecks)
+	var uncovered []git.LineRange
+	for line := 1; line <= 120; line += 2 {
+		uncovered = append(uncovered, git.LineRange{Start: line, End: line})
+	}
+	if err := data.CreateCheckRun("title", "summary", true,
+		annotations(map[string][]git.LineRange{"pkg/a.go": uncovered})); err != nil {
+		t.Fatalf("CreateCheckRun() failed: %v", err)
+	}
+	if len(checks.CheckRuns) != 1 || checks.CheckRuns[0].GetConclusion() != "failure" ||
+		checks.CheckRuns[0].HeadSHA != "fakeHeadSha" {
+		t.Errorf("got check runs %+v, want one failed check run on fakeHeadSha", checks.CheckRuns)
+	}
+	// annotations exceeding the limit of a request are added by updates
+	if len(checks.Annotations) != 60 {
+		t.Errorf("got %d annotations, want 60", len(checks.Annotations))
+	}
+
+	// the check run is kept if annotations cannot be added
+	checks.UpdateErr = errors.New("422 Validation Failed")
+	if err := data.CreateCheckRun("title", "summary", true,
+		annotations(map[string][]git.LineRange{"pkg/a.go": uncovered})); err != nil {
+		t.Errorf("CreateCheckRun() failed after creating the check run: %v", err)
+	}
+	if len(checks.CheckRuns) != 2 || len(checks.Annotations) != 110 {
+		t.Errorf("got %d check runs, %d annotations, want 2 check runs, 110 annotations",
+			len(checks.CheckRuns), len(checks.Annotations))
+	}
+
+	checks.Err = errors.New("403 Resource not accessible by integration")
+	if err := data.CreateCheckRun("title", "summary", false, nil); err == nil {
+		t.Error("CreateCheckRun() should fail without permissions")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// profiles.go merges coverage profiles of other test runs into the profile
+// produced by the tool, so that combined coverage is reported
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/gcs"
+	"knative.dev/test-infra/tools/coverage/merge"
+)
+
+// splitList splits a comma separated list, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mergeProfiles merges the given profile files, and the profiles of the latest
+// healthy builds of the given jobs in the bucket, into the profile of the
+// artifacts. The bucket is in GCS, or in the local storage under localStorage
+// if it's not empty. The merged profile replaces the profile of the artifacts.
+func mergeProfiles(arts *artifacts.LocalArtifacts, files, jobs []string, bucket, localStorage string) error {
+	if len(files) == 0 && len(jobs) == 0 {
+		return nil
+	}
+	readers := map[string]*artifacts.ProfileReader{arts.ProfilePath(): arts.ProfileReader()}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			for _, r := range readers {
+				r.Close()
+			}
+			return fmt.Errorf("failed opening profile to merge: %v", err)
+		}
+		readers[file] = artifacts.NewProfileReader(f)
+	}
+	if len(jobs) > 0 {
+		ctx := context.Background()
+		client := gcs.NewStorage(ctx, localStorage)
+		for _, job := range jobs {
+			p := gcs.NewPostSubmit(ctx, client, bucket, job, gcs.ArtifactsDirNameOnGcs, arts.ProfileName())
+			readers[fmt.Sprintf("gs://%s/%s", bucket, path.Join("logs", job, fmt.Sprint(p.Build)))] = p.ProfileReader()
+		}
+	}
+
+	log.Printf("Merging %d coverage profiles\n", len(readers))
+	var buf bytes.Buffer
+	if err := merge.Profiles(readers, &buf); err != nil {
+		return fmt.Errorf("failed merging profiles: %v", err)
+	}
+	return ioutil.WriteFile(arts.ProfilePath(), buf.Bytes(), 0644)
+}
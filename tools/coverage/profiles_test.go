@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/gcs/gcsFakes"
+)
+
+func TestSplitList(t *testing.T) {
+	if got := splitList(" a,,b ,"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("splitList() = %v, expected [a b]", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Errorf("splitList() = %v, expected nil", got)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// unit and e2e test profiles
+	ioutil.WriteFile(path.Join(dir, "unit.txt"), []byte(localBaseProfile), 0644)
+	e2ePath := path.Join(dir, "e2e.txt")
+	ioutil.WriteFile(e2ePath, []byte(localNewProfile), 0644)
+
+	arts := artifacts.NewLocalArtifacts(dir, "unit.txt", keyCovProfileFileName, defaultStdoutRedirect)
+	if err := mergeProfiles(arts, []string{e2ePath}, nil, "", ""); err != nil {
+		t.Fatalf("mergeProfiles() failed: %v", err)
+	}
+	merged, err := ioutil.ReadFile(arts.ProfilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `mode: count
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:5.20,8.2 2 1
+knative.dev/test-infra/tools/coverage/testTarget/presubmit/onlySrcChange.go:10.20,12.2 1 1
+`
+	if string(merged) != expected {
+		t.Errorf("merged profile = %q, expected %q", merged, expected)
+	}
+
+	if err := mergeProfiles(arts, []string{path.Join(dir, "missing.txt")}, nil, "", ""); err == nil {
+		t.Error("mergeProfiles() with missing profile expected error")
+	}
+
+	// merge the profile of the latest healthy build of a job in the storage,
+	// which has the same name as the profile of the artifacts
+	_, root := gcsFakes.NewLocalStorage(1)
+	defer os.RemoveAll(root)
+	ioutil.WriteFile(path.Join(dir, "cov-profile.txt"), []byte(localBaseProfile), 0644)
+	arts = artifacts.NewLocalArtifacts(dir, "cov-profile.txt", keyCovProfileFileName, defaultStdoutRedirect)
+	if err := mergeProfiles(arts, nil, []string{gcsFakes.FakePostSubmitProwJobName}, gcsFakes.FakeGcsBucketName, root); err != nil {
+		t.Fatalf("mergeProfiles() with jobs failed: %v", err)
+	}
+	merged, err = ioutil.ReadFile(arts.ProfilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "onlySrcChange.go:5.20,8.2 2 2\n"; !strings.Contains(string(merged), want) {
+		t.Errorf("merged profile doesn't contain %q:\n%s", want, merged)
+	}
+}
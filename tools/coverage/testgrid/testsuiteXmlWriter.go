@@ -19,12 +19,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"strconv"
 
 	"knative.dev/test-infra/pkg/junit"
 	"knative.dev/test-infra/tools/coverage/artifacts"
 	"knative.dev/test-infra/tools/coverage/calc"
 	"knative.dev/test-infra/tools/coverage/logUtil"
+	"knative.dev/test-infra/tools/coverage/policy"
 )
 
 // NewTestCase constructs the TestCase struct
@@ -46,15 +48,14 @@ func NewTestCase(targetName, coverage string, failure bool) junit.TestCase {
 	return tc
 }
 
-// toTestsuite populates Testsuite struct with data from CoverageList and actual file
-// directories from OS
-func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
+// toTestsuite populates Testsuite struct with data from CoverageList, actual file
+// directories from OS, and functions of the files
+func toTestsuite(g *calc.CoverageList, dirs []string, funcs []calc.FuncCoverage) *junit.TestSuite {
 	g.Summarize()
-	covThresInt := g.CovThresInt()
 	ts := junit.TestSuite{}
 
 	// Add overall coverage
-	ts.AddTestCase(NewTestCase("OVERALL", g.PercentageForTestgrid(), g.IsCoverageLow(covThresInt)))
+	ts.AddTestCase(NewTestCase("OVERALL", g.PercentageForTestgrid(), g.IsCoverageLow(g.CovThresInt())))
 
 	fmt.Println("")
 	log.Println("Constructing Testsuite Struct for Testgrid")
@@ -63,7 +64,7 @@ func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
 	for _, cov := range *g.Group() {
 		coverage := cov.PercentageForTestgrid()
 		if coverage != "" {
-			ts.AddTestCase(NewTestCase(cov.Name(), coverage, cov.IsCoverageLow(covThresInt)))
+			ts.AddTestCase(NewTestCase(cov.Name(), coverage, cov.IsCoverageLow(g.ThresholdFor(path.Dir(cov.Name())))))
 		} else {
 			log.Printf("Skipping file %s as it has no coverage data.\n", cov.Name())
 		}
@@ -74,11 +75,28 @@ func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
 		dirCov := g.Subset(dir)
 		coverage := dirCov.PercentageForTestgrid()
 		if coverage != "" {
-			ts.AddTestCase(NewTestCase(dir, coverage, dirCov.IsCoverageLow(covThresInt)))
+			ts.AddTestCase(NewTestCase(dir, coverage, dirCov.IsCoverageLow(g.ThresholdFor(dir))))
 		} else {
 			log.Printf("Skipping directory %s as it has no files with coverage data.\n", dir)
 		}
 	}
+
+	// Add coverage for packages, which unlike dirs don't include sub-directories
+	for _, pkg := range g.Packages() {
+		coverage := pkg.PercentageForTestgrid()
+		if coverage != "" {
+			ts.AddTestCase(NewTestCase(pkg.Name()+" (package)", coverage, pkg.IsCoverageLow(g.ThresholdFor(pkg.Name()))))
+		}
+	}
+
+	// Add coverage for functions, named after their files
+	for _, fc := range funcs {
+		coverage := fc.PercentageForTestgrid()
+		if coverage != "" {
+			ts.AddTestCase(NewTestCase(fmt.Sprintf("%s:%s (function)", fc.File, fc.Name()), coverage,
+				fc.IsCoverageLow(g.ThresholdFor(path.Dir(fc.File)))))
+		}
+	}
 	log.Println("Finished Constructing Testsuite Struct for Testgrid")
 	fmt.Println("")
 
@@ -86,22 +104,26 @@ func toTestsuite(g *calc.CoverageList, dirs []string) *junit.TestSuite {
 }
 
 // ProfileToTestsuiteXML uses coverage profile (and it's corresponding stdout) to produce junit xml
-// which serves as the input for test coverage testgrid
-func ProfileToTestsuiteXML(arts *artifacts.LocalArtifacts, covThres int) {
-	groupCov := calc.CovList(
+// which serves as the input for test coverage testgrid, with test cases for files, directories,
+// packages and functions. Thresholds and exclusions of the coverage policy are applied if it's not nil
+func ProfileToTestsuiteXML(arts *artifacts.LocalArtifacts, covThres int, pol *policy.Policy) {
+	groupCov := pol.Apply(calc.CovList(
 		artifacts.NewProfileReader(arts.ProfileReader()),
 		nil,
 		nil,
 		covThres,
-	)
+	))
 	f, err := os.Create(arts.JunitXmlForTestgridPath())
 	if err != nil {
 		logUtil.LogFatalf("Cannot create file: %v", err)
 	}
 	defer f.Close()
 
+	// source files are read from the working directory, the root of the repo
+	funcs := calc.FuncCovList(artifacts.NewProfileReader(arts.ProfileReader()), "", groupCov)
+
 	suites := junit.TestSuites{}
-	suites.AddTestSuite(toTestsuite(groupCov, groupCov.GetDirs()))
+	suites.AddTestSuite(toTestsuite(groupCov, groupCov.GetDirs(), funcs))
 	output, err := suites.ToBytes("", "    ")
 	if err != nil {
 		logUtil.LogFatalf("error: %v\n", err)
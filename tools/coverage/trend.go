@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// trend.go records the package coverage of post-submit builds in the coverage
+// history, and reports packages whose coverage regressed
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/io"
+	"knative.dev/test-infra/tools/coverage/policy"
+	"knative.dev/test-infra/tools/coverage/trend"
+)
+
+const (
+	trendCommand        = "trend"
+	defaultTrendWindow  = 10
+	defaultTrendMaxDrop = 1.0
+)
+
+// trendOptions are the options of the trend command
+type trendOptions struct {
+	store   string
+	window  int
+	maxDrop float64
+}
+
+// addTrendFlags adds the flags of the trend report to the flag set
+func addTrendFlags(fs *flag.FlagSet, o *trendOptions, storeUsage string) {
+	fs.StringVar(&o.store, "trend-store", "", storeUsage)
+	fs.IntVar(&o.window, "trend-window", defaultTrendWindow, "number of latest builds over which coverage regressions are detected")
+	fs.Float64Var(&o.maxDrop, "trend-max-drop", defaultTrendMaxDrop, "maximum drop of package coverage in percentage "+
+		"points over the window, packages dropping more are reported as regressions")
+}
+
+func parseTrendOptions(args []string) (*trendOptions, error) {
+	o := &trendOptions{}
+	fs := flag.NewFlagSet(trendCommand, flag.ContinueOnError)
+	addTrendFlags(fs, o, "coverage history, either a local file or a GCS directory in the format of gs://bucket/dir")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if o.store == "" {
+		return nil, fmt.Errorf("--trend-store must be set")
+	}
+	return o, nil
+}
+
+// runTrend prints the report of coverage regressions in the history
+func runTrend(o *trendOptions) error {
+	store, err := trend.NewStore(context.Background(), o.store)
+	if err != nil {
+		return err
+	}
+	h, err := store.Load()
+	if err != nil {
+		return err
+	}
+	fmt.Print(h.Report(o.window, o.maxDrop))
+	return nil
+}
+
+// recordTrend records the package coverage of the build in the history, and
+// writes the report of coverage regressions in the artifacts directory
+func recordTrend(o *trendOptions, arts *artifacts.LocalArtifacts, build int, commit string,
+	covThreshold int, pol *policy.Policy) error {
+	store, err := trend.NewStore(context.Background(), o.store)
+	if err != nil {
+		return err
+	}
+	g := pol.Apply(calc.CovList(arts.ProfileReader(), nil, nil, covThreshold))
+	if err := store.Record(trend.NewPoint(build, commit, time.Now(), g)); err != nil {
+		return err
+	}
+	log.Printf("Recorded coverage of build %d at %s in %s\n", build, commit, o.store)
+
+	h, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	report := h.Report(o.window, o.maxDrop)
+	log.Printf("Coverage trend:\n%s", report)
+	io.Write(&report, arts.Directory(), "coverage-trend.md")
+	return nil
+}
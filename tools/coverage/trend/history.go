@@ -0,0 +1,202 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trend records the package coverage of post-submit builds as a time
+// series, and detects packages whose coverage regressed over time
+package trend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"knative.dev/test-infra/tools/coverage/calc"
+	"knative.dev/test-infra/tools/coverage/githubUtil"
+)
+
+// MaxPoints is the number of points kept in the history, older points are
+// dropped when new points are recorded
+const MaxPoints = 1000
+
+// PackageCoverage is the coverage of a package in a build
+type PackageCoverage struct {
+	Covered int `json:"covered"`
+	Total   int `json:"total"`
+}
+
+// Percentage returns the percentage of statements covered
+func (c PackageCoverage) Percentage() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Covered) * 100 / float64(c.Total)
+}
+
+// Point is the coverage of all packages in a build
+type Point struct {
+	Build  int       `json:"build"`
+	Commit string    `json:"commit"`
+	Time   time.Time `json:"time"`
+	// Packages are keyed by directory relative to the repository root
+	Packages map[string]PackageCoverage `json:"packages"`
+}
+
+// NewPoint creates the point of a build from the coverage of its files
+func NewPoint(build int, commit string, t time.Time, g *calc.CoverageList) Point {
+	p := Point{Build: build, Commit: commit, Time: t, Packages: make(map[string]PackageCoverage)}
+	for _, pkg := range g.Packages() {
+		if pkg.NumAllStmts() == 0 {
+			continue
+		}
+		p.Packages[githubUtil.DirPathProfileToGithub(pkg.Name())] = PackageCoverage{
+			Covered: pkg.NumCoveredStmts(),
+			Total:   pkg.NumAllStmts(),
+		}
+	}
+	return p
+}
+
+// History is the time series of the coverage of builds, sorted by build
+type History struct {
+	Points []Point `json:"points"`
+}
+
+// Record adds the point to the history, replacing the point of the same build
+// if it exists, and drops the oldest points beyond MaxPoints
+func (h *History) Record(p Point) {
+	points := []Point{p}
+	for _, existing := range h.Points {
+		if existing.Build != p.Build {
+			points = append(points, existing)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Build < points[j].Build
+	})
+	if len(points) > MaxPoints {
+		points = points[len(points)-MaxPoints:]
+	}
+	h.Points = points
+}
+
+// Drop is a drop of the coverage of a package between two consecutive builds
+type Drop struct {
+	Build          int
+	Commit         string
+	PreviousCommit string
+	Change         float64
+}
+
+// Regression is a package whose coverage dropped over the window
+type Regression struct {
+	Package string
+	From    float64
+	To      float64
+	// Drops are the builds in the window where the coverage dropped, i.e. the
+	// commits responsible for the regression
+	Drops []Drop
+}
+
+// Change returns the change of coverage in percentage points
+func (r Regression) Change() float64 {
+	return r.To - r.From
+}
+
+// window returns the last n points of the history
+func (h *History) window(n int) []Point {
+	if n <= 0 || n > len(h.Points) {
+		return h.Points
+	}
+	return h.Points[len(h.Points)-n:]
+}
+
+// Regressions returns the packages of the latest build whose coverage dropped
+// by more than maxDrop percentage points since the first build containing them
+// in the last window builds, sorted by the largest drop first
+func (h *History) Regressions(window int, maxDrop float64) []Regression {
+	points := h.window(window)
+	if len(points) < 2 {
+		return nil
+	}
+	latest := points[len(points)-1]
+	var regressions []Regression
+	for pkg, cov := range latest.Packages {
+		r := Regression{Package: pkg, To: cov.Percentage()}
+		var prev *Point
+		for i := range points {
+			c, ok := points[i].Packages[pkg]
+			if !ok {
+				continue
+			}
+			if prev == nil {
+				r.From = c.Percentage()
+			} else if change := c.Percentage() - prev.Packages[pkg].Percentage(); change < 0 {
+				r.Drops = append(r.Drops, Drop{
+					Build:          points[i].Build,
+					Commit:         points[i].Commit,
+					PreviousCommit: prev.Commit,
+					Change:         change,
+				})
+			}
+			prev = &points[i]
+		}
+		if -r.Change() > maxDrop {
+			regressions = append(regressions, r)
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Change() != regressions[j].Change() {
+			return regressions[i].Change() < regressions[j].Change()
+		}
+		return regressions[i].Package < regressions[j].Package
+	})
+	return regressions
+}
+
+// shortSha shortens the commit sha for display
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// Report returns the markdown report of the regressions over the window
+func (h *History) Report(window int, maxDrop float64) string {
+	n := len(h.window(window))
+	regressions := h.Regressions(window, maxDrop)
+	if len(regressions) == 0 {
+		return fmt.Sprintf("No package coverage dropped more than %.1f%% over the last %d builds.\n", maxDrop, n)
+	}
+	rows := []string{
+		fmt.Sprintf("Package coverage dropped more than %.1f%% over the last %d builds:", maxDrop, n),
+		"",
+		"Package | Coverage | Change | Commits Responsible",
+		"------- |:--------:|:------:| -------------------",
+	}
+	for _, r := range regressions {
+		var commits []string
+		for _, d := range r.Drops {
+			commits = append(commits, fmt.Sprintf("%s..%s (%.1f%%)",
+				shortSha(d.PreviousCommit), shortSha(d.Commit), d.Change))
+		}
+		rows = append(rows, fmt.Sprintf("%s | %.1f%% → %.1f%% | %.1f%% | %s",
+			r.Package, r.From, r.To, r.Change(), strings.Join(commits, ", ")))
+	}
+	rows = append(rows, "")
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trend
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// point creates the point of a build, with the coverage of packages in
+// percentage of 100 statements
+func point(build int, commit string, coverages map[string]int) Point {
+	p := Point{Build: build, Commit: commit, Time: time.Unix(int64(build), 0), Packages: make(map[string]PackageCoverage)}
+	for pkg, covered := range coverages {
+		p.Packages[pkg] = PackageCoverage{Covered: covered, Total: 100}
+	}
+	return p
+}
+
+func historyForTest() *History {
+	h := &History{}
+	h.Record(point(3, "ccccccccc", map[string]int{"pkg/a": 78, "pkg/b": 50, "pkg/c": 10}))
+	h.Record(point(1, "aaaaaaaaa", map[string]int{"pkg/a": 80, "pkg/b": 60}))
+	h.Record(point(2, "bbbbbbbbb", map[string]int{"pkg/a": 81, "pkg/b": 55}))
+	h.Record(point(4, "ddddddddd", map[string]int{"pkg/a": 79, "pkg/b": 50, "pkg/c": 5}))
+	return h
+}
+
+func TestRecord(t *testing.T) {
+	h := historyForTest()
+	var builds []int
+	for _, p := range h.Points {
+		builds = append(builds, p.Build)
+	}
+	if !reflect.DeepEqual(builds, []int{1, 2, 3, 4}) {
+		t.Errorf("got builds %v, expected [1 2 3 4]", builds)
+	}
+	// recording the same build again replaces it
+	h.Record(point(4, "eeeeeeeee", nil))
+	if len(h.Points) != 4 || h.Points[3].Commit != "eeeeeeeee" {
+		t.Errorf("got points %+v, expected build 4 to be replaced", h.Points)
+	}
+}
+
+func TestRecordMaxPoints(t *testing.T) {
+	h := &History{}
+	for build := 0; build < MaxPoints+5; build++ {
+		h.Record(point(build, "", nil))
+	}
+	if len(h.Points) != MaxPoints || h.Points[0].Build != 5 {
+		t.Errorf("got %d points starting at build %d, expected %d starting at build 5",
+			len(h.Points), h.Points[0].Build, MaxPoints)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	h := historyForTest()
+	expected := []Regression{{
+		Package: "pkg/b",
+		From:    60,
+		To:      50,
+		Drops: []Drop{
+			{Build: 2, Commit: "bbbbbbbbb", PreviousCommit: "aaaaaaaaa", Change: -5},
+			{Build: 3, Commit: "ccccccccc", PreviousCommit: "bbbbbbbbb", Change: -5},
+		},
+	}, {
+		Package: "pkg/c",
+		From:    10,
+		To:      5,
+		Drops:   []Drop{{Build: 4, Commit: "ddddddddd", PreviousCommit: "ccccccccc", Change: -5}},
+	}}
+	if got := h.Regressions(0, 1); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Regressions() = %+v, expected %+v", got, expected)
+	}
+	// pkg/b didn't drop over the last 2 builds
+	if got := h.Regressions(2, 1); len(got) != 1 || got[0].Package != "pkg/c" {
+		t.Errorf("Regressions() over 2 builds = %+v, expected pkg/c", got)
+	}
+	if got := h.Regressions(0, 20); len(got) != 0 {
+		t.Errorf("Regressions() with max drop 20 = %+v, expected none", got)
+	}
+	if got := (&History{Points: h.Points[:1]}).Regressions(0, 1); got != nil {
+		t.Errorf("Regressions() of a single build = %+v, expected none", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := historyForTest().Report(10, 1)
+	for _, want := range []string{
+		"over the last 4 builds",
+		"pkg/b | 60.0% → 50.0% | -10.0% | aaaaaaa..bbbbbbb (-5.0%), bbbbbbb..ccccccc (-5.0%)",
+		"pkg/c | 10.0% → 5.0% | -5.0% | ccccccc..ddddddd (-5.0%)",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "pkg/a") {
+		t.Errorf("report contains pkg/a:\n%s", report)
+	}
+
+	expected := "No package coverage dropped more than 20.0% over the last 4 builds.\n"
+	if got := historyForTest().Report(10, 20); got != expected {
+		t.Errorf("Report() = %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// store.go persists the history as JSON, in a local file or in GCS
+
+package trend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	pkggcs "knative.dev/test-infra/pkg/gcs"
+	"knative.dev/test-infra/tools/coverage/gcs"
+)
+
+const (
+	gcsPrefix = "gs://"
+	// pointSuffix is the suffix of the objects of points in GCS
+	pointSuffix = ".json"
+)
+
+// Store loads the history and records points in it
+type Store interface {
+	Load() (*History, error)
+	Record(p Point) error
+}
+
+// NewStore creates the store at location, which is either a GCS directory in
+// the format of "gs://bucket/dir", or a local file
+func NewStore(ctx context.Context, location string) (Store, error) {
+	if !strings.HasPrefix(location, gcsPrefix) {
+		return &fileStore{path: location}, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(location, gcsPrefix), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.Trim(parts[1], "/") == "" {
+		return nil, fmt.Errorf("invalid gcs location '%s', must be in the format of gs://bucket/dir", location)
+	}
+	return &gcsStore{ctx: ctx, client: gcs.NewClient(ctx), bucket: parts[0], dir: strings.Trim(parts[1], "/")}, nil
+}
+
+// decode decodes the history, or returns an empty history if there's no content
+func decode(contents []byte) (*History, error) {
+	h := &History{}
+	if len(contents) == 0 {
+		return h, nil
+	}
+	if err := json.Unmarshal(contents, h); err != nil {
+		return nil, fmt.Errorf("failed decoding coverage history: %v", err)
+	}
+	return h, nil
+}
+
+// fileStore keeps the history in a single file, it's meant for local runs
+// where builds aren't recorded concurrently
+type fileStore struct {
+	path string
+}
+
+func (s *fileStore) Load() (*History, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed reading coverage history '%s': %v", s.path, err)
+	}
+	return decode(contents)
+}
+
+func (s *fileStore) Record(p Point) error {
+	h, err := s.Load()
+	if err != nil {
+		return err
+	}
+	h.Record(p)
+	contents, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path, contents, 0644); err != nil {
+		return fmt.Errorf("failed writing coverage history '%s': %v", s.path, err)
+	}
+	return nil
+}
+
+// gcsStore keeps each point in its own object in dir, named after its build,
+// so that post-submits finishing at the same time don't overwrite each other's
+// points. The history is aggregated from the latest MaxPoints objects on load.
+type gcsStore struct {
+	ctx    context.Context
+	client pkggcs.Storage
+	bucket string
+	dir    string
+}
+
+func (s *gcsStore) Load() (*History, error) {
+	children, err := s.client.ListDirectChildren(s.ctx, s.bucket, s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing coverage history gs://%s/%s: %v", s.bucket, s.dir, err)
+	}
+	var builds []int
+	for _, child := range children {
+		name := path.Base(child)
+		if !strings.HasSuffix(name, pointSuffix) {
+			continue
+		}
+		if build, err := strconv.Atoi(strings.TrimSuffix(name, pointSuffix)); err == nil {
+			builds = append(builds, build)
+		}
+	}
+	sort.Ints(builds)
+	if len(builds) > MaxPoints {
+		builds = builds[len(builds)-MaxPoints:]
+	}
+	h := &History{}
+	for _, build := range builds {
+		object := s.pointObject(build)
+		contents, err := s.client.ReadObject(s.ctx, s.bucket, object)
+		if err == storage.ErrObjectNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed reading coverage history gs://%s/%s: %v", s.bucket, object, err)
+		}
+		var p Point
+		if err := json.Unmarshal(contents, &p); err != nil {
+			return nil, fmt.Errorf("failed decoding coverage history gs://%s/%s: %v", s.bucket, object, err)
+		}
+		h.Record(p)
+	}
+	return h, nil
+}
+
+func (s *gcsStore) Record(p Point) error {
+	contents, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	object := s.pointObject(p.Build)
+	if _, err := s.client.WriteObject(s.ctx, s.bucket, object, contents); err != nil {
+		return fmt.Errorf("failed writing coverage history gs://%s/%s: %v", s.bucket, object, err)
+	}
+	return nil
+}
+
+// pointObject returns the object of the point of the build
+func (s *gcsStore) pointObject(build int) string {
+	return path.Join(s.dir, strconv.Itoa(build)+pointSuffix)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trend
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sync"
+	"testing"
+
+	pkggcs "knative.dev/test-infra/pkg/gcs"
+)
+
+// testStore checks that the store starts empty and loads what's recorded
+func testStore(t *testing.T, s Store) {
+	h, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() of a new store failed: %v", err)
+	}
+	if len(h.Points) != 0 {
+		t.Errorf("Load() of a new store = %+v, expected empty history", h)
+	}
+
+	h = historyForTest()
+	for _, p := range h.Points {
+		if err := s.Record(p); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+	loaded, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Regressions(0, 1), h.Regressions(0, 1)) || len(loaded.Points) != len(h.Points) {
+		t.Errorf("Load() = %+v, expected %+v", loaded, h)
+	}
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewStore(context.Background(), path.Join(dir, "history.json"))
+	if err != nil {
+		t.Fatalf("NewStore() failed: %v", err)
+	}
+	testStore(t, s)
+	testStore(t, &gcsStore{
+		ctx:    context.Background(),
+		client: pkggcs.NewLocalStorage(dir),
+		bucket: "bucket",
+		dir:    "coverage/history",
+	})
+}
+
+func TestGcsStoreConcurrentRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &gcsStore{
+		ctx:    context.Background(),
+		client: pkggcs.NewLocalStorage(dir),
+		bucket: "bucket",
+		dir:    "coverage/history",
+	}
+
+	// post-submits of consecutive commits may finish at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for build := 1; build <= 10; build++ {
+		wg.Add(1)
+		go func(build int) {
+			defer wg.Done()
+			errs <- s.Record(point(build, "", map[string]int{"pkg/a": build}))
+		}(build)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+	h, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(h.Points) != 10 || h.Points[0].Build != 1 || h.Points[9].Build != 10 {
+		t.Errorf("got %d points, expected builds 1 to 10: %+v", len(h.Points), h.Points)
+	}
+}
+
+func TestNewStoreInvalidGcsLocation(t *testing.T) {
+	for _, location := range []string{"gs://", "gs://bucket", "gs://bucket/", "gs:///dir"} {
+		if _, err := NewStore(context.Background(), location); err == nil {
+			t.Errorf("NewStore(%q) expected error", location)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/coverage/artifacts"
+	"knative.dev/test-infra/tools/coverage/trend"
+)
+
+func TestParseTrendOptions(t *testing.T) {
+	cases := []struct {
+		args  []string
+		isErr bool
+	}{
+		{[]string{"--trend-store", "history.json"}, false},
+		{[]string{"--trend-store", "gs://bucket/coverage/history", "--trend-window", "5", "--trend-max-drop", "0.5"}, false},
+		{[]string{"--trend-window", "5"}, true},
+	}
+	for _, tt := range cases {
+		if _, err := parseTrendOptions(tt.args); (err != nil) != tt.isErr {
+			t.Errorf("parseTrendOptions(%v) error = %v, expected error: %v", tt.args, err, tt.isErr)
+		}
+	}
+}
+
+func TestRecordTrend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage-trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o := &trendOptions{store: path.Join(dir, "history.json"), window: defaultTrendWindow, maxDrop: defaultTrendMaxDrop}
+
+	// coverage of testTarget/presubmit drops from 66.7% to 33.3%
+	for i, profile := range []string{localBaseProfile, localNewProfile} {
+		ioutil.WriteFile(path.Join(dir, "profile.txt"), []byte(profile), 0644)
+		arts := artifacts.NewLocalArtifacts(dir, "profile.txt", keyCovProfileFileName, defaultStdoutRedirect)
+		if err := recordTrend(o, arts, i+1, []string{"base-sha", "new-sha"}[i], 50, nil); err != nil {
+			t.Fatalf("recordTrend() failed: %v", err)
+		}
+	}
+
+	store, _ := trend.NewStore(context.Background(), o.store)
+	h, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Points) != 2 {
+		t.Fatalf("got %d points in the history, expected 2", len(h.Points))
+	}
+	if c := h.Points[1].Packages["tools/coverage/testTarget/presubmit"]; c.Covered != 1 || c.Total != 3 {
+		t.Errorf("got package coverage %+v of the new build, expected 1 of 3 statements", c)
+	}
+	report, err := ioutil.ReadFile(path.Join(dir, "coverage-trend.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "tools/coverage/testTarget/presubmit | 66.7% → 33.3%") {
+		t.Errorf("report doesn't contain the regression:\n%s", report)
+	}
+}
@@ -29,13 +29,15 @@ import (
 	"knative.dev/test-infra/pkg/mysql"
 )
 
+// Operations are the operations of a store of clusters and requests. It's implemented by
+// DBClient for MySQL and SQLite databases, and by MemoryStore.
 type Operations interface {
 	// check cluster available, if available, return cluster id and access token
-	CheckAvail(cp ClusterParams) (bool, int64)
+	CheckAvail(cp *ClusterParams) (bool, int64)
 	// check number of clusters of a status specific configurations
 	CheckNumStatus(cp *ClusterParams, status string) int64
 	// get with cluster id stored in the Cluster database
-	GetCluster(clusterID int64) (Response, error)
+	GetCluster(clusterID int64) (*Response, error)
 	// delete a cluster entry
 	DeleteCluster(clusterID int64) error
 	// Insert a cluster entry
@@ -45,11 +47,9 @@ type Operations interface {
 	// List clutsers (use for checking after downtime to see stale clusters)
 	ListClusters() ([]Cluster, error)
 	// get with accessToken stored in the Request database
-	GetRequest(accessToken string) (Request, error)
-	// Generate Unique access token for Prow
-	generateToken() string
-	// Insert a request entry
-	InsertRequest(r *Request) error
+	GetRequest(accessToken string) (*Request, error)
+	// Insert a request entry and return its access token
+	InsertRequest(r *Request) (string, error)
 	// Update a request entry
 	UpdateRequest(requestID int64, opts ...UpdateOption) error
 	// List requests within a time interval (use for checking after downtime to see stale requests)
@@ -65,6 +65,8 @@ type scannable interface {
 	Scan(dest ...interface{}) error
 }
 
+var _ Operations = (*DBClient)(nil)
+
 // DBClient holds an active database connection. This implements all the fuunctions of Operations.
 type DBClient struct {
 	*sql.DB
@@ -76,13 +78,17 @@ func (r Request) String() string {
 		r.requestTime, r.Nodes, r.NodeType, r.ProwJobID, r.Zone)
 }
 
-// generate a list of strings that could be used in a query for ClusterParams
-func (cp *ClusterParams) generateParamsConditions(opts ...QueryClusterParamsOption) []string {
+// generate a list of conditions that could be used in a query for ClusterParams, and
+// the arguments for their placeholders
+func (cp *ClusterParams) generateParamsConditions(opts ...QueryClusterParamsOption) ([]string, []interface{}) {
 	var fieldStatements []string
+	var args []interface{}
 	for _, opt := range opts {
-		fieldStatements = append(fieldStatements, opt(cp))
+		statement, arg := opt(cp)
+		fieldStatements = append(fieldStatements, statement)
+		args = append(args, arg)
 	}
-	return fieldStatements
+	return fieldStatements, args
 }
 
 func generateAND(fieldStatements []string) string {
@@ -103,19 +109,23 @@ func populateRequest(sc scannable) (*Request, error) {
 	return r, err
 }
 
-// NewDB returns the DB object with an active database connection
+// NewDB returns the DB object with an active database connection, with its schema
+// migrated to the latest version
 func NewDB(c *mysql.DBConfig) (*DBClient, error) {
 	db, err := c.Connect()
-	return &DBClient{db}, err
+	if err != nil {
+		return &DBClient{db}, err
+	}
+	return &DBClient{db}, migrate(db, mysqlDialect, migrations)
 }
 
 // check available clusters in Cluster db and return zone and projectID that would be used by ProwJob
 func (db *DBClient) CheckAvail(cp *ClusterParams) (bool, int64) {
 	// getting query string ready
-	conditions := generateAND(cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
-	queryString := fmt.Sprintf("SELECT * FROM Clusters WHERE Status = 'Ready' AND %s", conditions)
+	conditions, args := cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
+	queryString := "SELECT * FROM Clusters WHERE Status = 'Ready' AND " + generateAND(conditions)
 	// check whether available cluster exists
-	row := db.QueryRow(queryString)
+	row := db.QueryRow(queryString, args...)
 	c, err := populateCluster(row)
 	// no available cluster is found
 	if err != nil {
@@ -128,10 +138,9 @@ func (db *DBClient) CheckAvail(cp *ClusterParams) (bool, int64) {
 func (db *DBClient) CheckNumStatus(cp *ClusterParams, status string) int64 {
 	var count int64
 	// getting query string ready
-	conditions := generateAND(cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
-	queryString := fmt.Sprintf("SELECT COUNT(*) FROM Clusters WHERE Status = '%s' AND %s", status, conditions)
-	log.Printf("query string is: %s", queryString)
-	err := db.QueryRow(queryString).Scan(&count)
+	conditions, args := cp.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
+	queryString := "SELECT COUNT(*) FROM Clusters WHERE Status = ? AND " + generateAND(conditions)
+	err := db.QueryRow(queryString, append([]interface{}{status}, args...)...).Scan(&count)
 	if err != nil {
 		log.Printf("error is: %v", err)
 		return 0
@@ -155,18 +164,33 @@ func (db *DBClient) InsertCluster(c *Cluster) (int64, error) {
 	return res.LastInsertId()
 }
 
-func updateQueryString(dbName string, id int64, opts ...UpdateOption) string {
+// generate the update statement of the entry with the id, and the arguments for its
+// placeholders. Only the updatableColumns of the table can be updated.
+func updateQueryString(dbName string, id int64, opts ...UpdateOption) (string, []interface{}, error) {
 	var fieldStatements []string
+	var args []interface{}
 	for _, opt := range opts {
-		fieldStatements = append(fieldStatements, opt())
+		key, value := opt()
+		if !updatableColumns[dbName][key] {
+			return "", nil, fmt.Errorf("cannot update column %q of %s", key, dbName)
+		}
+		fieldStatements = append(fieldStatements, key+" = ?")
+		args = append(args, value)
+	}
+	if len(fieldStatements) == 0 {
+		return "", nil, fmt.Errorf("no column of %s to update", dbName)
 	}
-	return fmt.Sprintf("UPDATE %s SET %s WHERE ID = %d", dbName, strings.Join(fieldStatements, ","), id)
+	queryString := fmt.Sprintf("UPDATE %s SET %s WHERE ID = ?", dbName, strings.Join(fieldStatements, ", "))
+	return queryString, append(args, id), nil
 }
 
 // Update a cluster entry on different fields
 func (db *DBClient) UpdateCluster(clusterID int64, opts ...UpdateOption) error {
-	queryString := updateQueryString(ClusterDB, clusterID, opts...)
-	_, err := db.Exec(queryString)
+	queryString, args, err := updateQueryString(ClusterDB, clusterID, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(queryString, args...)
 	return err
 }
 
@@ -174,11 +198,12 @@ func (db *DBClient) UpdateCluster(clusterID int64, opts ...UpdateOption) error {
 func (db *DBClient) ListClusters() ([]Cluster, error) {
 	var result []Cluster
 	rows, err := db.Query(`
-	SELECT ID, Nodes, NodeType, ProjectID, Status, Zone
+	SELECT ID, ProjectID, Status, Zone, Nodes, NodeType
 	FROM Clusters`)
 	if err != nil {
 		return result, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		c, err := populateCluster(rows)
 		if err != nil {
@@ -207,7 +232,8 @@ func (db *DBClient) DeleteCluster(clusterID int64) error {
 	return err
 }
 
-func (db *DBClient) generateToken() string {
+// Generate Unique access token for Prow
+func generateToken() string {
 	return string(uuid.NewUUID())
 }
 
@@ -220,7 +246,7 @@ func (db *DBClient) GetCluster(clusterID int64) (*Response, error) {
 	if err != nil {
 		return &Response{}, err
 	}
-	clusterName := nameCluster(clusterID)
+	clusterName := NameCluster(clusterID)
 	cc := &Response{ClusterName: clusterName, ProjectID: c.ProjectID, Zone: c.Zone}
 	return cc, err
 }
@@ -239,8 +265,8 @@ func checkAffected(stmt *sql.Stmt, numRows int64, args ...interface{}) error {
 	return nil
 }
 
-// name cluster in the following format: e2e-cluster{id}-{prowJobID}
-func nameCluster(clusterID int64) string {
+// NameCluster names a cluster in the following format: e2e-cluster{id}
+func NameCluster(clusterID int64) string {
 	return fmt.Sprintf("e2e-cluster%v", clusterID)
 }
 
@@ -252,15 +278,18 @@ func (db *DBClient) InsertRequest(r *Request) (string, error) {
 		return "", err
 	}
 	defer stmt.Close()
-	accessToken := db.generateToken()
+	accessToken := generateToken()
 	_, err = stmt.Exec(accessToken, r.requestTime, r.ProwJobID, r.Nodes, r.NodeType, r.Zone)
 	return accessToken, err
 }
 
 // Update a request entry
 func (db *DBClient) UpdateRequest(requestID int64, opts ...UpdateOption) error {
-	queryString := updateQueryString(RequestDB, requestID, opts...)
-	_, err := db.Exec(queryString)
+	queryString, args, err := updateQueryString(RequestDB, requestID, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(queryString, args...)
 	return err
 }
 
@@ -287,6 +316,7 @@ func (db *DBClient) ListRequests(window time.Duration) ([]Request, error) {
 	if err != nil {
 		return result, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		r, err := populateRequest(rows)
 		if err != nil {
@@ -301,11 +331,11 @@ func (db *DBClient) ListRequests(window time.Duration) ([]Request, error) {
 func (db *DBClient) PriorityRanking(r *Request) int64 {
 	var rank int64
 	// getting query string ready
-	conditions := generateAND(r.ClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType()))
+	conditions, args := r.ClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())
 	// query only rows that haven't been assigned a cluster and of the same config
-	queryString := fmt.Sprintf("Select Rnk From (SELECT ID, RANK() OVER (ORDER BY RequestTime) Rnk FROM Requests WHERE ClusterID = 0 AND %s) Ranking WHERE Ranking.ID = %d", conditions, r.ID)
-	log.Printf("query string is: %s", queryString)
-	err := db.QueryRow(queryString).Scan(&rank)
+	queryString := "Select Rnk From (SELECT ID, RANK() OVER (ORDER BY RequestTime) Rnk FROM Requests WHERE ClusterID = 0 AND " +
+		generateAND(conditions) + ") Ranking WHERE Ranking.ID = ?"
+	err := db.QueryRow(queryString, append(args, r.ID)...).Scan(&rank)
 	if err != nil {
 		log.Printf("Got an error in the query: %v", err)
 		return math.MaxInt64
@@ -90,37 +90,54 @@ func TestPopulateCluster(t *testing.T) {
 
 func TestGenerateAnd(t *testing.T) {
 	cases := []struct {
-		cp              *ClusterParams
-		wantResult      string
-		fieldStatements []string
+		cp         *ClusterParams
+		opts       []QueryClusterParamsOption
+		wantResult string
+		wantArgs   []interface{}
 	}{
-		{fakeClusterParams, "Zone = 'us-central1' AND Nodes = 4 AND NodeType = 'e2-standard-4'", fakeClusterParams.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())},
-		{fakeClusterParams2, "Zone = '' AND Nodes = 4 AND NodeType = 'e2-standard-4'", fakeClusterParams2.generateParamsConditions(QueryZone(), QueryNodes(), QueryNodeType())},
-		{fakeClusterParams3, "Nodes = 4", fakeClusterParams3.generateParamsConditions(QueryNodes())},
+		{fakeClusterParams, []QueryClusterParamsOption{QueryZone(), QueryNodes(), QueryNodeType()}, "Zone = ? AND Nodes = ? AND NodeType = ?", []interface{}{"us-central1", int64(4), "e2-standard-4"}},
+		{fakeClusterParams2, []QueryClusterParamsOption{QueryZone(), QueryNodes(), QueryNodeType()}, "Zone = ? AND Nodes = ? AND NodeType = ?", []interface{}{"", int64(4), "e2-standard-4"}},
+		{fakeClusterParams3, []QueryClusterParamsOption{QueryNodes()}, "Nodes = ?", []interface{}{int64(4)}},
 	}
 	for _, test := range cases {
-		conditions := generateAND(test.fieldStatements)
+		fieldStatements, args := test.cp.generateParamsConditions(test.opts...)
+		conditions := generateAND(fieldStatements)
 		if !reflect.DeepEqual(conditions, test.wantResult) {
 			t.Errorf("get condition: got condition '%s', want condition '%s'", conditions, test.wantResult)
 		}
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("get condition: got args '%v', want args '%v'", args, test.wantArgs)
+		}
 	}
 
 }
 
-func TestupdateQueryString(t *testing.T) {
+func TestUpdateQueryString(t *testing.T) {
 	cases := []struct {
 		dbName     string
 		id         int64
 		wantResult string
+		wantArgs   []interface{}
+		wantErr    bool
 		update     []UpdateOption
 	}{
-		{"Clusters", 1, "UPDATE Clusters SET Zone = 'us-central1'WHERE ID = 1", []UpdateOption{UpdateStringField("Zone", "us-central1")}},
-		{"Clusters", 1, "UPDATE Clusters SET Zone = 'us-central1',Nodes=6 WHERE ID = 1", []UpdateOption{UpdateStringField("Zone", "us-central1"), UpdateNumField("Nodes", 6)}},
+		{"Clusters", 1, "UPDATE Clusters SET Zone = ? WHERE ID = ?", []interface{}{"us-central1", int64(1)}, false, []UpdateOption{UpdateStringField("Zone", "us-central1")}},
+		{"Clusters", 1, "UPDATE Clusters SET Zone = ?, Nodes = ? WHERE ID = ?", []interface{}{"us-central1", int64(6), int64(1)}, false, []UpdateOption{UpdateStringField("Zone", "us-central1"), UpdateNumField("Nodes", 6)}},
+		{"Requests", 2, "UPDATE Requests SET ClusterID = ? WHERE ID = ?", []interface{}{int64(3), int64(2)}, false, []UpdateOption{UpdateNumField("ClusterID", 3)}},
+		{"Clusters", 1, "", nil, true, []UpdateOption{UpdateNumField("ClusterID", 3)}},
+		{"Clusters", 1, "", nil, true, []UpdateOption{UpdateStringField("Zone = 'x', Status", "Ready")}},
+		{"Clusters", 1, "", nil, true, nil},
 	}
 	for _, test := range cases {
-		generatedString := updateQueryString(test.dbName, test.id, test.update...)
+		generatedString, args, err := updateQueryString(test.dbName, test.id, test.update...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("get string: got err '%v', want err %v", err, test.wantErr)
+		}
 		if !reflect.DeepEqual(generatedString, test.wantResult) {
 			t.Errorf("get string: got string '%s', want string '%s'", generatedString, test.wantResult)
 		}
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("get string: got args '%v', want args '%v'", args, test.wantArgs)
+		}
 	}
 }
@@ -0,0 +1,312 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps clusters and requests in memory, for tests and for running DKCM
+// without a database. It behaves like DBClient, including returning sql.ErrNoRows
+// for missing entries.
+type MemoryStore struct {
+	mutex         sync.Mutex
+	clusters      []*Cluster
+	requests      []*Request
+	lastClusterID int64
+	lastRequestID int64
+}
+
+var _ Operations = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// sameParams checks whether the zone, nodes and node type of the params are equal
+func sameParams(a, b *ClusterParams) bool {
+	return a.Zone == b.Zone && a.Nodes == b.Nodes && a.NodeType == b.NodeType
+}
+
+// copyParams copies the params so that entries aren't modified through their callers
+func copyParams(cp *ClusterParams) *ClusterParams {
+	if cp == nil {
+		return &ClusterParams{}
+	}
+	c := *cp
+	return &c
+}
+
+// setField sets a field of ClusterParams, which are shared by both tables
+func (cp *ClusterParams) setField(key string, value interface{}) bool {
+	switch key {
+	case "Zone":
+		return setString(&cp.Zone, value)
+	case "Nodes":
+		return setNum(&cp.Nodes, value)
+	case "NodeType":
+		return setString(&cp.NodeType, value)
+	}
+	return false
+}
+
+func setString(field *string, value interface{}) bool {
+	v, ok := value.(string)
+	if ok {
+		*field = v
+	}
+	return ok
+}
+
+func setNum(field *int64, value interface{}) bool {
+	v, ok := value.(int64)
+	if ok {
+		*field = v
+	}
+	return ok
+}
+
+// check available clusters and return the id of the first one
+func (m *MemoryStore) CheckAvail(cp *ClusterParams) (bool, int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, c := range m.clusters {
+		if c.Status == "Ready" && sameParams(c.ClusterParams, cp) {
+			return true, c.ID
+		}
+	}
+	return false, -1
+}
+
+// check number of clusters of a certain config and status
+func (m *MemoryStore) CheckNumStatus(cp *ClusterParams, status string) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var count int64
+	for _, c := range m.clusters {
+		if c.Status == status && sameParams(c.ClusterParams, cp) {
+			count++
+		}
+	}
+	return count
+}
+
+// findCluster returns the cluster with the id, the mutex must be held
+func (m *MemoryStore) findCluster(clusterID int64) (int, *Cluster) {
+	for i, c := range m.clusters {
+		if c.ID == clusterID {
+			return i, c
+		}
+	}
+	return -1, nil
+}
+
+// get the cluster with the id
+func (m *MemoryStore) GetCluster(clusterID int64) (*Response, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, c := m.findCluster(clusterID)
+	if c == nil {
+		return &Response{}, sql.ErrNoRows
+	}
+	return &Response{ClusterName: NameCluster(clusterID), ProjectID: c.ProjectID, Zone: c.Zone}, nil
+}
+
+// DeleteCluster deletes the cluster with the id
+func (m *MemoryStore) DeleteCluster(clusterID int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	i, _ := m.findCluster(clusterID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	m.clusters = append(m.clusters[:i], m.clusters[i+1:]...)
+	return nil
+}
+
+// insert a cluster entry, its status is WIP unless set
+func (m *MemoryStore) InsertCluster(c *Cluster) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastClusterID++
+	entry := &Cluster{ClusterParams: copyParams(c.ClusterParams), ProjectID: c.ProjectID, Status: c.Status}
+	entry.ID = m.lastClusterID
+	if entry.Status == "" {
+		entry.Status = "WIP"
+	}
+	m.clusters = append(m.clusters, entry)
+	return entry.ID, nil
+}
+
+// Update a cluster entry on different fields
+func (m *MemoryStore) UpdateCluster(clusterID int64, opts ...UpdateOption) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, c := m.findCluster(clusterID)
+	if c == nil {
+		return nil
+	}
+	updated := *c
+	updated.ClusterParams = copyParams(c.ClusterParams)
+	for _, opt := range opts {
+		key, value := opt()
+		var ok bool
+		switch key {
+		case "ProjectID":
+			ok = setString(&updated.ProjectID, value)
+		case "Status":
+			ok = setString(&updated.Status, value)
+		default:
+			ok = updated.ClusterParams.setField(key, value)
+		}
+		if !ok {
+			return fmt.Errorf("cannot update field %q of %s to %v", key, ClusterDB, value)
+		}
+	}
+	*c = updated
+	return nil
+}
+
+// List all clusters
+func (m *MemoryStore) ListClusters() ([]Cluster, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var result []Cluster
+	for _, c := range m.clusters {
+		entry := *c
+		entry.ClusterParams = copyParams(c.ClusterParams)
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// Get the request with the access token
+func (m *MemoryStore) GetRequest(accessToken string) (*Request, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, r := range m.requests {
+		if r.accessToken == accessToken {
+			entry := *r
+			entry.ClusterParams = copyParams(r.ClusterParams)
+			return &entry, nil
+		}
+	}
+	return &Request{}, sql.ErrNoRows
+}
+
+// insert a request entry
+func (m *MemoryStore) InsertRequest(r *Request) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastRequestID++
+	entry := &Request{ClusterParams: copyParams(r.ClusterParams), accessToken: generateToken(),
+		requestTime: r.requestTime, ProwJobID: r.ProwJobID}
+	entry.ID = m.lastRequestID
+	m.requests = append(m.requests, entry)
+	return entry.accessToken, nil
+}
+
+// Update a request entry
+func (m *MemoryStore) UpdateRequest(requestID int64, opts ...UpdateOption) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var r *Request
+	for _, entry := range m.requests {
+		if entry.ID == requestID {
+			r = entry
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	updated := *r
+	updated.ClusterParams = copyParams(r.ClusterParams)
+	for _, opt := range opts {
+		key, value := opt()
+		var ok bool
+		switch key {
+		case "ProwJobID":
+			ok = setString(&updated.ProwJobID, value)
+		case "ClusterID":
+			ok = setNum(&updated.ClusterID, value)
+		default:
+			ok = updated.ClusterParams.setField(key, value)
+		}
+		if !ok {
+			return fmt.Errorf("cannot update field %q of %s to %v", key, RequestDB, value)
+		}
+	}
+	*r = updated
+	return nil
+}
+
+// List requests within a time interval
+func (m *MemoryStore) ListRequests(window time.Duration) ([]Request, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var result []Request
+	startTime := time.Now().Add(-1 * window)
+	for _, r := range m.requests {
+		if r.requestTime.After(startTime) {
+			entry := *r
+			entry.ClusterParams = copyParams(r.ClusterParams)
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// rank request priority like RANK() OVER (ORDER BY RequestTime) among the unassigned
+// requests of the same config
+func (m *MemoryStore) PriorityRanking(r *Request) int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var requestTime *time.Time
+	for _, entry := range m.requests {
+		if entry.ID == r.ID && entry.ClusterID == 0 && sameParams(entry.ClusterParams, r.ClusterParams) {
+			requestTime = &entry.requestTime
+		}
+	}
+	if requestTime == nil {
+		return math.MaxInt64
+	}
+	rank := int64(1)
+	for _, entry := range m.requests {
+		if entry.ClusterID == 0 && sameParams(entry.ClusterParams, r.ClusterParams) && entry.requestTime.Before(*requestTime) {
+			rank++
+		}
+	}
+	return rank
+}
+
+// disable unassigned requests due to timeout
+func (m *MemoryStore) ClearTimeOut(timeOut time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	startTime := time.Now().Add(-1 * timeOut)
+	for _, r := range m.requests {
+		if !r.requestTime.After(startTime) && r.ClusterID == 0 {
+			r.ClusterID = -1
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"knative.dev/test-infra/pkg/mysql"
+)
+
+const (
+	mysqlDialect  = "mysql"
+	sqliteDialect = "sqlite"
+
+	// idPlaceholder is replaced by the auto-incremented primary key column of the
+	// dialect in the statements of migrations
+	idPlaceholder = "$ID"
+)
+
+// idColumns are the definitions of an auto-incremented primary key column in each dialect
+var idColumns = map[string]string{
+	mysqlDialect:  "ID int NOT NULL AUTO_INCREMENT PRIMARY KEY",
+	sqliteDialect: "ID INTEGER PRIMARY KEY AUTOINCREMENT",
+}
+
+// migration upgrades the schema to its version
+type migration struct {
+	version    int64
+	statements []string
+}
+
+// migrations are the versions of the schema in ascending order. Once released, a migration
+// must not be changed; upgrade the schema by appending a migration instead. The column order
+// of the tables must match populateCluster and populateRequest, as rows are read with SELECT *.
+var migrations = []migration{
+	{
+		// IF NOT EXISTS keeps the tables of databases created before migrations existed
+		version: 1,
+		statements: []string{`
+CREATE TABLE IF NOT EXISTS Clusters (
+  $ID,
+  ProjectID varchar(1023) NOT NULL,
+  Status varchar(1023) DEFAULT 'WIP',
+  Zone varchar(1023) NOT NULL,
+  Nodes int NOT NULL,
+  NodeType varchar(1023) NOT NULL
+)`, `
+CREATE TABLE IF NOT EXISTS Requests (
+  $ID,
+  AccessToken varchar(1023) NOT NULL,
+  RequestTime timestamp,
+  Zone varchar(1023) NOT NULL,
+  Nodes int NOT NULL,
+  NodeType varchar(1023) NOT NULL,
+  ProwJobID varchar(1023) NOT NULL,
+  ClusterID int DEFAULT 0
+)`},
+	},
+}
+
+// schemaVersion returns the version of the schema, 0 if no migration has been applied
+func schemaVersion(db *sql.DB) (int64, error) {
+	var version int64
+	err := db.QueryRow("SELECT COALESCE(MAX(Version), 0) FROM SchemaMigrations").Scan(&version)
+	return version, err
+}
+
+// migrate applies the migrations newer than the version of the schema, each in a transaction
+// recording its version in the SchemaMigrations table
+func migrate(db *sql.DB, dialect string, migrations []migration) error {
+	idColumn, ok := idColumns[dialect]
+	if !ok {
+		return fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS SchemaMigrations (Version int NOT NULL PRIMARY KEY)"); err != nil {
+		return fmt.Errorf("failed to create the SchemaMigrations table: %w", err)
+	}
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to get the schema version: %w", err)
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, statement := range m.statements {
+			if _, err := tx.Exec(strings.ReplaceAll(statement, idPlaceholder, idColumn)); err != nil {
+				return fmt.Errorf("failed to migrate the schema to version %d: %w", m.version, mysql.RollbackTx(tx, err))
+			}
+		}
+		if _, err := tx.Exec("INSERT INTO SchemaMigrations(Version) VALUES (?)", m.version); err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", m.version, mysql.RollbackTx(tx, err))
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to migrate the schema to version %d: %w", m.version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build cgo
+// +build cgo
+
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open SQLite database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	upgrade := migration{version: 2, statements: []string{"ALTER TABLE Clusters ADD COLUMN Region varchar(1023)"}}
+	broken := migration{version: 3, statements: []string{
+		"ALTER TABLE Clusters ADD COLUMN Broken int",
+		"INVALID STATEMENT",
+	}}
+	db := openSQLite(t)
+
+	steps := []struct {
+		name        string
+		migrations  []migration
+		wantErr     bool
+		wantVersion int64
+	}{
+		{"create", migrations, false, 1},
+		{"rerun", migrations, false, 1},
+		{"upgrade", append(migrations, upgrade), false, 2},
+		{"broken", append(migrations, upgrade, broken), true, 2},
+	}
+	for _, step := range steps {
+		err := migrate(db, sqliteDialect, step.migrations)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: migrate() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if version, err := schemaVersion(db); err != nil || version != step.wantVersion {
+			t.Errorf("%s: schemaVersion() = %d, %v, want %d", step.name, version, err, step.wantVersion)
+		}
+	}
+	if _, err := db.Exec("INSERT INTO Clusters(ProjectID, Zone, Nodes, NodeType, Region) VALUES ('p', 'z', 1, 't', 'r')"); err != nil {
+		t.Errorf("failed to use the column added by the upgrade: %v", err)
+	}
+	if _, err := db.Exec("SELECT Broken FROM Clusters"); err == nil {
+		t.Error("the column of the broken migration should be rolled back")
+	}
+}
+
+func TestMigrateExistingTables(t *testing.T) {
+	db := openSQLite(t)
+	if _, err := db.Exec("CREATE TABLE Clusters (ID INTEGER PRIMARY KEY AUTOINCREMENT, ProjectID varchar(1023) NOT NULL, Status varchar(1023) DEFAULT 'WIP', Zone varchar(1023) NOT NULL, Nodes int NOT NULL, NodeType varchar(1023) NOT NULL)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO Clusters(ProjectID, Zone, Nodes, NodeType) VALUES ('p', 'z', 1, 't')"); err != nil {
+		t.Fatalf("failed to insert cluster: %v", err)
+	}
+	if err := migrate(db, sqliteDialect, migrations); err != nil {
+		t.Fatalf("migrate() failed: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM Clusters").Scan(&count); err != nil || count != 1 {
+		t.Errorf("got %d clusters, %v after migrating, want the existing cluster kept", count, err)
+	}
+}
+
+func TestMigrateUnknownDialect(t *testing.T) {
+	if err := migrate(openSQLite(t), "postgres", migrations); err == nil {
+		t.Error("migrate() should fail for unknown dialects")
+	}
+}
@@ -21,4 +21,6 @@ type Response struct {
 	ClusterName string `json:"clusterName"`
 	ProjectID   string `json:"projectID"`
 	Zone        string `json:"zone"`
+	// Kubeconfig is the kubeconfig for accessing the cluster
+	Kubeconfig string `json:"kubeconfig,omitempty"`
 }
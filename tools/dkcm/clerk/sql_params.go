@@ -16,47 +16,52 @@ limitations under the License.
 
 package clerk
 
-import (
-	"fmt"
-)
+// Function option that returns a part of query for elements in ClusterParams, with a
+// placeholder for the value of the element, and the value
+type QueryClusterParamsOption func(*ClusterParams) (string, interface{})
 
-// Function option that returns a part of query for elements in ClusterParams
-type QueryClusterParamsOption func(*ClusterParams) string
+// Function option that returns a key value pair for database update, the value
+// being either a string or an int64
+type UpdateOption func() (string, interface{})
 
-// Function option that returns as a key value pair for database update
-type UpdateOption func() string
-
-// update query for changing string attributes
+// update option for changing string attributes
 func UpdateStringField(key string, value string) UpdateOption {
-	return func() string {
-		return fmt.Sprintf("%s = '%s'", key, value)
+	return func() (string, interface{}) {
+		return key, value
 	}
 }
 
-// update query for changing numeric attributes
+// update option for changing numeric attributes
 func UpdateNumField(key string, value int64) UpdateOption {
-	return func() string {
-		return fmt.Sprintf("%s = %d", key, value)
+	return func() (string, interface{}) {
+		return key, value
 	}
 }
 
+// updatableColumns are the columns of each table that can be updated, as column names
+// cannot be passed as parameters of a statement
+var updatableColumns = map[string]map[string]bool{
+	ClusterDB: {"ProjectID": true, "Status": true, "Zone": true, "Nodes": true, "NodeType": true},
+	RequestDB: {"Zone": true, "Nodes": true, "NodeType": true, "ProwJobID": true, "ClusterID": true},
+}
+
 // return a query string of zone
 func QueryZone() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("Zone = '%s'", cp.Zone)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "Zone = ?", cp.Zone
 	}
 }
 
 // return a query string of number of nodes
 func QueryNodes() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("Nodes = %d", cp.Nodes)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "Nodes = ?", cp.Nodes
 	}
 }
 
 // return a query string of node type
 func QueryNodeType() QueryClusterParamsOption {
-	return func(cp *ClusterParams) string {
-		return fmt.Sprintf("NodeType = '%s'", cp.NodeType)
+	return func(cp *ClusterParams) (string, interface{}) {
+		return "NodeType = ?", cp.NodeType
 	}
 }
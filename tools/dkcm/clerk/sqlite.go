@@ -0,0 +1,46 @@
+//go:build cgo
+// +build cgo
+
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"database/sql"
+	"fmt"
+
+	// register the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewSQLite returns a DBClient backed by the SQLite database at path, creating the
+// database if it doesn't exist and migrating its schema to the latest version. Use
+// ":memory:" for a throwaway database.
+func NewSQLite(path string) (*DBClient, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database %q: %w", path, err)
+	}
+	// SQLite doesn't support concurrent writers, and each connection to ":memory:"
+	// would be a different database
+	db.SetMaxOpenConns(1)
+	if err := migrate(db, sqliteDialect, migrations); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate SQLite database %q: %w", path, err)
+	}
+	return &DBClient{db}, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clerk
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// stores are the implementations of Operations the store tests run against, each
+// call returning an empty store. SQLite is added by sqlite_test.go when built with cgo.
+var stores = map[string]func(t *testing.T) Operations{
+	"memory": func(t *testing.T) Operations {
+		return NewMemoryStore()
+	},
+}
+
+// runStoreTest runs the test against each store
+func runStoreTest(t *testing.T, test func(t *testing.T, store Operations)) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+func insertRequest(t *testing.T, store Operations, cp *ClusterParams, requestTime time.Time) *Request {
+	r := NewRequest(AddProwJobID("fake-prow-job"), AddRequestTime(requestTime))
+	r.ClusterParams = cp
+	token, err := store.InsertRequest(r)
+	if err != nil {
+		t.Fatalf("InsertRequest() failed: %v", err)
+	}
+	got, err := store.GetRequest(token)
+	if err != nil {
+		t.Fatalf("GetRequest(%q) failed: %v", token, err)
+	}
+	return got
+}
+
+func TestStoreClusters(t *testing.T) {
+	runStoreTest(t, func(t *testing.T, store Operations) {
+		cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+		c := NewCluster(AddProjectID("knative-boskos-03"))
+		c.ClusterParams = cp
+		id, err := store.InsertCluster(c)
+		if err != nil {
+			t.Fatalf("InsertCluster() failed: %v", err)
+		}
+		if available, _ := store.CheckAvail(cp); available {
+			t.Error("CheckAvail() = true for a WIP cluster, want false")
+		}
+		if n := store.CheckNumStatus(cp, "WIP"); n != 1 {
+			t.Errorf("CheckNumStatus(WIP) = %d, want 1", n)
+		}
+
+		if err := store.UpdateCluster(id, UpdateStringField("Status", "Ready"), UpdateNumField("Nodes", 6)); err != nil {
+			t.Fatalf("UpdateCluster() failed: %v", err)
+		}
+		if available, _ := store.CheckAvail(cp); available {
+			t.Error("CheckAvail() = true for a cluster of another size, want false")
+		}
+		cp6 := NewClusterParams(AddZone("us-central1"), AddNodes(6), AddNodeType("e2-standard-4"))
+		if available, availID := store.CheckAvail(cp6); !available || availID != id {
+			t.Errorf("CheckAvail() = %v, %d, want true, %d", available, availID, id)
+		}
+
+		resp, err := store.GetCluster(id)
+		if err != nil {
+			t.Fatalf("GetCluster() failed: %v", err)
+		}
+		want := Response{ClusterName: NameCluster(id), ProjectID: "knative-boskos-03", Zone: "us-central1"}
+		if *resp != want {
+			t.Errorf("GetCluster() = %+v, want %+v", *resp, want)
+		}
+		clusters, err := store.ListClusters()
+		if err != nil || len(clusters) != 1 {
+			t.Fatalf("ListClusters() = %v, %v, want 1 cluster", clusters, err)
+		}
+		if got := clusters[0]; got.ID != id || got.Status != "Ready" || got.Nodes != 6 || got.ProjectID != "knative-boskos-03" {
+			t.Errorf("ListClusters() = %+v, want the updated cluster", got)
+		}
+
+		if err := store.DeleteCluster(id); err != nil {
+			t.Fatalf("DeleteCluster() failed: %v", err)
+		}
+		if _, err := store.GetCluster(id); err == nil {
+			t.Error("GetCluster() should fail for deleted clusters")
+		}
+		if err := store.DeleteCluster(id); err == nil {
+			t.Error("DeleteCluster() should fail for deleted clusters")
+		}
+	})
+}
+
+func TestStoreQuotedValues(t *testing.T) {
+	runStoreTest(t, func(t *testing.T, store Operations) {
+		// values of the HTTP form fields are stored as they are, not as SQL
+		cp := NewClusterParams(AddZone("us-central1' OR '1'='1"), AddNodes(4), AddNodeType("e2-standard-4'; DROP TABLE Clusters; --"))
+		c := NewCluster()
+		c.ClusterParams = cp
+		id, err := store.InsertCluster(c)
+		if err != nil {
+			t.Fatalf("InsertCluster() failed: %v", err)
+		}
+		if err := store.UpdateCluster(id, UpdateStringField("Status", "Ready' OR '1'='1")); err != nil {
+			t.Fatalf("UpdateCluster() failed: %v", err)
+		}
+		if n := store.CheckNumStatus(cp, "Ready' OR '1'='1"); n != 1 {
+			t.Errorf("CheckNumStatus() = %d, want 1", n)
+		}
+		if available, _ := store.CheckAvail(NewClusterParams(AddZone("' OR '1'='1"), AddNodes(4), AddNodeType("e2-standard-4"))); available {
+			t.Error("CheckAvail() = true for an unknown zone, want false")
+		}
+		r := insertRequest(t, store, cp, time.Now())
+		if r.Zone != cp.Zone || r.NodeType != cp.NodeType {
+			t.Errorf("GetRequest() = %v, want the quoted values", r)
+		}
+		if got := store.PriorityRanking(r); got != 1 {
+			t.Errorf("PriorityRanking() = %d, want 1", got)
+		}
+		if err := store.UpdateCluster(id, UpdateStringField("Status = 'Ready', Zone", "us-central1")); err == nil {
+			t.Error("UpdateCluster() should fail for unknown columns")
+		}
+	})
+}
+
+func TestStoreRequests(t *testing.T) {
+	runStoreTest(t, func(t *testing.T, store Operations) {
+		cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+		now := time.Now()
+		r := insertRequest(t, store, cp, now.Add(-time.Minute))
+		if r.ProwJobID != "fake-prow-job" || r.ClusterID != 0 || !sameParams(r.ClusterParams, cp) {
+			t.Errorf("GetRequest() = %v, want the inserted request", r)
+		}
+		if _, err := store.GetRequest("invalid-token"); err == nil {
+			t.Error("GetRequest() should fail for invalid tokens")
+		}
+
+		insertRequest(t, store, cp, now.Add(-2*time.Hour))
+		requests, err := store.ListRequests(time.Hour)
+		if err != nil || len(requests) != 1 || requests[0].ID != r.ID {
+			t.Errorf("ListRequests(1h) = %v, %v, want the request of the last hour", requests, err)
+		}
+
+		if err := store.UpdateRequest(r.ID, UpdateNumField("ClusterID", 3)); err != nil {
+			t.Fatalf("UpdateRequest() failed: %v", err)
+		}
+		requests, err = store.ListRequests(time.Hour)
+		if err != nil || len(requests) != 1 || requests[0].ClusterID != 3 {
+			t.Errorf("ListRequests(1h) = %v, %v, want the updated request", requests, err)
+		}
+	})
+}
+
+func TestStorePriorityRanking(t *testing.T) {
+	runStoreTest(t, func(t *testing.T, store Operations) {
+		cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+		otherCP := NewClusterParams(AddZone("us-east1"), AddNodes(4), AddNodeType("e2-standard-4"))
+		now := time.Now()
+		second := insertRequest(t, store, cp, now.Add(-time.Minute))
+		first := insertRequest(t, store, cp, now.Add(-2*time.Minute))
+		other := insertRequest(t, store, otherCP, now.Add(-3*time.Minute))
+
+		cases := []struct {
+			name string
+			r    *Request
+			want int64
+		}{
+			{"first", first, 1},
+			{"second", second, 2},
+			{"other config", other, 1},
+		}
+		for _, tt := range cases {
+			if got := store.PriorityRanking(tt.r); got != tt.want {
+				t.Errorf("PriorityRanking(%s) = %d, want %d", tt.name, got, tt.want)
+			}
+		}
+
+		// assigned requests are no longer ranked
+		if err := store.UpdateRequest(first.ID, UpdateNumField("ClusterID", 1)); err != nil {
+			t.Fatalf("UpdateRequest() failed: %v", err)
+		}
+		if got := store.PriorityRanking(second); got != 1 {
+			t.Errorf("PriorityRanking(second) = %d after assigning first, want 1", got)
+		}
+		if got := store.PriorityRanking(first); got != math.MaxInt64 {
+			t.Errorf("PriorityRanking(first) = %d after assigning it, want %d", got, int64(math.MaxInt64))
+		}
+	})
+}
+
+func TestStoreClearTimeOut(t *testing.T) {
+	runStoreTest(t, func(t *testing.T, store Operations) {
+		cp := NewClusterParams(AddZone("us-central1"), AddNodes(4), AddNodeType("e2-standard-4"))
+		now := time.Now()
+		stale := insertRequest(t, store, cp, now.Add(-2*time.Hour))
+		fresh := insertRequest(t, store, cp, now.Add(-time.Minute))
+		if err := store.ClearTimeOut(time.Hour); err != nil {
+			t.Fatalf("ClearTimeOut() failed: %v", err)
+		}
+		requests, err := store.ListRequests(3 * time.Hour)
+		if err != nil {
+			t.Fatalf("ListRequests() failed: %v", err)
+		}
+		want := map[int64]int64{stale.ID: -1, fresh.ID: 0}
+		for _, r := range requests {
+			if r.ClusterID != want[r.ID] {
+				t.Errorf("got ClusterID %d for request %d, want %d", r.ClusterID, r.ID, want[r.ID])
+			}
+		}
+	})
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 
 	"knative.dev/test-infra/pkg/mysql"
+	"knative.dev/test-infra/tools/dkcm/clerk"
 	"knative.dev/test-infra/tools/dkcm/mainservice"
+	"knative.dev/test-infra/tools/dkcm/provisioner"
 )
 
 func main() {
@@ -21,14 +24,40 @@ func main() {
 
 	gcpServiceAccount := flag.String("gcp-service-account", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "JSON key file for GCP service account")
 
+	store := flag.String("store", "mysql", "Store of clusters and requests, one of 'mysql' (Cloud SQL database configured by the database flags), 'sqlite' or 'memory'")
+	sqlitePath := flag.String("sqlite-path", "dkcm.db", "SQLite database file, used with --store=sqlite")
+
+	provisionerType := flag.String("provisioner", provisioner.GKE, "Cluster provisioner, one of 'gke' (GKE clusters in Boskos projects) or 'kind' (local kind clusters)")
+
 	flag.Parse()
 
-	dbConfig, err := mysql.ConfigureDB(*dbUserSF, *dbPassSF, *dbHost, *dbPort, *dbName)
+	var ops clerk.Operations
+	var err error
+	switch *store {
+	case "mysql":
+		var dbConfig *mysql.DBConfig
+		dbConfig, err = mysql.ConfigureDB(*dbUserSF, *dbPassSF, *dbHost, *dbPort, *dbName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ops, err = clerk.NewDB(dbConfig)
+	case "sqlite":
+		ops, err = clerk.NewSQLite(*sqlitePath)
+	case "memory":
+		ops = clerk.NewMemoryStore()
+	default:
+		err = fmt.Errorf("unknown store %q, must be one of 'mysql', 'sqlite' or 'memory'", *store)
+	}
+	if err != nil {
+		log.Fatalf("Failed to create Clerk client: %v", err)
+	}
+
+	p, err := provisioner.New(*provisionerType, *boskosClientHost, *gcpServiceAccount)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := mainservice.Start(dbConfig, *boskosClientHost, *gcpServiceAccount); err != nil {
+	if err := mainservice.Start(ops, p); err != nil {
 		log.Fatalf("Failed to start main service: %v", err)
 	}
 }
@@ -18,8 +18,6 @@ package mainservice
 
 const (
 	// default parameters for mainservice server
-	DefaultClusterName   = "e2e-cls"
-	DefaultNetworkName   = "e2e-network"
 	DefaultZone          = "us-west1"
 	DefaultNodeType      = "e2-standard-4"
 	DefaultOverProvision = 5
@@ -36,6 +34,7 @@ const (
 	// Field for use when query Cluster db
 	Status    = "Status"
 	ClusterID = "ClusterID"
+	ProjectID = "ProjectID"
 
 	// time interval to examine timeout requests
 	CheckInterval = 2
@@ -22,23 +22,26 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
 
-	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
-	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
-	"knative.dev/test-infra/pkg/mysql"
 	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/provisioner"
 )
 
 var (
 	// channel serves as a lock for go routine
-	chanLock             = make(chan struct{})
-	boskosClient         *boskos.Client
-	dbClient             *clerk.DBClient
-	serviceAccount       string
+	chanLock             = make(chan struct{}, 1)
+	dbClient             clerk.Operations
+	prov                 provisioner.Provisioner
 	DefaultClusterParams = clerk.ClusterParams{Zone: DefaultZone, Nodes: DefaultNodesCount, NodeType: DefaultNodeType}
+
+	// zones and node types requested are passed to gcloud, so only GCP region, zone and
+	// machine type names are accepted, e.g. us-west1, us-west1-a and e2-standard-4
+	zoneRegex     = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+(-[a-z])?$`)
+	nodeTypeRegex = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
 )
 
 // Response to Prow
@@ -48,21 +51,10 @@ type ServiceResponse struct {
 	ClusterInfo *clerk.Response `json:"clusterInfo"`
 }
 
-func Start(dbConfig *mysql.DBConfig, boskosClientHost, gcpServiceAccount string) error {
-	var err error
-	boskosClient, err = boskos.NewClient(boskosClientHost, "", "")
-	if err != nil {
-		return fmt.Errorf("failed to create Boskos client: %w", err)
-	}
-	dbClient, err = clerk.NewDB(dbConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create Clerk client: %w", err)
-	}
-	serviceAccount = gcpServiceAccount
-	server := http.NewServeMux()
-	server.HandleFunc("/request-cluster", handleNewClusterRequest)
-	server.HandleFunc("/get-cluster", handleGetCluster)
-	server.HandleFunc("/clean-cluster", handleCleanCluster)
+func Start(store clerk.Operations, p provisioner.Provisioner) error {
+	dbClient = store
+	prov = p
+	server := newServer()
 	// use PORT environment variable, or default to 8080
 	port := DefaultPort
 	if fromEnv := os.Getenv("PORT"); fromEnv != "" {
@@ -73,6 +65,20 @@ func Start(dbConfig *mysql.DBConfig, boskosClientHost, gcpServiceAccount string)
 	return http.ListenAndServe(fmt.Sprintf(":%v", port), server)
 }
 
+// newServer creates the handler of all the endpoints of the main service
+func newServer() *http.ServeMux {
+	server := http.NewServeMux()
+	server.HandleFunc("/request-cluster", handleNewClusterRequest)
+	server.HandleFunc("/get-cluster", handleGetCluster)
+	server.HandleFunc("/clean-cluster", handleCleanCluster)
+	return server
+}
+
+// toProvisionerCluster identifies the cluster of the response for the provisioner
+func toProvisionerCluster(c *clerk.Response) *provisioner.Cluster {
+	return &provisioner.Cluster{Name: c.ClusterName, ProjectID: c.ProjectID, Zone: c.Zone}
+}
+
 // handle cleaning cluster request after usage
 func handleCleanCluster(w http.ResponseWriter, req *http.Request) {
 	// add project name
@@ -92,9 +98,9 @@ func handleCleanCluster(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, fmt.Sprintf("there is an error deleting the cluster with the token: %v, please try again", err), http.StatusForbidden)
 		return
 	}
-	err = boskosClient.ReleaseGKEProject(c.ProjectID)
+	err = prov.Delete(toProvisionerCluster(c))
 	if err != nil {
-		http.Error(w, "there is an error releasing Boskos's project. Please try again.", http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("there is an error deleting the cluster: %v. Please try again.", err), http.StatusInternalServerError)
 		return
 	}
 }
@@ -116,15 +122,25 @@ func checkPoolCap(cp *clerk.ClusterParams) {
 	<-chanLock
 }
 
-// assign clusters if available upon request
-func CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
-	project, err := boskosClient.AcquireGKEProject(boskos.GKEProjectResource)
+// provision creates the cluster with the provisioner and checks its health, unhealthy
+// clusters are deleted
+func provision(clusterID int64, cp *clerk.ClusterParams) (*provisioner.Cluster, error) {
+	pc, err := prov.Create(clerk.NameCluster(clusterID), cp)
 	if err != nil {
-		log.Printf("Failed to acquire a project from boskos: %v", err)
-		return
+		return nil, err
+	}
+	if err := prov.CheckHealth(pc); err != nil {
+		if deleteErr := prov.Delete(pc); deleteErr != nil {
+			log.Printf("Failed to delete unhealthy cluster: %v", deleteErr)
+		}
+		return nil, err
 	}
-	projectName := project.Name
-	c := clerk.NewCluster(clerk.AddProjectID(projectName))
+	return pc, nil
+}
+
+// create a cluster with the provisioner, and mark it as ready once it's healthy
+func CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
+	c := clerk.NewCluster()
 	c.ClusterParams = cp
 	clusterID, err := dbClient.InsertCluster(c)
 	wg.Done()
@@ -132,32 +148,17 @@ func CreateCluster(cp *clerk.ClusterParams, wg *sync.WaitGroup) {
 		log.Printf("Failed to insert a new Cluster entry: %v", err)
 		return
 	}
-	if err := kubetest2.Run(&kubetest2.Options{}, &kubetest2.GKEClusterConfig{
-		GCPServiceAccount: serviceAccount,
-		GCPProjectID:      projectName,
-		Name:              DefaultClusterName,
-		Region:            cp.Zone,
-		Machine:           cp.NodeType,
-		MinNodes:          int(cp.Nodes),
-		MaxNodes:          int(cp.Nodes),
-		Network:           DefaultNetworkName,
-		Environment:       "prod",
-		Version:           "latest",
-		Scopes:            "cloud-platform",
-	}); err != nil {
+	pc, err := provision(clusterID, cp)
+	if err != nil {
+		log.Printf("Failed to create cluster %d: %v", clusterID, err)
 		if err := dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Fail)); err != nil {
-			log.Printf("Failed to insert delete new Cluster entry: %v", err)
-			return
-		}
-		err = boskosClient.ReleaseGKEProject(c.ProjectID)
-		if err != nil {
-			log.Printf("Failed to release Boskos Project: %v", err)
-			return
+			log.Printf("Failed to update the Cluster entry: %v", err)
 		}
 		return
 	}
-	if err := dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, Ready)); err != nil {
-		log.Printf("Failed to insert a new Cluster entry: %v", err)
+	if err := dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(ProjectID, pc.ProjectID),
+		clerk.UpdateStringField(Status, Ready)); err != nil {
+		log.Printf("Failed to update the Cluster entry: %v", err)
 		return
 	}
 }
@@ -180,6 +181,12 @@ func AssignCluster(token string, w http.ResponseWriter) {
 			http.Error(w, fmt.Sprintf("there is an error getting available clusters: %v, please try again", err), http.StatusInternalServerError)
 			return
 		}
+		kubeconfig, err := prov.Kubeconfig(toProvisionerCluster(response))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("there is an error getting the kubeconfig of the cluster: %v, please try again", err), http.StatusInternalServerError)
+			return
+		}
+		response.Kubeconfig = string(kubeconfig)
 		dbClient.UpdateRequest(r.ID, clerk.UpdateNumField(ClusterID, clusterID))
 		dbClient.UpdateCluster(clusterID, clerk.UpdateStringField(Status, InUse))
 		serviceResponse = &ServiceResponse{IsReady: true, Message: "Your cluster is ready!", ClusterInfo: response}
@@ -209,6 +216,14 @@ func handleNewClusterRequest(w http.ResponseWriter, req *http.Request) {
 	if zone == "" {
 		zone = DefaultZone
 	}
+	if !zoneRegex.MatchString(zone) {
+		http.Error(w, fmt.Sprintf("invalid zone %q", zone), http.StatusBadRequest)
+		return
+	}
+	if !nodeTypeRegex.MatchString(nodesType) {
+		http.Error(w, fmt.Sprintf("invalid node type %q", nodesType), http.StatusBadRequest)
+		return
+	}
 	cp := clerk.NewClusterParams(clerk.AddZone(zone), clerk.AddNodes(int64(nodesCount)), clerk.AddNodeType(nodesType))
 	r := clerk.NewRequest(clerk.AddProwJobID(prowJobID), clerk.AddRequestTime(time.Now()))
 	r.ClusterParams = cp
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mainservice
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/dkcm/clerk"
+	"knative.dev/test-infra/tools/dkcm/provisioner"
+)
+
+// waitFor polls the condition until it's met or a timeout
+func waitFor(t *testing.T, what string, condition func() bool) {
+	for deadline := time.Now().Add(10 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestClusterLifecycle(t *testing.T) {
+	fake := provisioner.NewFake()
+	dbClient = clerk.NewMemoryStore()
+	prov = fake
+	server := httptest.NewServer(newServer())
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+"/request-cluster", url.Values{"prowjobid": {"fake-prow-job"}, "nodes": {"2"}})
+	if err != nil {
+		t.Fatalf("failed to request a cluster: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d requesting a cluster: %s", resp.StatusCode, body)
+	}
+	token := string(body)
+
+	cp := clerk.NewClusterParams(clerk.AddZone(DefaultZone), clerk.AddNodes(2), clerk.AddNodeType(DefaultNodeType))
+	waitFor(t, "the pool to be ready", func() bool {
+		return dbClient.CheckNumStatus(cp, Ready) == DefaultOverProvision
+	})
+	if n := len(fake.Clusters()); n != DefaultOverProvision {
+		t.Fatalf("got %d clusters created, want %d", n, DefaultOverProvision)
+	}
+
+	resp, err = http.Get(server.URL + "/get-cluster?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to get the cluster: %v", err)
+	}
+	var serviceResponse ServiceResponse
+	err = json.NewDecoder(resp.Body).Decode(&serviceResponse)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("failed to decode the response: %v", err)
+	}
+	if !serviceResponse.IsReady || serviceResponse.ClusterInfo == nil {
+		t.Fatalf("got response %+v, want a ready cluster", serviceResponse)
+	}
+	clusterName := serviceResponse.ClusterInfo.ClusterName
+	if want := "current-context: " + clusterName + "\n"; serviceResponse.ClusterInfo.Kubeconfig != want {
+		t.Errorf("got kubeconfig %q, want %q", serviceResponse.ClusterInfo.Kubeconfig, want)
+	}
+	if n := dbClient.CheckNumStatus(cp, InUse); n != 1 {
+		t.Errorf("got %d clusters in use, want 1", n)
+	}
+
+	resp, err = http.Get(server.URL + "/clean-cluster?token=" + token)
+	if err != nil {
+		t.Fatalf("failed to clean the cluster: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d cleaning the cluster", resp.StatusCode)
+	}
+	for _, name := range fake.Clusters() {
+		if name == clusterName {
+			t.Errorf("cluster %q still exists after cleaning it", clusterName)
+		}
+	}
+	if n := dbClient.CheckNumStatus(cp, InUse); n != 0 {
+		t.Errorf("got %d clusters in use after cleaning, want 0", n)
+	}
+}
+
+func TestNewClusterRequestInvalidParams(t *testing.T) {
+	dbClient = clerk.NewMemoryStore()
+	prov = provisioner.NewFake()
+	server := httptest.NewServer(newServer())
+	defer server.Close()
+
+	cases := []struct {
+		name   string
+		params url.Values
+		want   int
+	}{
+		{"zone", url.Values{"zone": {"us-central1-a"}, "nodeType": {"n1-highmem-8"}}, http.StatusOK},
+		{"region", url.Values{"zone": {"us-central1"}}, http.StatusOK},
+		{"zone with arguments", url.Values{"zone": {"us-central1 --impersonate-service-account=admin"}}, http.StatusBadRequest},
+		{"zone with quotes", url.Values{"zone": {"us-central1'"}}, http.StatusBadRequest},
+		{"node type with arguments", url.Values{"nodeType": {"e2-standard-4 --project=other"}}, http.StatusBadRequest},
+		{"node type with flag", url.Values{"nodeType": {"--verbosity=debug"}}, http.StatusBadRequest},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.PostForm(server.URL+"/request-cluster", tt.params)
+			if err != nil {
+				t.Fatalf("failed to request a cluster: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateClusterUnhealthy(t *testing.T) {
+	fake := provisioner.NewFake()
+	fake.Unhealthy[clerk.NameCluster(1)] = true
+	dbClient = clerk.NewMemoryStore()
+	prov = fake
+
+	cp := clerk.NewClusterParams(clerk.AddZone(DefaultZone), clerk.AddNodes(2), clerk.AddNodeType(DefaultNodeType))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	CreateCluster(cp, &wg)
+	if n := dbClient.CheckNumStatus(cp, Fail); n != 1 {
+		t.Errorf("got %d failed clusters, want 1", n)
+	}
+	if len(fake.Clusters()) != 0 {
+		t.Errorf("got clusters %v, want the unhealthy cluster deleted", fake.Clusters())
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"sync"
+
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+// Fake is an in-memory provisioner for tests, its clusters are created instantly
+type Fake struct {
+	mutex    sync.Mutex
+	clusters map[string]*Cluster
+	// CreateErr is returned by Create if set
+	CreateErr error
+	// Unhealthy clusters fail the health check, keyed by name
+	Unhealthy map[string]bool
+}
+
+// NewFake creates a Fake provisioner without any cluster
+func NewFake() *Fake {
+	return &Fake{clusters: make(map[string]*Cluster), Unhealthy: make(map[string]bool)}
+}
+
+// Create records the cluster, unless CreateErr is set
+func (f *Fake) Create(name string, cp *clerk.ClusterParams) (*Cluster, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.CreateErr != nil {
+		return nil, f.CreateErr
+	}
+	if _, ok := f.clusters[name]; ok {
+		return nil, fmt.Errorf("cluster %q already exists", name)
+	}
+	c := &Cluster{Name: name, ProjectID: "fake-project-" + name, Zone: cp.Zone}
+	f.clusters[name] = c
+	return c, nil
+}
+
+// Delete removes the cluster
+func (f *Fake) Delete(c *Cluster) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.clusters[c.Name]; !ok {
+		return fmt.Errorf("cluster %q doesn't exist", c.Name)
+	}
+	delete(f.clusters, c.Name)
+	return nil
+}
+
+// CheckHealth fails if the cluster doesn't exist or is unhealthy
+func (f *Fake) CheckHealth(c *Cluster) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.clusters[c.Name]; !ok {
+		return fmt.Errorf("cluster %q doesn't exist", c.Name)
+	}
+	if f.Unhealthy[c.Name] {
+		return fmt.Errorf("cluster %q is unhealthy", c.Name)
+	}
+	return nil
+}
+
+// Kubeconfig returns a fake kubeconfig naming the cluster
+func (f *Fake) Kubeconfig(c *Cluster) ([]byte, error) {
+	if err := f.CheckHealth(c); err != nil {
+		return nil, err
+	}
+	return []byte("current-context: " + c.Name + "\n"), nil
+}
+
+// Clusters returns the names of the existing clusters
+func (f *Fake) Clusters() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var names []string
+	for name := range f.clusters {
+		names = append(names, name)
+	}
+	return names
+}
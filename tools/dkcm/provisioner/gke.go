@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos"
+	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+const (
+	gkeNetworkName = "e2e-network"
+	gkeRunning     = "RUNNING"
+)
+
+// gkeProvisioner creates GKE clusters with kubetest2, each in a project acquired from Boskos
+type gkeProvisioner struct {
+	boskosClient   boskos.Operation
+	serviceAccount string
+	// run creates the cluster, it's kubetest2.Run except in unit tests
+	run func(*kubetest2.Options, *kubetest2.GKEClusterConfig) error
+}
+
+// NewGKE creates a GKE provisioner acquiring projects with a Boskos client
+func NewGKE(boskosClientHost, gcpServiceAccount string) (Provisioner, error) {
+	boskosClient, err := boskos.NewClient(boskosClientHost, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Boskos client: %w", err)
+	}
+	return &gkeProvisioner{boskosClient: boskosClient, serviceAccount: gcpServiceAccount, run: kubetest2.Run}, nil
+}
+
+// Create acquires a project from Boskos and creates the cluster in it, the project is
+// released if the cluster cannot be created
+func (p *gkeProvisioner) Create(name string, cp *clerk.ClusterParams) (*Cluster, error) {
+	project, err := p.boskosClient.AcquireGKEProject(boskos.GKEProjectResource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire a project from Boskos: %w", err)
+	}
+	if err := p.run(&kubetest2.Options{}, &kubetest2.GKEClusterConfig{
+		GCPServiceAccount: p.serviceAccount,
+		GCPProjectID:      project.Name,
+		Name:              name,
+		Region:            cp.Zone,
+		Machine:           cp.NodeType,
+		MinNodes:          int(cp.Nodes),
+		MaxNodes:          int(cp.Nodes),
+		Network:           gkeNetworkName,
+		Environment:       "prod",
+		Version:           "latest",
+		Scopes:            "cloud-platform",
+	}); err != nil {
+		if releaseErr := p.boskosClient.ReleaseGKEProject(project.Name); releaseErr != nil {
+			return nil, fmt.Errorf("failed to create cluster: %v, and failed to release Boskos project %q: %w",
+				err, project.Name, releaseErr)
+		}
+		return nil, fmt.Errorf("failed to create cluster: %w", err)
+	}
+	return &Cluster{Name: name, ProjectID: project.Name, Zone: cp.Zone}, nil
+}
+
+// Delete releases the project of the cluster, which is cleaned up by the Boskos janitor
+func (p *gkeProvisioner) Delete(c *Cluster) error {
+	if err := p.boskosClient.ReleaseGKEProject(c.ProjectID); err != nil {
+		return fmt.Errorf("failed to release Boskos project %q: %w", c.ProjectID, err)
+	}
+	return nil
+}
+
+// CheckHealth checks that the cluster is running
+func (p *gkeProvisioner) CheckHealth(c *Cluster) error {
+	out, err := runCommand(nil, "gcloud", "container", "clusters", "describe", c.Name,
+		"--project="+c.ProjectID, "--region="+c.Zone, "--format=value(status)")
+	if err != nil {
+		return fmt.Errorf("failed to describe cluster %q: %w", c.Name, err)
+	}
+	if status := strings.TrimSpace(out); status != gkeRunning {
+		return fmt.Errorf("cluster %q is %s, not %s", c.Name, status, gkeRunning)
+	}
+	return nil
+}
+
+// Kubeconfig gets the credentials of the cluster in a temporary kubeconfig
+func (p *gkeProvisioner) Kubeconfig(c *Cluster) ([]byte, error) {
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if _, err := runCommand(append(os.Environ(), "KUBECONFIG="+f.Name()), "gcloud", "container", "clusters",
+		"get-credentials", c.Name, "--project="+c.ProjectID, "--region="+c.Zone); err != nil {
+		return nil, fmt.Errorf("failed to get credentials of cluster %q: %w", c.Name, err)
+	}
+	return ioutil.ReadFile(f.Name())
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+const kindWaitTimeout = "5m"
+
+// kindProvisioner creates local kind clusters, with a worker node for each node of
+// the params. Zones and node types don't apply to kind clusters.
+type kindProvisioner struct{}
+
+// NewKind creates a kind provisioner, it requires the kind and kubectl binaries
+func NewKind() Provisioner {
+	return &kindProvisioner{}
+}
+
+// kindConfig generates the kind configuration of a cluster with a control plane and
+// the given number of worker nodes
+func kindConfig(nodes int64) string {
+	lines := []string{"kind: Cluster", "apiVersion: kind.x-k8s.io/v1alpha4", "nodes:", "- role: control-plane"}
+	for i := int64(0); i < nodes; i++ {
+		lines = append(lines, "- role: worker")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Create creates the cluster and waits for the control plane to be ready
+func (p *kindProvisioner) Create(name string, cp *clerk.ClusterParams) (*Cluster, error) {
+	f, err := ioutil.TempFile("", "kind-config")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(kindConfig(cp.Nodes))
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runCommand(nil, "kind", "create", "cluster", "--name="+name, "--config="+f.Name(),
+		"--wait="+kindWaitTimeout); err != nil {
+		return nil, fmt.Errorf("failed to create kind cluster %q: %w", name, err)
+	}
+	return &Cluster{Name: name, Zone: cp.Zone}, nil
+}
+
+// Delete deletes the cluster
+func (p *kindProvisioner) Delete(c *Cluster) error {
+	if _, err := runCommand(nil, "kind", "delete", "cluster", "--name="+c.Name); err != nil {
+		return fmt.Errorf("failed to delete kind cluster %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// CheckHealth checks that the API server of the cluster is ready
+func (p *kindProvisioner) CheckHealth(c *Cluster) error {
+	out, err := runCommand(nil, "kubectl", "--context=kind-"+c.Name, "get", "--raw=/readyz")
+	if err != nil {
+		return fmt.Errorf("failed to check readiness of kind cluster %q: %w", c.Name, err)
+	}
+	if strings.TrimSpace(out) != "ok" {
+		return fmt.Errorf("kind cluster %q is not ready: %s", c.Name, out)
+	}
+	return nil
+}
+
+// Kubeconfig gets the kubeconfig of the cluster from kind
+func (p *kindProvisioner) Kubeconfig(c *Cluster) ([]byte, error) {
+	out, err := runCommand(nil, "kind", "get", "kubeconfig", "--name="+c.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig of kind cluster %q: %w", c.Name, err)
+	}
+	return []byte(out), nil
+}
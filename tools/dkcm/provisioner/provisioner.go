@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+const (
+	// GKE provisions GKE clusters in Boskos projects with kubetest2
+	GKE = "gke"
+	// Kind provisions local kind clusters
+	Kind = "kind"
+)
+
+// Cluster identifies a cluster created by a Provisioner
+type Cluster struct {
+	Name string
+	// ProjectID is the GCP project of the cluster, empty for local clusters
+	ProjectID string
+	Zone      string
+}
+
+// Provisioner creates and deletes the clusters managed by DKCM
+type Provisioner interface {
+	// Create creates a cluster with the params, it blocks until the cluster is created
+	Create(name string, cp *clerk.ClusterParams) (*Cluster, error)
+	// Delete deletes the cluster and releases the resources it uses
+	Delete(c *Cluster) error
+	// CheckHealth returns an error if the cluster is not ready for use
+	CheckHealth(c *Cluster) error
+	// Kubeconfig returns the kubeconfig for accessing the cluster
+	Kubeconfig(c *Cluster) ([]byte, error)
+}
+
+// New creates the provisioner of the given type
+func New(provisionerType, boskosClientHost, gcpServiceAccount string) (Provisioner, error) {
+	switch provisionerType {
+	case GKE:
+		return NewGKE(boskosClientHost, gcpServiceAccount)
+	case Kind:
+		return NewKind(), nil
+	default:
+		return nil, fmt.Errorf("unknown provisioner %q, must be one of %q or %q", provisionerType, GKE, Kind)
+	}
+}
+
+// runCommand is defined for easy mocking in unit tests.
+var runCommand = execCommand
+
+// execCommand runs the binary with the environment, or the current one if nil, and returns
+// its standard output. Unlike cmd.RunCommand the arguments are never split, so values of
+// the cluster params can't inject arguments.
+func execCommand(env []string, name string, args ...string) (string, error) {
+	c := exec.Command(name, args...)
+	c.Env = env
+	var eb bytes.Buffer
+	c.Stderr = &eb
+	out, err := c.Output()
+	if err != nil {
+		code := 1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		} else {
+			eb.WriteString(err.Error())
+		}
+		return string(out), &cmd.CommandLineError{
+			Command:     strings.Join(append([]string{name}, args...), " "),
+			ErrorOutput: eb.Bytes(),
+			ErrorCode:   code,
+		}
+	}
+	return string(out), nil
+}
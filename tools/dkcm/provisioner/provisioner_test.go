@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provisioner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	boskoscommon "sigs.k8s.io/boskos/common"
+
+	"knative.dev/test-infra/pkg/clustermanager/e2e-tests/boskos/fake"
+	"knative.dev/test-infra/pkg/clustermanager/kubetest2"
+	"knative.dev/test-infra/pkg/cmd"
+	"knative.dev/test-infra/tools/dkcm/clerk"
+)
+
+var fakeParams = clerk.NewClusterParams(clerk.AddZone("us-central1"), clerk.AddNodes(2), clerk.AddNodeType("e2-standard-4"))
+
+// mockCommands replaces runCommand with one recording the arguments of the commands run
+// and returning the output of the first prefix matching the joined arguments
+func mockCommands(t *testing.T, outputs map[string]string) *[][]string {
+	var commands [][]string
+	orig := runCommand
+	t.Cleanup(func() { runCommand = orig })
+	runCommand = func(env []string, name string, args ...string) (string, error) {
+		command := append([]string{name}, args...)
+		commands = append(commands, command)
+		for prefix, out := range outputs {
+			if strings.HasPrefix(strings.Join(command, " "), prefix) {
+				return out, nil
+			}
+		}
+		return "", errors.New("command failed")
+	}
+	return &commands
+}
+
+func TestGKECreate(t *testing.T) {
+	cases := []struct {
+		name     string
+		runErr   error
+		wantErr  bool
+		wantBusy bool
+	}{
+		{"created", nil, false, true},
+		{"project released on failure", errors.New("stockout"), true, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			boskosClient := &fake.FakeBoskosClient{}
+			boskosClient.NewGKEProject("fake-project")
+			var got *kubetest2.GKEClusterConfig
+			p := &gkeProvisioner{boskosClient: boskosClient, run: func(opts *kubetest2.Options, cc *kubetest2.GKEClusterConfig) error {
+				got = cc
+				return tt.runErr
+			}}
+
+			c, err := p.Create("e2e-cluster1", fakeParams)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.GCPProjectID != "fake-project" || got.Name != "e2e-cluster1" || got.Region != "us-central1" || got.MinNodes != 2 {
+				t.Errorf("got cluster config %+v, want cluster e2e-cluster1 with 2 nodes in fake-project", got)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(c, &Cluster{Name: "e2e-cluster1", ProjectID: "fake-project", Zone: "us-central1"}) {
+				t.Errorf("Create() = %+v, want cluster e2e-cluster1 in fake-project", c)
+			}
+			if busy := boskosClient.GetResources()[0].State == boskoscommon.Busy; busy != tt.wantBusy {
+				t.Errorf("got project busy %v, want %v", busy, tt.wantBusy)
+			}
+		})
+	}
+}
+
+func TestGKECheckHealth(t *testing.T) {
+	p := &gkeProvisioner{}
+	c := &Cluster{Name: "e2e-cluster1", ProjectID: "fake-project", Zone: "us-central1"}
+	commands := mockCommands(t, map[string]string{"gcloud container clusters describe": "RUNNING\n"})
+	if err := p.CheckHealth(c); err != nil {
+		t.Errorf("CheckHealth() failed: %v", err)
+	}
+	want := []string{"gcloud", "container", "clusters", "describe", "e2e-cluster1",
+		"--project=fake-project", "--region=us-central1", "--format=value(status)"}
+	if !reflect.DeepEqual(*commands, [][]string{want}) {
+		t.Errorf("got commands %q, want %q", *commands, want)
+	}
+
+	// values are passed as single arguments, even with spaces
+	c.Zone = "us-central1 --impersonate-service-account=admin"
+	commands = mockCommands(t, map[string]string{"gcloud container clusters describe": "RUNNING\n"})
+	p.CheckHealth(c)
+	if got := (*commands)[0][6]; got != "--region="+c.Zone {
+		t.Errorf("got argument %q, want %q", got, "--region="+c.Zone)
+	}
+
+	mockCommands(t, map[string]string{"gcloud container clusters describe": "PROVISIONING\n"})
+	if err := p.CheckHealth(c); err == nil {
+		t.Error("CheckHealth() should fail for clusters being provisioned")
+	}
+}
+
+func TestKindConfig(t *testing.T) {
+	want := `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+- role: control-plane
+- role: worker
+- role: worker
+`
+	if got := kindConfig(2); got != want {
+		t.Errorf("kindConfig(2) = %q, want %q", got, want)
+	}
+}
+
+func TestKindLifecycle(t *testing.T) {
+	commands := mockCommands(t, map[string]string{
+		"kind create cluster":  "",
+		"kubectl":              "ok",
+		"kind get kubeconfig":  "kind: Config\n",
+		"kind delete cluster ": "",
+	})
+	p := NewKind()
+	c, err := p.Create("e2e-cluster1", fakeParams)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if err := p.CheckHealth(c); err != nil {
+		t.Errorf("CheckHealth() failed: %v", err)
+	}
+	if kubeconfig, err := p.Kubeconfig(c); err != nil || string(kubeconfig) != "kind: Config\n" {
+		t.Errorf("Kubeconfig() = %q, %v, want the kubeconfig from kind", kubeconfig, err)
+	}
+	if err := p.Delete(c); err != nil {
+		t.Errorf("Delete() failed: %v", err)
+	}
+
+	want := []string{
+		"kind create cluster --name=e2e-cluster1 --config=",
+		"kubectl --context=kind-e2e-cluster1 get --raw=/readyz",
+		"kind get kubeconfig --name=e2e-cluster1",
+		"kind delete cluster --name=e2e-cluster1",
+	}
+	if len(*commands) != len(want) {
+		t.Fatalf("got commands %v, want %v", *commands, want)
+	}
+	for i, prefix := range want {
+		if got := strings.Join((*commands)[i], " "); !strings.HasPrefix(got, prefix) {
+			t.Errorf("got command %q, want prefix %q", got, prefix)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	c, err := f.Create("e2e-cluster1", fakeParams)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := f.Create("e2e-cluster1", fakeParams); err == nil {
+		t.Error("Create() should fail for existing clusters")
+	}
+	f.Unhealthy["e2e-cluster1"] = true
+	if err := f.CheckHealth(c); err == nil {
+		t.Error("CheckHealth() should fail for unhealthy clusters")
+	}
+	if err := f.Delete(c); err != nil {
+		t.Errorf("Delete() failed: %v", err)
+	}
+	if len(f.Clusters()) != 0 {
+		t.Errorf("got clusters %v after deleting, want none", f.Clusters())
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	out, err := execCommand(nil, "echo", "a b", "c;d")
+	if err != nil || out != "a b c;d\n" {
+		t.Errorf("execCommand() = %q, %v, want the arguments echoed as is", out, err)
+	}
+	_, err = execCommand([]string{"MSG=failed"}, "sh", "-c", "echo $MSG >&2; exit 3")
+	if cle, ok := err.(*cmd.CommandLineError); !ok || cle.ErrorCode != 3 || string(cle.ErrorOutput) != "failed\n" {
+		t.Errorf("execCommand() error = %#v, want exit code 3 with the error output", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("eks", "", ""); err == nil {
+		t.Error("New() should fail for unknown provisioners")
+	}
+	if p, err := New(Kind, "", ""); err != nil || p == nil {
+		t.Errorf("New(%q) = %v, %v, want a kind provisioner", Kind, p, err)
+	}
+}
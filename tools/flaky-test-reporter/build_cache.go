@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// build_cache.go caches parsed junit results of finished builds on local disk,
+// so that repeated runs only need to fetch new builds from GCS
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/pkg/junit"
+)
+
+// buildCache stores junit results of each build as a json file under
+// dir/JOB_NAME/BUILD_ID.json. A nil buildCache is valid and caches nothing.
+type buildCache struct {
+	dir string
+}
+
+// newBuildCache returns a buildCache storing files under dir, or nil if dir is empty
+func newBuildCache(dir string) *buildCache {
+	if dir == "" {
+		return nil
+	}
+	return &buildCache{dir: dir}
+}
+
+func (bc *buildCache) filePath(jobName string, buildID int) string {
+	return path.Join(bc.dir, jobName, fmt.Sprintf("%d.json", buildID))
+}
+
+// get returns cached results of a build, the second return value is false if
+// the build is not cached or the cache file is unreadable
+func (bc *buildCache) get(jobName string, buildID int) ([]*junit.TestSuites, bool) {
+	if bc == nil {
+		return nil, false
+	}
+	contents, err := ioutil.ReadFile(bc.filePath(jobName, buildID))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("WARNING: failed reading cache for build '%d' of job '%s': %v", buildID, jobName, err)
+		}
+		return nil, false
+	}
+	var suites []*junit.TestSuites
+	if err := json.Unmarshal(contents, &suites); err != nil {
+		log.Printf("WARNING: ignoring corrupted cache for build '%d' of job '%s': %v", buildID, jobName, err)
+		return nil, false
+	}
+	return suites, true
+}
+
+// put stores results of a finished build in cache
+func (bc *buildCache) put(jobName string, buildID int, suites []*junit.TestSuites) error {
+	if bc == nil {
+		return nil
+	}
+	if err := helpers.CreateDir(path.Join(bc.dir, jobName)); err != nil {
+		return err
+	}
+	contents, err := json.Marshal(suites)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(bc.filePath(jobName, buildID), contents, 0644)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"knative.dev/test-infra/pkg/junit"
+)
+
+func TestBuildCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flaky-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	failure := "failed"
+	suites := []*junit.TestSuites{{
+		Suites: []junit.TestSuite{{
+			Name: "suite",
+			TestCases: []junit.TestCase{
+				{Name: "passed"},
+				{Name: "failed", Failure: &failure},
+			},
+		}},
+	}}
+
+	cache := newBuildCache(dir)
+	if _, ok := cache.get("job", 1); ok {
+		t.Error("get() found build in empty cache")
+	}
+	if err := cache.put("job", 1, suites); err != nil {
+		t.Fatalf("put() failed: %v", err)
+	}
+	got, ok := cache.get("job", 1)
+	if !ok {
+		t.Fatal("get() didn't find cached build")
+	}
+	if !reflect.DeepEqual(got, suites) {
+		t.Errorf("get() = %v, want %v", got, suites)
+	}
+
+	if err := ioutil.WriteFile(cache.filePath("job", 2), []byte("corrupted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.get("job", 2); ok {
+		t.Error("get() returned corrupted cache")
+	}
+
+	disabled := newBuildCache("")
+	if err := disabled.put("job", 1, suites); err != nil {
+		t.Errorf("put() on disabled cache failed: %v", err)
+	}
+	if _, ok := disabled.get("job", 1); ok {
+		t.Error("get() on disabled cache found build")
+	}
+}
@@ -19,18 +19,20 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
-	"log"
-	"os"
-	"path/filepath"
+	"regexp"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
-)
 
-// configFile saves all information we need, this path is caller based
-const configFile = "config/config.yaml"
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/pkg/prow"
+)
 
-var JobConfigs []JobConfig
+// slackIdentityRegex matches Slack channel IDs, public channels start with
+// "C" and private channels start with "G"
+var slackIdentityRegex = regexp.MustCompile(`^[CG][A-Z0-9]{8,}$`)
 
 // Config contains all job configs for flaky tests reporting
 type Config struct {
@@ -47,29 +49,111 @@ type JobConfig struct {
 	SlackChannels []SlackChannel `yaml:"slackChannels,omitempty"`
 }
 
-// SlackChannel contains Slack channels info
+// SlackChannel contains Slack channels info, and optional filters for
+// deciding which flaky tests are notified to this channel
 type SlackChannel struct {
 	Name     string `yaml:"name"`
 	Identity string `yaml:"identity"`
+	// MinFlakyRate is the minimal flaky rate of a job, in range [0, 1], for it to be notified
+	MinFlakyRate float32 `yaml:"minFlakyRate,omitempty"`
+	// TestPattern is a regular expression, only flaky tests with matching names are notified
+	TestPattern string `yaml:"testPattern,omitempty"`
+}
+
+// PathsFlag is a multi-value flag for passing in config files
+type PathsFlag []string
+
+func (pf *PathsFlag) String() string {
+	return strings.Join(*pf, ", ")
+}
+
+func (pf *PathsFlag) Set(val string) error {
+	*pf = append(*pf, val)
+	return nil
+}
+
+// Load reads all config files, merges job configs from them in order and
+// validates the result. It fails if no config file is provided, or any of
+// them is missing or invalid.
+func Load(paths ...string) (*Config, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config file provided")
+	}
+	merged := &Config{}
+	for _, p := range paths {
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading config file '%s': %v", p, err)
+		}
+		c, err := parse(contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing config file '%s': %v", p, err)
+		}
+		merged.JobConfigs = append(merged.JobConfigs, c.JobConfigs...)
+	}
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config from %v: %v", paths, err)
+	}
+	return merged, nil
 }
 
-func init() {
-	contents, err := ioutil.ReadFile(configFile)
-	if err != nil {
-		// If running in container the relative path would not work,
-		// get current file dir and try to resolve it with Abs path
-		dir, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-		contents, err = ioutil.ReadFile(filepath.Join(dir, configFile))
+// parse unmarshals config contents, unknown fields are treated as errors
+// so that typos don't result in silently ignored settings
+func parse(contents []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(contents, c); err != nil {
+		return nil, err
 	}
-	if err != nil {
-		log.Printf("Failed to load the config file: %v", err)
-		return
+	return c, nil
+}
+
+// Validate checks that there is at least one job, all jobs are unique and
+// have all required fields set, and all Slack identities are valid
+func (c *Config) Validate() error {
+	if len(c.JobConfigs) == 0 {
+		return fmt.Errorf("no job configured")
 	}
+	var errs []error
+	seen := make(map[string]bool)
+	for i, jc := range c.JobConfigs {
+		if err := jc.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("job #%d '%s': %v", i, jc.Name, err))
+		}
+		if seen[jc.Name] {
+			errs = append(errs, fmt.Errorf("job #%d '%s': duplicate job", i, jc.Name))
+		}
+		seen[jc.Name] = true
+	}
+	return helpers.CombineErrors(errs)
+}
 
-	config := &Config{}
-	if err = yaml.Unmarshal(contents, config); err != nil {
-		log.Printf("Failed to unmarshal %v", contents)
-	} else {
-		JobConfigs = config.JobConfigs
+func (jc JobConfig) validate() error {
+	var errs []error
+	for _, f := range []struct{ name, val string }{
+		{"name", jc.Name}, {"org", jc.Org}, {"repo", jc.Repo}, {"type", jc.Type},
+	} {
+		if f.val == "" {
+			errs = append(errs, fmt.Errorf("missing %s", f.name))
+		}
+	}
+	switch jc.Type {
+	case "", prow.PresubmitJob, prow.PostsubmitJob, prow.PeriodicJob:
+	default:
+		errs = append(errs, fmt.Errorf("invalid type '%s'", jc.Type))
+	}
+	for _, sc := range jc.SlackChannels {
+		if sc.Name == "" {
+			errs = append(errs, fmt.Errorf("missing name for Slack channel '%s'", sc.Identity))
+		}
+		if !slackIdentityRegex.MatchString(sc.Identity) {
+			errs = append(errs, fmt.Errorf("invalid identity '%s' for Slack channel '%s'", sc.Identity, sc.Name))
+		}
+		if sc.MinFlakyRate < 0 || sc.MinFlakyRate > 1 {
+			errs = append(errs, fmt.Errorf("invalid minFlakyRate '%v' for Slack channel '%s', must be in range [0, 1]", sc.MinFlakyRate, sc.Name))
+		}
+		if _, err := regexp.Compile(sc.TestPattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid testPattern for Slack channel '%s': %v", sc.Name, err))
+		}
 	}
+	return helpers.CombineErrors(errs)
 }
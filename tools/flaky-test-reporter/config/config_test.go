@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed writing config file: %v", err)
+	}
+	return p
+}
+
+func TestLoadCheckedInConfig(t *testing.T) {
+	c, err := Load("config.yaml")
+	if err != nil {
+		t.Fatalf("config.yaml is invalid: %v", err)
+	}
+	if len(c.JobConfigs) == 0 {
+		t.Error("config.yaml has no job")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flaky-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid1 := writeConfigFile(t, dir, "valid1.yaml", `
+jobConfigs:
+  - name: job1
+    org: knative
+    repo: serving
+    type: postsubmit
+    slackChannels:
+      - name: serving-api
+        identity: CA4DNJ9A4
+`)
+	valid2 := writeConfigFile(t, dir, "valid2.yaml", `
+jobConfigs:
+  - name: job2
+    org: knative
+    repo: eventing
+    type: periodic
+`)
+	unknownField := writeConfigFile(t, dir, "unknown.yaml", `
+jobConfigs:
+  - name: job3
+    org: knative
+    repo: eventing
+    type: periodic
+    issueRepos: eventing
+`)
+	missingRepo := writeConfigFile(t, dir, "missing.yaml", `
+jobConfigs:
+  - name: job3
+    org: knative
+    type: periodic
+`)
+	badSlack := writeConfigFile(t, dir, "badslack.yaml", `
+jobConfigs:
+  - name: job3
+    org: knative
+    repo: eventing
+    type: periodic
+    slackChannels:
+      - name: eventing
+        identity: eventing
+`)
+	badFilters := writeConfigFile(t, dir, "badfilters.yaml", `
+jobConfigs:
+  - name: job3
+    org: knative
+    repo: eventing
+    type: periodic
+    slackChannels:
+      - name: eventing
+        identity: CA4DNJ9A4
+        minFlakyRate: 2
+        testPattern: "["
+`)
+	empty := writeConfigFile(t, dir, "empty.yaml", ``)
+
+	tests := []struct {
+		name     string
+		paths    []string
+		wantJobs []string
+		wantErr  string
+	}{
+		{"single file", []string{valid1}, []string{"job1"}, ""},
+		{"merged files", []string{valid1, valid2}, []string{"job1", "job2"}, ""},
+		{"no file", nil, nil, "no config file provided"},
+		{"file not exist", []string{filepath.Join(dir, "notexist.yaml")}, nil, "failed reading config file"},
+		{"unknown field", []string{unknownField}, nil, "field issueRepos not found"},
+		{"missing repo", []string{missingRepo}, nil, "missing repo"},
+		{"duplicate jobs", []string{valid1, valid1}, nil, "duplicate job"},
+		{"invalid Slack identity", []string{badSlack}, nil, "invalid identity 'eventing'"},
+		{"invalid minFlakyRate", []string{badFilters}, nil, "invalid minFlakyRate '2'"},
+		{"invalid testPattern", []string{badFilters}, nil, "invalid testPattern"},
+		{"no job", []string{empty}, nil, "no job configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.paths...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			var gotJobs []string
+			for _, jc := range c.JobConfigs {
+				gotJobs = append(gotJobs, jc.Name)
+			}
+			if strings.Join(gotJobs, ",") != strings.Join(tt.wantJobs, ",") {
+				t.Errorf("Load() jobs = %v, want %v", gotJobs, tt.wantJobs)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// failure_signature.go clusters failure messages of a test into signatures,
+// so that it's easy to tell whether a flaky test fails the same way every time
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"knative.dev/test-infra/tools/flaky-test-reporter/jsonreport"
+)
+
+const (
+	// maxSignatures is the max count of signatures reported for each test
+	maxSignatures = 3
+	// maxSignatureExamples is the max count of example builds linked for each signature
+	maxSignatureExamples = 3
+	// maxSignatureLength is the max length of a normalized signature, long
+	// failure messages are mostly logs that don't help identifying the failure
+	maxSignatureLength = 200
+)
+
+// signatureNormalizers are applied in order to a failure message to strip the
+// parts that differ between runs. Order matters: more specific patterns
+// (timestamps, UUIDs) must be replaced before generic numbers.
+var signatureNormalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// RFC3339 or similar timestamps, e.g. 2019-08-01T12:34:56.789Z, 2019-08-01 12:34:56 +0000
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|\s?[+-]\d{2}:?\d{2})?(\s[A-Z]{3})?`), "<timestamp>"},
+	// Time of day, e.g. 12:34:56.789
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`), "<time>"},
+	// UUIDs
+	{regexp.MustCompile(`\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	// IP addresses with optional port
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	// Pod names generated by deployments and replicasets, e.g. helloworld-00001-deployment-7d9f8c6b5-x2x7z
+	{regexp.MustCompile(`\b([a-z0-9]+-)+[a-z0-9]{8,10}-[a-z0-9]{5}\b`), "<pod>"},
+	// Random suffixes appended to resource names, e.g. ns-x2k9d. The suffix must
+	// contain a digit, letters only suffixes can't be told apart from words,
+	// e.g. cluster-resource
+	{regexp.MustCompile(`\b(([a-z0-9]+-)*[a-z0-9]+)-[a-z]*[0-9][a-z0-9]{3,}\b`), "$1-<random>"},
+	// Long hex strings, e.g. commit SHAs and digests
+	{regexp.MustCompile(`\b[0-9a-f]{12,}\b`), "<hex>"},
+	// Any other numbers, e.g. line numbers, durations and counts
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<N>"},
+	// Whitespaces
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// failureSignature is a group of failures of a test that look the same after normalization
+type failureSignature struct {
+	Signature string
+	BuildIDs  []int // build IDs failed with this signature, in descending order
+}
+
+// normalizeFailureMessage strips volatile parts of a failure message, like
+// timestamps, pod names and random suffixes, so that failures caused by the
+// same problem end up with identical signatures
+func normalizeFailureMessage(message string) string {
+	for _, n := range signatureNormalizers {
+		message = n.re.ReplaceAllString(message, n.repl)
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > maxSignatureLength {
+		message = message[:maxSignatureLength] + "..."
+	}
+	return message
+}
+
+// getFailureSignatures clusters failure messages of a test into signatures,
+// sorted by number of occurrences in descending order
+func getFailureSignatures(ts *TestStat) []failureSignature {
+	clusters := make(map[string]*failureSignature)
+	for buildID, message := range ts.FailureMessages {
+		sig := normalizeFailureMessage(message)
+		if _, ok := clusters[sig]; !ok {
+			clusters[sig] = &failureSignature{Signature: sig}
+		}
+		clusters[sig].BuildIDs = append(clusters[sig].BuildIDs, buildID)
+	}
+	var signatures []failureSignature
+	for _, fs := range clusters {
+		sort.Sort(sort.Reverse(sort.IntSlice(fs.BuildIDs)))
+		signatures = append(signatures, *fs)
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		if len(signatures[i].BuildIDs) != len(signatures[j].BuildIDs) {
+			return len(signatures[i].BuildIDs) > len(signatures[j].BuildIDs)
+		}
+		return signatures[i].Signature < signatures[j].Signature
+	})
+	return signatures
+}
+
+// getTopFailureSignatures returns up to maxSignatures most common failure signatures of a test
+func getTopFailureSignatures(ts *TestStat) []failureSignature {
+	signatures := getFailureSignatures(ts)
+	if len(signatures) > maxSignatures {
+		signatures = signatures[:maxSignatures]
+	}
+	return signatures
+}
+
+// getBuildURL returns the Prow link of a build of the job
+func getBuildURL(jobName string, buildID int) string {
+	return fmt.Sprintf("%s%s/%d", jobLogsURL, jobName, buildID)
+}
+
+// createSignaturesContent creates markdown listing the top failure signatures
+// of a test, to be added to issue comment
+func createSignaturesContent(rd RepoData, testFullName string) string {
+	signatures := getTopFailureSignatures(rd.TestStats[testFullName])
+	if len(signatures) == 0 {
+		return ""
+	}
+	content := "\nTop failure signatures:"
+	for _, fs := range signatures {
+		var examples []string
+		for i, buildID := range fs.BuildIDs {
+			if i >= maxSignatureExamples {
+				break
+			}
+			examples = append(examples, fmt.Sprintf("[%d](%s)", buildID, getBuildURL(rd.Config.Name, buildID)))
+		}
+		// Backticks would break the inline code formatting
+		content += fmt.Sprintf("\n- %d times: `%s` (e.g. %s)",
+			len(fs.BuildIDs), strings.ReplaceAll(fs.Signature, "`", "'"), strings.Join(examples, ", "))
+	}
+	return content
+}
+
+// getJSONSignatures converts top failure signatures of a test into the format used by JSON report
+func getJSONSignatures(rd RepoData, testFullName string) []jsonreport.FailureSignature {
+	var res []jsonreport.FailureSignature
+	for _, fs := range getTopFailureSignatures(rd.TestStats[testFullName]) {
+		jfs := jsonreport.FailureSignature{
+			Signature: fs.Signature,
+			Count:     len(fs.BuildIDs),
+		}
+		for i, buildID := range fs.BuildIDs {
+			if i >= maxSignatureExamples {
+				break
+			}
+			jfs.Examples = append(jfs.Examples, getBuildURL(rd.Config.Name, buildID))
+		}
+		res = append(res, jfs)
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
+	"knative.dev/test-infra/tools/flaky-test-reporter/jsonreport"
+)
+
+func TestNormalizeFailureMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "timestamp",
+			message: "2020-08-01T12:34:56.789Z timed out waiting",
+			want:    "<timestamp> timed out waiting",
+		},
+		{
+			name:    "pod name",
+			message: "pod helloworld-00001-deployment-7d9f8c6b5-x2x7z is not ready",
+			want:    "pod <pod> is not ready",
+		},
+		{
+			name:    "random suffix",
+			message: `service "helloworld-fds8hkxm" is not ready`,
+			want:    `service "helloworld-<random>" is not ready`,
+		},
+		{
+			name:    "hyphenated words",
+			message: `cluster-resource "webhook-certificate" not found`,
+			want:    `cluster-resource "webhook-certificate" not found`,
+		},
+		{
+			name:    "line numbers and whitespaces",
+			message: "  service_test.go:123:\n\t\texpected 3 got 4  ",
+			want:    "service_test.go:<N>: expected <N> got <N>",
+		},
+		{
+			name:    "ip address",
+			message: "dial tcp 10.0.0.1:443: i/o timeout",
+			want:    "dial tcp <ip>: i/o timeout",
+		},
+		{
+			name:    "too long",
+			message: strings.Repeat("x", maxSignatureLength+10),
+			want:    strings.Repeat("x", maxSignatureLength) + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeFailureMessage(tt.message); got != tt.want {
+				t.Errorf("normalizeFailureMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFailureSignatures(t *testing.T) {
+	ts := &TestStat{
+		TestName: "a",
+		Failed:   []int{1, 2, 3, 4},
+		FailureMessages: map[int]string{
+			1: "2020-08-01T12:34:56Z service helloworld-fds8hkxm not ready",
+			2: "timeout",
+			3: "2020-08-02T01:02:03Z service helloworld-kdj3eiwo not ready",
+			4: "2020-08-03T01:02:03Z service helloworld-pq0wieur not ready",
+		},
+	}
+	want := []failureSignature{{
+		Signature: "<timestamp> service helloworld-<random> not ready",
+		BuildIDs:  []int{4, 3, 1},
+	}, {
+		Signature: "timeout",
+		BuildIDs:  []int{2},
+	}}
+	if got := getFailureSignatures(ts); !reflect.DeepEqual(got, want) {
+		t.Errorf("getFailureSignatures() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSignatures(t *testing.T) {
+	sigs := []jsonreport.FailureSignature{
+		{Signature: "timeout", Count: 2, Examples: []string{"job-a/1", "job-a/2"}},
+		{Signature: "not ready", Count: 1, Examples: []string{"job-a/3"}},
+		{Signature: "timeout", Count: 3, Examples: []string{"job-b/4", "job-b/5", "job-b/6"}},
+	}
+	want := []jsonreport.FailureSignature{
+		{Signature: "timeout", Count: 5, Examples: []string{"job-a/1", "job-a/2", "job-b/4"}},
+		{Signature: "not ready", Count: 1, Examples: []string{"job-a/3"}},
+	}
+	if got := mergeSignatures(sigs); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSignatures() = %v, want %v", got, want)
+	}
+}
+
+func TestCreateSignaturesContent(t *testing.T) {
+	rd := RepoData{
+		Config: config.JobConfig{Name: "fakejob"},
+		TestStats: map[string]*TestStat{
+			"flaky": {
+				TestName:        "flaky",
+				Failed:          []int{10, 11},
+				FailureMessages: map[int]string{10: "`a` failed", 11: "`a` failed"},
+			},
+			"passed": {
+				TestName: "passed",
+				Passed:   []int{10, 11},
+			},
+		},
+	}
+	want := "\nTop failure signatures:\n- 2 times: `'a' failed` (e.g. " +
+		"[11](" + jobLogsURL + "fakejob/11), [10](" + jobLogsURL + "fakejob/10))"
+	if got := createSignaturesContent(rd, "flaky"); got != want {
+		t.Errorf("createSignaturesContent() = %q, want %q", got, want)
+	}
+	if got := createSignaturesContent(rd, "passed"); got != "" {
+		t.Errorf("createSignaturesContent() = %q, want empty", got)
+	}
+}
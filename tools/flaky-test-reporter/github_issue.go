@@ -153,9 +153,10 @@ func (gih *GithubIssueHandler) createCommentForTest(rd RepoData, testFullName st
 		var buildIDContents []string
 		for _, buildID := range ts.Failed {
 			buildIDContents = append(buildIDContents,
-				fmt.Sprintf("[%d](%s%s/%d)", buildID, jobLogsURL, rd.Config.Name, buildID))
+				fmt.Sprintf("[%d](%s)", buildID, getBuildURL(rd.Config.Name, buildID)))
 		}
 		content += strings.Join(buildIDContents, ", ")
+		content += createSignaturesContent(rd, testFullName)
 	}
 	return content
 }
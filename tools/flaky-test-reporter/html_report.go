@@ -0,0 +1,267 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// html_report.go generates a static HTML dashboard of flaky tests, which is
+// stored in artifacts directory so it can be browsed from Prow artifacts page
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"time"
+
+	"knative.dev/test-infra/pkg/helpers"
+	"knative.dev/test-infra/pkg/junit"
+	"knative.dev/test-infra/pkg/prow"
+	"knative.dev/test-infra/pkg/testgrid"
+)
+
+const (
+	htmlReportFile = "flaky-tests.html"
+	// maxTopFlakyTests is the max count of tests listed in top flaky tests section
+	maxTopFlakyTests = 20
+)
+
+// htmlReport is the data rendered by htmlReportTemplate
+type htmlReport struct {
+	GeneratedAt   string
+	Jobs          []htmlJob
+	TopFlakyTests []htmlFlakyTest
+}
+
+// htmlJob summarizes flaky tests of a single job
+type htmlJob struct {
+	Name          string
+	Repo          string
+	LastBuildTime string
+	TestCount     int
+	FlakyRate     string
+	TestgridURL   string
+	BulkIssueURLs []string
+	// Builds are in chronological order, showing trend of failures in this job
+	Builds     []htmlBuild
+	FlakyTests []htmlFlakyTest
+}
+
+// htmlBuild contains results of a single build
+type htmlBuild struct {
+	ID          int
+	URL         string
+	FailedCount int
+	FlakyCount  int // count of flaky tests that failed in this build
+	BarWidth    int // width in pixels of the bar representing FlakyCount
+}
+
+// htmlFlakyTest contains results of a flaky test across all builds
+type htmlFlakyTest struct {
+	Name        string
+	Job         string
+	Repo        string
+	FailedCount int
+	RunCount    int
+	History     []htmlResult // in chronological order
+	IssueURLs   []string
+}
+
+// htmlResult is a single cell in history grid
+type htmlResult struct {
+	Status junit.TestStatusEnum
+	URL    string
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Flaky Tests Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.cell { padding: 0; }
+td.cell a { display: block; width: 16px; height: 16px; }
+.passed { background: #4caf50; }
+.failed { background: #e53935; }
+.skipped { background: #e0e0e0; }
+.bar { display: inline-block; background: #e53935; height: 10px; }
+</style>
+</head>
+<body>
+<h1>Flaky Tests Report</h1>
+<p>Generated at {{.GeneratedAt}}</p>
+
+<h2>Jobs</h2>
+<table>
+<tr><th>Job</th><th>Repo</th><th>Last build</th><th>Tests</th><th>Flaky tests</th><th>Flaky rate</th><th>Links</th></tr>
+{{range .Jobs}}<tr>
+<td><a href="#{{.Repo}}-{{.Name}}">{{.Name}}</a></td>
+<td>{{.Repo}}</td>
+<td>{{.LastBuildTime}}</td>
+<td>{{.TestCount}}</td>
+<td>{{len .FlakyTests}}</td>
+<td>{{.FlakyRate}}</td>
+<td>{{if .TestgridURL}}<a href="{{.TestgridURL}}">Testgrid</a>{{end}}{{range .BulkIssueURLs}} <a href="{{.}}">issue</a>{{end}}</td>
+</tr>
+{{end}}</table>
+
+<h2>Top Flaky Tests</h2>
+<table>
+<tr><th>Test</th><th>Job</th><th>Repo</th><th>Failed</th><th>Issues</th></tr>
+{{range .TopFlakyTests}}<tr>
+<td>{{.Name}}</td>
+<td>{{.Job}}</td>
+<td>{{.Repo}}</td>
+<td>{{.FailedCount}}/{{.RunCount}}</td>
+<td>{{range .IssueURLs}}<a href="{{.}}">{{.}}</a> {{end}}</td>
+</tr>
+{{end}}</table>
+
+{{range .Jobs}}
+<h2 id="{{.Repo}}-{{.Name}}">{{.Name}} ({{.Repo}})</h2>
+<h3>Failures per build</h3>
+<table>
+<tr><th>Build</th><th>Failed tests</th><th>Failed flaky tests</th></tr>
+{{range .Builds}}<tr>
+<td><a href="{{.URL}}">{{.ID}}</a></td>
+<td>{{.FailedCount}}</td>
+<td><span class="bar" style="width: {{.BarWidth}}px"></span> {{.FlakyCount}}</td>
+</tr>
+{{end}}</table>
+{{if .FlakyTests}}
+<h3>History of flaky tests</h3>
+<table>
+{{range .FlakyTests}}<tr>
+<td>{{.Name}}</td>
+{{range .History}}<td class="cell"><a class="{{.Status}}" href="{{.URL}}" title="{{.Status}}"></a></td>{{end}}
+<td>{{range .IssueURLs}}<a href="{{.}}">issue</a> {{end}}</td>
+</tr>
+{{end}}</table>
+{{else}}
+<p>No flaky tests found.</p>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// getIssueURLs returns links of all issues tracking the given identity
+func getIssueURLs(flakyIssuesMap map[string][]flakyIssue, identity string) []string {
+	var urls []string
+	for _, fi := range flakyIssuesMap[identity] {
+		if url := fi.issue.GetHTMLURL(); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// createHTMLJob converts RepoData into htmlJob
+func createHTMLJob(rd RepoData, flakyIssuesMap map[string][]flakyIssue) htmlJob {
+	job := htmlJob{
+		Name:          rd.Config.Name,
+		Repo:          rd.Config.Repo,
+		LastBuildTime: time.Unix(*rd.LastBuildStartTime, 0).String(),
+		TestCount:     len(rd.TestStats),
+		FlakyRate:     fmt.Sprintf("%.2f%%", getFlakyRate(rd)*100),
+	}
+	if url, err := testgrid.GetTestgridTabURL(rd.Config.Name, []string{testgridFilter}); err == nil {
+		job.TestgridURL = url
+	}
+	if flakyRateAboveThreshold(rd) {
+		job.BulkIssueURLs = getIssueURLs(flakyIssuesMap, getBulkIssueIdentity(rd, getFlakyRate(rd)))
+	}
+
+	flakyTests := getFlakyTests(rd)
+	sort.Strings(flakyTests)
+	// BuildIDs are sorted by start time in descending order, reverse it for displaying trend
+	for i := len(rd.BuildIDs) - 1; i >= 0; i-- {
+		buildID := rd.BuildIDs[i]
+		build := htmlBuild{ID: buildID, URL: getBuildURL(rd.Config.Name, buildID)}
+		for _, ts := range rd.TestStats {
+			if intSliceContains(ts.Failed, buildID) {
+				build.FailedCount++
+				if ts.isFlaky() {
+					build.FlakyCount++
+				}
+			}
+		}
+		build.BarWidth = build.FlakyCount * 10
+		job.Builds = append(job.Builds, build)
+	}
+	for _, testName := range flakyTests {
+		ts := rd.TestStats[testName]
+		ft := htmlFlakyTest{
+			Name:        testName,
+			Job:         rd.Config.Name,
+			Repo:        rd.Config.Repo,
+			FailedCount: len(ts.Failed),
+			RunCount:    len(ts.Passed) + len(ts.Failed),
+			IssueURLs:   getIssueURLs(flakyIssuesMap, getIdentityForTest(testName, rd.Config.Repo)),
+		}
+		results := rd.getResultSliceForTest(testName)
+		for i := len(rd.BuildIDs) - 1; i >= 0; i-- {
+			ft.History = append(ft.History, htmlResult{
+				Status: results[i],
+				URL:    getBuildURL(rd.Config.Name, rd.BuildIDs[i]),
+			})
+		}
+		job.FlakyTests = append(job.FlakyTests, ft)
+	}
+	return job
+}
+
+// createHTMLReport renders the HTML dashboard for all jobs
+func createHTMLReport(repoDataAll []RepoData, flakyIssuesMap map[string][]flakyIssue, now time.Time) ([]byte, error) {
+	report := htmlReport{GeneratedAt: now.String()}
+	for _, rd := range repoDataAll {
+		job := createHTMLJob(rd, flakyIssuesMap)
+		report.Jobs = append(report.Jobs, job)
+		report.TopFlakyTests = append(report.TopFlakyTests, job.FlakyTests...)
+	}
+	sort.SliceStable(report.TopFlakyTests, func(i, j int) bool {
+		return report.TopFlakyTests[i].FailedCount > report.TopFlakyTests[j].FailedCount
+	})
+	if len(report.TopFlakyTests) > maxTopFlakyTests {
+		report.TopFlakyTests = report.TopFlakyTests[:maxTopFlakyTests]
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeHTMLReport writes the HTML dashboard under local artifacts directory
+func writeHTMLReport(repoDataAll []RepoData, flakyIssuesMap map[string][]flakyIssue) error {
+	contents, err := createHTMLReport(repoDataAll, flakyIssuesMap, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed rendering HTML report: %v", err)
+	}
+	artifactsDir := prow.GetLocalArtifactsDir()
+	if err := helpers.CreateDir(artifactsDir); err != nil {
+		return err
+	}
+	outFilePath := path.Join(artifactsDir, htmlReportFile)
+	log.Printf("writing HTML report to '%s'", outFilePath)
+	return ioutil.WriteFile(outFilePath, contents, 0644)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+	"knative.dev/test-infra/pkg/junit"
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
+)
+
+func htmlTestRepoData() RepoData {
+	startTime := int64(0)
+	testStats := make(map[string]*TestStat)
+	// Enough passed tests to keep flaky rate below threshold
+	for _, name := range []string{"p1", "p2", "p3", "p4", "p5", "p6"} {
+		testStats[name] = &TestStat{TestName: name, Passed: []int{3, 2, 1}}
+	}
+	testStats["flaky"] = &TestStat{TestName: "flaky", Passed: []int{3, 1}, Failed: []int{2}}
+	return RepoData{
+		Config:             config.JobConfig{Name: "fakejob", Repo: fakeRepo},
+		TestStats:          testStats,
+		BuildIDs:           []int{3, 2, 1},
+		LastBuildStartTime: &startTime,
+	}
+}
+
+func TestCreateHTMLJob(t *testing.T) {
+	issueURL := "https://github.com/fakeorg/fakerepo/issues/1"
+	issues := map[string][]flakyIssue{
+		getIdentityForTest("flaky", fakeRepo): {{issue: &github.Issue{HTMLURL: &issueURL}}},
+	}
+	job := createHTMLJob(htmlTestRepoData(), issues)
+
+	wantBuilds := []htmlBuild{
+		{ID: 1, URL: jobLogsURL + "fakejob/1"},
+		{ID: 2, URL: jobLogsURL + "fakejob/2", FailedCount: 1, FlakyCount: 1, BarWidth: 10},
+		{ID: 3, URL: jobLogsURL + "fakejob/3"},
+	}
+	if !reflect.DeepEqual(job.Builds, wantBuilds) {
+		t.Errorf("Builds = %v, want %v", job.Builds, wantBuilds)
+	}
+	wantFlakyTests := []htmlFlakyTest{{
+		Name:        "flaky",
+		Job:         "fakejob",
+		Repo:        fakeRepo,
+		FailedCount: 1,
+		RunCount:    3,
+		History: []htmlResult{
+			{Status: junit.Passed, URL: jobLogsURL + "fakejob/1"},
+			{Status: junit.Failed, URL: jobLogsURL + "fakejob/2"},
+			{Status: junit.Passed, URL: jobLogsURL + "fakejob/3"},
+		},
+		IssueURLs: []string{issueURL},
+	}}
+	if !reflect.DeepEqual(job.FlakyTests, wantFlakyTests) {
+		t.Errorf("FlakyTests = %v, want %v", job.FlakyTests, wantFlakyTests)
+	}
+}
+
+func TestCreateHTMLReport(t *testing.T) {
+	contents, err := createHTMLReport([]RepoData{htmlTestRepoData()}, nil, time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("createHTMLReport() failed: %v", err)
+	}
+	for _, want := range []string{
+		`<a href="#fakerepo-fakejob">fakejob</a>`,
+		`<td>14.29%</td>`,
+		`<a class="failed" href="` + jobLogsURL + `fakejob/2"`,
+	} {
+		if !strings.Contains(string(contents), want) {
+			t.Errorf("HTML report doesn't contain %q:\n%s", want, contents)
+		}
+	}
+}
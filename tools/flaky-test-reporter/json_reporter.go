@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 
 	"knative.dev/test-infra/pkg/helpers"
@@ -42,10 +43,63 @@ func getFlakyTestSet(repoDataAll []RepoData) map[string]map[string]bool {
 	return flakyTestSet
 }
 
+// getFlakyTestSignatures collects top failure signatures of flaky tests in the same
+// "repo: test" layout as getFlakyTestSet. If a test is flaky in multiple jobs of the
+// same repo, the signatures from all jobs are combined, identical signatures merged,
+// and the most common ones kept.
+func getFlakyTestSignatures(repoDataAll []RepoData) map[string]map[string][]jsonreport.FailureSignature {
+	signatures := map[string]map[string][]jsonreport.FailureSignature{}
+	for _, rd := range repoDataAll {
+		if signatures[rd.Config.Repo] == nil {
+			signatures[rd.Config.Repo] = map[string][]jsonreport.FailureSignature{}
+		}
+		for _, test := range getFlakyTests(rd) {
+			signatures[rd.Config.Repo][test] = append(signatures[rd.Config.Repo][test], getJSONSignatures(rd, test)...)
+		}
+	}
+	for _, testSignatures := range signatures {
+		for test, sigs := range testSignatures {
+			sigs = mergeSignatures(sigs)
+			sort.SliceStable(sigs, func(i, j int) bool {
+				return sigs[i].Count > sigs[j].Count
+			})
+			if len(sigs) > maxSignatures {
+				sigs = sigs[:maxSignatures]
+			}
+			testSignatures[test] = sigs
+		}
+	}
+	return signatures
+}
+
+// mergeSignatures combines identical signatures of a test failing the same way
+// in multiple jobs, adding up their counts and keeping up to
+// maxSignatureExamples examples
+func mergeSignatures(sigs []jsonreport.FailureSignature) []jsonreport.FailureSignature {
+	var merged []jsonreport.FailureSignature
+	index := make(map[string]int)
+	for _, sig := range sigs {
+		i, ok := index[sig.Signature]
+		if !ok {
+			index[sig.Signature] = len(merged)
+			merged = append(merged, jsonreport.FailureSignature{Signature: sig.Signature})
+			i = len(merged) - 1
+		}
+		merged[i].Count += sig.Count
+		for _, example := range sig.Examples {
+			if len(merged[i].Examples) < maxSignatureExamples {
+				merged[i].Examples = append(merged[i].Examples, example)
+			}
+		}
+	}
+	return merged
+}
+
 func writeFlakyTestsToJSON(repoDataAll []RepoData, dryrun bool) error {
 	client := &jsonreport.JSONClient{}
 	var allErrs []error
 	flakyTestSets := getFlakyTestSet(repoDataAll)
+	flakyTestSignatures := getFlakyTestSignatures(repoDataAll)
 	ch := make(chan bool, len(flakyTestSets))
 	wg := sync.WaitGroup{}
 	for repo := range flakyTestSets {
@@ -59,7 +113,7 @@ func writeFlakyTestsToJSON(repoDataAll []RepoData, dryrun bool) error {
 			if err := helpers.Run(
 				fmt.Sprintf("writing JSON report for repo '%s'", repo),
 				func() error {
-					_, err := client.CreateReport(repo, testList, true)
+					_, err := client.CreateReport(repo, testList, flakyTestSignatures[repo], true)
 					return err
 				},
 				dryrun); err != nil {
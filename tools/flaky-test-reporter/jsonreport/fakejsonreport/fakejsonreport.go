@@ -35,10 +35,11 @@ func Initialize(serviceAccount string) (*FakeClient, error) {
 
 // CreateReport generates a flaky report for a given repository, and optionally
 // writes it to disk.
-func (c *FakeClient) CreateReport(repo string, flaky []string, writeFile bool) (*jsonreport.Report, error) {
+func (c *FakeClient) CreateReport(repo string, flaky []string, signatures map[string][]jsonreport.FailureSignature, writeFile bool) (*jsonreport.Report, error) {
 	report := &jsonreport.Report{
-		Repo:  repo,
-		Flaky: flaky,
+		Repo:       repo,
+		Flaky:      flaky,
+		Signatures: signatures,
 	}
 	if writeFile {
 		data, err := json.Marshal(report)
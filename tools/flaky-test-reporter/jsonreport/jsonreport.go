@@ -39,11 +39,20 @@ const (
 type Report struct {
 	Repo  string   `json:"repo"`
 	Flaky []string `json:"flaky"`
+	// Signatures maps flaky test name to its most common failure signatures
+	Signatures map[string][]FailureSignature `json:"signatures,omitempty"`
+}
+
+// FailureSignature is a normalized failure message shared by failed runs of a test
+type FailureSignature struct {
+	Signature string   `json:"signature"`
+	Count     int      `json:"count"`
+	Examples  []string `json:"examples"` // links to builds failed with this signature
 }
 
 // JSONClient contains the set of operations a JSON reporter needs
 type Client interface {
-	CreateReport(repo string, flaky []string, writeFile bool) (*Report, error)
+	CreateReport(repo string, flaky []string, signatures map[string][]FailureSignature, writeFile bool) (*Report, error)
 	GetFlakyTests(jobName, repo string) ([]string, error)
 	GetReportRepos(jobName string) ([]string, error)
 	GetFlakyTestReport(jobName, repo string, buildID int) ([]Report, error)
@@ -61,10 +70,11 @@ func Initialize(serviceAccount string) (Client, error) {
 
 // CreateReport generates a flaky report for a given repository, and optionally
 // writes it to disk.
-func (c *JSONClient) CreateReport(repo string, flaky []string, writeFile bool) (*Report, error) {
+func (c *JSONClient) CreateReport(repo string, flaky []string, signatures map[string][]FailureSignature, writeFile bool) (*Report, error) {
 	report := &Report{
-		Repo:  repo,
-		Flaky: flaky,
+		Repo:       repo,
+		Flaky:      flaky,
+		Signatures: signatures,
 	}
 	if writeFile {
 		return report, c.writeToArtifactsDir(report)
@@ -46,10 +46,20 @@ func main() {
 	githubAccount := flag.String("github-account", "", "Token file for Github authentication")
 	slackAccount := flag.String("slack-account", "", "slack secret file for authenticating with Slack")
 	buildsCountOverride := flag.Int("build-count", 10, "count of builds to scan")
+	concurrency := flag.Int("concurrency", 10, "max count of jobs processed, and max count of builds fetched at the same time")
+	cacheDir := flag.String("cache-dir", "", "directory for caching parsed results of finished builds across runs, caching is disabled if empty")
 	skipReport := flag.Bool("skip-report", false, "skip Github and Slack report")
+	slackDigest := flag.String("slack-digest", noDigest, "post Slack notifications as a digest per channel, can be 'daily' or 'weekly', by default one message is posted per repo")
 	dryrun := flag.Bool("dry-run", false, "dry run switch")
+	var configPaths config.PathsFlag
+	flag.Var(&configPaths, "config", "config file of jobs to analyze, can be repeated to merge multiple files")
 	flag.Parse()
 
+	cfg, err := config.Load(configPaths...)
+	if err != nil {
+		log.Fatalf("Failed loading config: %v", err)
+	}
+
 	buildsCount = *buildsCountOverride
 	requiredCount = requiredRatio * float32(buildsCount)
 
@@ -57,20 +67,26 @@ func main() {
 		log.Printf("running in [dry run mode]")
 	}
 
+	switch *slackDigest {
+	case noDigest, dailyDigest, weeklyDigest:
+	default:
+		log.Fatalf("Invalid --slack-digest '%s', must be one of '%s' or '%s'", *slackDigest, dailyDigest, weeklyDigest)
+	}
+
 	if err := prow.Initialize(*serviceAccount); err != nil { // Explicit authenticate with gcs Client
 		log.Fatalf("Failed authenticating GCS: '%v'", err)
 	}
 
 	var repoDataAll []RepoData
 	// Clean up local artifacts directory, this will be used later for artifacts uploads
-	err := os.RemoveAll(prow.GetLocalArtifactsDir()) // this function returns nil if path not found
+	err = os.RemoveAll(prow.GetLocalArtifactsDir()) // this function returns nil if path not found
 	if err != nil {
 		log.Fatalf("Failed removing local artifacts directory: %v", err)
 	}
 	var jobErrs []error
-	for _, jc := range config.JobConfigs {
-		log.Printf("collecting results for job '%s' in repo '%s'\n", jc.Name, jc.Repo)
-		rd, err := collectTestResultsForRepo(jc)
+	rds, errs := collectTestResultsForRepos(cfg.JobConfigs, *concurrency, newBuildCache(*cacheDir))
+	for i, jc := range cfg.JobConfigs {
+		rd, err := rds[i], errs[i]
 		if err != nil {
 			err = fmt.Errorf("WARNING: error collecting results for job '%s' in repo '%s': %v", jc.Name, jc.Repo, err)
 			log.Printf("%v", err)
@@ -100,8 +116,10 @@ func main() {
 		log.Printf("--skip-report provided, skipping Github and Slack report")
 	} else {
 		flakyIssues, ghErr = githubOperations(*githubAccount, repoDataAll, *dryrun)
-		slackErr = slackOperations(*slackAccount, repoDataAll, flakyIssues, *dryrun)
+		slackErr = slackOperations(*slackAccount, *slackDigest, repoDataAll, flakyIssues, *dryrun)
 	}
+	// Issue links are omitted from HTML report if Github report was skipped
+	htmlErr := writeHTMLReport(repoDataAll, flakyIssues)
 
 	if jobErr != nil {
 		log.Printf("Job step failures:\n%v", jobErr)
@@ -112,8 +130,11 @@ func main() {
 	if jsonErr != nil {
 		log.Printf("JSON step failures:\n%v", jsonErr)
 	}
+	if htmlErr != nil {
+		log.Printf("HTML step failures:\n%v", htmlErr)
+	}
 	// Fail this job if there is any error
-	if jobErr != nil || jsonErr != nil || ghErr != nil || slackErr != nil {
+	if jobErr != nil || jsonErr != nil || htmlErr != nil || ghErr != nil || slackErr != nil {
 		os.Exit(1)
 	}
 }
@@ -132,9 +153,9 @@ func isWeekend(t time.Time) bool {
 	return weekDay == time.Saturday || weekDay == time.Sunday
 }
 
-func slackOperations(slackToken string, repoData []RepoData, flakyIssues map[string][]flakyIssue, dryrun bool) error {
-	if isWeekend(time.Now()) {
-		log.Print("Skip Slack notification on weekend")
+func slackOperations(slackToken, digest string, repoData []RepoData, flakyIssues map[string][]flakyIssue, dryrun bool) error {
+	if !shouldNotify(digest, time.Now()) {
+		log.Print("Skip Slack notification today")
 		return nil
 	}
 
@@ -148,6 +169,9 @@ func slackOperations(slackToken string, repoData []RepoData, flakyIssues map[str
 		return err
 	}
 
+	if digest != noDigest {
+		return sendSlackDigests(digest, repoData, client, flakyIssues, dryrun)
+	}
 	return sendSlackNotifications(repoData, client, flakyIssues, dryrun)
 }
 
@@ -26,7 +26,9 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/test-infra/pkg/helpers"
@@ -53,10 +55,11 @@ type RepoData struct {
 // TestStat represents test results of a single testcase across all builds,
 // Passed, Skipped and Failed contains buildIDs with corresponding results
 type TestStat struct {
-	TestName string
-	Passed   []int
-	Skipped  []int
-	Failed   []int
+	TestName        string
+	Passed          []int
+	Skipped         []int
+	Failed          []int
+	FailureMessages map[int]string `json:",omitempty"` // key is buildID of failed run
 }
 
 func (ts *TestStat) isFlaky() bool {
@@ -154,7 +157,12 @@ func addSuiteToRepoData(suite *junit.TestSuite, buildID int, rd *RepoData) {
 		case junit.Skipped:
 			rd.TestStats[testFullName].Skipped = append(rd.TestStats[testFullName].Skipped, buildID)
 		case junit.Failed:
-			rd.TestStats[testFullName].Failed = append(rd.TestStats[testFullName].Failed, buildID)
+			ts := rd.TestStats[testFullName]
+			ts.Failed = append(ts.Failed, buildID)
+			if ts.FailureMessages == nil {
+				ts.FailureMessages = make(map[int]string)
+			}
+			ts.FailureMessages[buildID] = *testCase.Failure
 		}
 	}
 }
@@ -212,28 +220,68 @@ func getCombinedResultsForBuild(build *prow.Build) ([]*junit.TestSuites, error)
 	return allSuites, nil
 }
 
+// getResultsForBuild returns junit results of a build from cache, or reads them
+// from GCS and caches them if not cached yet
+func getResultsForBuild(build *prow.Build, cache *buildCache) ([]*junit.TestSuites, error) {
+	if suites, ok := cache.get(build.JobName, build.BuildID); ok {
+		return suites, nil
+	}
+	suites, err := getCombinedResultsForBuild(build)
+	if err != nil {
+		return nil, err
+	}
+	// Failing to cache only makes next run slower, don't fail
+	if err := cache.put(build.JobName, build.BuildID, suites); err != nil {
+		log.Printf("WARNING: failed caching build '%d' of job '%s': %v", build.BuildID, build.JobName, err)
+	}
+	return suites, nil
+}
+
 // collectTestResultsForRepo collects test results, build IDs from all builds,
-// as well as LastBuildStartTime, and stores them in RepoData
-func collectTestResultsForRepo(jc config.JobConfig) (*RepoData, error) {
+// as well as LastBuildStartTime, and stores them in RepoData.
+// Builds are fetched concurrently, the count of concurrent fetches is bounded by sem,
+// which is shared across all jobs.
+func collectTestResultsForRepo(jc config.JobConfig, sem chan struct{}, cache *buildCache) (*RepoData, error) {
 	rd := &RepoData{Config: jc}
 	job := prow.NewJob(jc.Name, jc.Type, jc.Org, jc.Repo, 0)
 	if !job.PathExists() {
 		return rd, fmt.Errorf("job path not exist '%s'", jc.Name)
 	}
-	builds := getLatestFinishedBuilds(job, buildsCount)
+	builds, err := getLatestFinishedBuilds(job, buildsCount)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Printf("latest builds: ")
+	var buildIDs []string
 	for i, build := range builds {
-		log.Printf("\t%d", build.BuildID)
+		buildIDs = append(buildIDs, strconv.Itoa(build.BuildID))
 		rd.BuildIDs = append(rd.BuildIDs, build.BuildID)
 		if 0 == i { // This is the latest build as builds are sorted by start time in descending order
 			rd.LastBuildStartTime = build.StartTime
 		}
-		combinedResults, err := getCombinedResultsForBuild(&build)
-		if err != nil {
-			return nil, err
-		}
-		for _, suites := range combinedResults {
+	}
+	log.Printf("latest builds of job '%s': %s", jc.Name, strings.Join(buildIDs, ", "))
+
+	results := make([][]*junit.TestSuites, len(builds))
+	errs := make([]error, len(builds))
+	var wg sync.WaitGroup
+	for i := range builds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = getResultsForBuild(&builds[i], cache)
+		}(i)
+	}
+	wg.Wait()
+	if err := helpers.CombineErrors(errs); err != nil {
+		return nil, err
+	}
+
+	// RepoData is not safe for concurrent use, add results in build order
+	for i, build := range builds {
+		for _, suites := range results[i] {
 			for _, suite := range suites.Suites {
 				addSuiteToRepoData(&suite, build.BuildID, rd)
 			}
@@ -242,6 +290,34 @@ func collectTestResultsForRepo(jc config.JobConfig) (*RepoData, error) {
 	return rd, nil
 }
 
+// collectTestResultsForRepos collects test results for all jobs concurrently,
+// with at most concurrency jobs processed and concurrency builds fetched at the same time.
+// Results and errors are in the same order as jobConfigs, an error collecting
+// one job doesn't affect the others.
+func collectTestResultsForRepos(jobConfigs []config.JobConfig, concurrency int, cache *buildCache) ([]*RepoData, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobSem := make(chan struct{}, concurrency)
+	buildSem := make(chan struct{}, concurrency)
+	rds := make([]*RepoData, len(jobConfigs))
+	errs := make([]error, len(jobConfigs))
+	var wg sync.WaitGroup
+	for i := range jobConfigs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jobSem <- struct{}{}
+			defer func() { <-jobSem }()
+			jc := jobConfigs[i]
+			log.Printf("collecting results for job '%s' in repo '%s'\n", jc.Name, jc.Repo)
+			rds[i], errs[i] = collectTestResultsForRepo(jc, buildSem, cache)
+		}(i)
+	}
+	wg.Wait()
+	return rds, errs
+}
+
 func (rd *RepoData) getResultSliceForTest(testName string) []junit.TestStatusEnum {
 	res := make([]junit.TestStatusEnum, len(rd.BuildIDs), len(rd.BuildIDs))
 	ts := rd.TestStats[testName]
@@ -270,7 +346,7 @@ func intSliceContains(its []int, target int) bool {
 // getLatestFinishedBuilds is an inexpensive way of listing latest finished builds, in comparing to
 // the GetLatestBuilds function from prow package, as it doesn't precompute start/finish time before sorting.
 // This function takes the assumption that build IDs are always incremental integers, it would fail if it doesn't
-func getLatestFinishedBuilds(job *prow.Job, count int) []prow.Build {
+func getLatestFinishedBuilds(job *prow.Job, count int) ([]prow.Build, error) {
 	var builds []prow.Build
 	buildIDs := job.GetBuildIDs()
 	sort.Sort(sort.Reverse(sort.IntSlice(buildIDs)))
@@ -281,10 +357,10 @@ func getLatestFinishedBuilds(job *prow.Job, count int) []prow.Build {
 		build := job.NewBuild(buildID)
 		if build.FinishTime != nil {
 			if build.StartTime == nil {
-				log.Fatalf("Failed parsing start time for finished build '%s'", build.StoragePath)
+				return nil, fmt.Errorf("failed parsing start time for finished build '%s'", build.StoragePath)
 			}
 			builds = append(builds, *build)
 		}
 	}
-	return builds
+	return builds, nil
 }
@@ -21,23 +21,69 @@ package main
 import (
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"knative.dev/test-infra/pkg/helpers"
 	"knative.dev/test-infra/pkg/slackutil"
 	"knative.dev/test-infra/pkg/testgrid"
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
 )
 
 const (
 	knativeBotName = "Knative Testgrid Robot"
 	// default filter for testgrid link
 	testgridFilter = "exclude-non-failed-tests=20"
+
+	// Slack digest modes, by default one message is posted per repo to each channel
+	noDigest     = ""
+	dailyDigest  = "daily"
+	weeklyDigest = "weekly"
 )
 
-// createSlackMessageForRepo creates slack message layout from RepoData
-func createSlackMessageForRepo(rd RepoData, flakyIssuesMap map[string][]flakyIssue) string {
+// shouldNotify decides whether Slack notifications are sent at the given time:
+// weekly digest is sent on Mondays, others are sent on weekdays
+func shouldNotify(digest string, t time.Time) bool {
+	if digest == weeklyDigest {
+		return t.Weekday() == time.Monday
+	}
+	return !isWeekend(t)
+}
+
+// hasFilters checks whether the channel only gets notified of some jobs or tests
+func hasFilters(channel config.SlackChannel) bool {
+	return channel.MinFlakyRate > 0 || channel.TestPattern != ""
+}
+
+// filterFlakyTestsForChannel returns flaky tests of the job matching filters of
+// the channel, it returns nil if the flaky rate of the job is below the minimal
+// flaky rate of the channel
+func filterFlakyTestsForChannel(rd RepoData, channel config.SlackChannel) []string {
+	if getFlakyRate(rd) < channel.MinFlakyRate {
+		return nil
+	}
 	flakyTests := getFlakyTests(rd)
+	sort.Strings(flakyTests)
+	if channel.TestPattern == "" {
+		return flakyTests
+	}
+	// The pattern is already validated when loading config
+	re := regexp.MustCompile(channel.TestPattern)
+	var res []string
+	for _, test := range flakyTests {
+		if re.MatchString(test) {
+			res = append(res, test)
+		}
+	}
+	return res
+}
+
+// createSlackMessageForRepo creates slack message layout from RepoData,
+// listing the given flaky tests
+func createSlackMessageForRepo(rd RepoData, flakyTests []string, flakyIssuesMap map[string][]flakyIssue) string {
 	message := fmt.Sprintf("As of %s, there are %d flaky tests in '%s' from repo '%s'",
 		time.Unix(*rd.LastBuildStartTime, 0).String(), len(flakyTests), rd.Config.Name, rd.Config.Repo)
 	if rd.Config.IssueRepo == "" {
@@ -87,7 +133,13 @@ func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations,
 			channel := channels[i]
 			go func() {
 				defer wg.Done()
-				message := createSlackMessageForRepo(rd, flakyIssues)
+				defer func() { ch <- true }()
+				flakyTests := filterFlakyTestsForChannel(rd, channel)
+				if len(flakyTests) == 0 && hasFilters(channel) {
+					log.Printf("no flaky test in job '%s' from repo '%s' matches filters of channel '%s', skipping", rd.Config.Name, rd.Config.Repo, channel.Name)
+					return
+				}
+				message := createSlackMessageForRepo(rd, flakyTests, flakyIssues)
 				if err := helpers.Run(
 					fmt.Sprintf("post Slack message for job '%s' from repo '%s' in channel '%s'", rd.Config.Name, rd.Config.Repo, channel.Name),
 					func() error {
@@ -101,7 +153,6 @@ func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations,
 				if dryrun {
 					log.Printf("[dry run] Slack message not sent. See it below:\n%s\n\n", message)
 				}
-				ch <- true
 			}()
 		}
 		wg.Wait()
@@ -109,3 +160,126 @@ func sendSlackNotifications(repoDataAll []RepoData, c slackutil.WriteOperations,
 	}
 	return helpers.CombineErrors(allErrs)
 }
+
+// channelDigest contains all jobs, and their flaky tests, to be notified to a channel
+type channelDigest struct {
+	channel    config.SlackChannel
+	repoData   []RepoData
+	flakyTests [][]string // flaky tests for each RepoData in repoData
+}
+
+// groupByChannel groups jobs by the Slack channels they notify, jobs without
+// flaky tests matching filters of a channel are not added to the channel, while
+// channels without filters get all jobs, including jobs without flaky tests.
+// Channels are sorted by name.
+func groupByChannel(repoDataAll []RepoData) []*channelDigest {
+	digests := make(map[string]*channelDigest)
+	for _, rd := range repoDataAll {
+		for _, channel := range rd.Config.SlackChannels {
+			flakyTests := filterFlakyTestsForChannel(rd, channel)
+			if len(flakyTests) == 0 && hasFilters(channel) {
+				continue
+			}
+			// Filters are per job, the same channel can have different filters in different jobs
+			if _, ok := digests[channel.Identity]; !ok {
+				digests[channel.Identity] = &channelDigest{channel: channel}
+			}
+			digests[channel.Identity].repoData = append(digests[channel.Identity].repoData, rd)
+			digests[channel.Identity].flakyTests = append(digests[channel.Identity].flakyTests, flakyTests)
+		}
+	}
+	var res []*channelDigest
+	for _, d := range digests {
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].channel.Name < res[j].channel.Name
+	})
+	return res
+}
+
+// createDigestSummary creates the summary message of a digest, which lists
+// count of flaky tests for each job, details are posted in thread
+func createDigestSummary(digest string, cd *channelDigest) *slackutil.Message {
+	title := fmt.Sprintf("Flaky tests %s digest", digest)
+	var lines []string
+	for i, rd := range cd.repoData {
+		lines = append(lines, fmt.Sprintf("• *%s* (%s): %d flaky tests, flaky rate %.2f%%",
+			rd.Config.Name, rd.Config.Repo, len(cd.flakyTests[i]), getFlakyRate(rd)*100))
+	}
+	summary := strings.Join(lines, "\n")
+	return &slackutil.Message{
+		Text: fmt.Sprintf("%s\n%s", title, summary),
+		Blocks: []slackutil.Block{
+			slackutil.NewHeaderBlock(title),
+			slackutil.NewSectionBlock(summary),
+			slackutil.NewContextBlock("See thread for flaky tests of each job"),
+		},
+	}
+}
+
+// sendSlackDigests posts a summary message to each channel, and replies in
+// thread with details of each job
+func sendSlackDigests(digest string, repoDataAll []RepoData, c slackutil.WriteOperations, flakyIssues map[string][]flakyIssue, dryrun bool) error {
+	var allErrs []error
+	var mutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, cd := range groupByChannel(repoDataAll) {
+		wg.Add(1)
+		go func(cd *channelDigest) {
+			defer wg.Done()
+			var errs []error
+			summary := createDigestSummary(digest, cd)
+			var threadTS string
+			if err := helpers.Run(
+				fmt.Sprintf("post Slack digest in channel '%s'", cd.channel.Name),
+				func() error {
+					var err error
+					threadTS, err = c.PostMessage(summary, cd.channel.Identity)
+					return err
+				},
+				dryrun,
+			); err != nil {
+				log.Printf("failed sending digest to Slack channel '%s': '%v'", cd.channel.Name, err)
+				errs = append(errs, err)
+			}
+			if dryrun {
+				log.Printf("[dry run] Slack digest not sent. See it below:\n%s\n\n", summary.Text)
+			}
+			// Details without the summary would be confusing
+			if threadTS == "" && !dryrun {
+				mutex.Lock()
+				allErrs = append(allErrs, errs...)
+				mutex.Unlock()
+				return
+			}
+			for i, rd := range cd.repoData {
+				text := createSlackMessageForRepo(rd, cd.flakyTests[i], flakyIssues)
+				message := &slackutil.Message{
+					Text:     text,
+					Blocks:   []slackutil.Block{slackutil.NewSectionBlock(text)},
+					ThreadTS: threadTS,
+				}
+				if err := helpers.Run(
+					fmt.Sprintf("post Slack digest details for job '%s' from repo '%s' in channel '%s'", rd.Config.Name, rd.Config.Repo, cd.channel.Name),
+					func() error {
+						_, err := c.PostMessage(message, cd.channel.Identity)
+						return err
+					},
+					dryrun,
+				); err != nil {
+					log.Printf("failed sending digest details to Slack channel '%s': '%v'", cd.channel.Name, err)
+					errs = append(errs, err)
+				}
+				if dryrun {
+					log.Printf("[dry run] Slack message not sent. See it below:\n%s\n\n", text)
+				}
+			}
+			mutex.Lock()
+			allErrs = append(allErrs, errs...)
+			mutex.Unlock()
+		}(cd)
+	}
+	wg.Wait()
+	return helpers.CombineErrors(allErrs)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/pkg/slackutil/fakeslackutil"
+	"knative.dev/test-infra/tools/flaky-test-reporter/config"
+)
+
+func slackTestRepoData(name string, channels ...config.SlackChannel) RepoData {
+	startTime := int64(0)
+	testStats := make(map[string]*TestStat)
+	for _, test := range []string{"p1", "p2", "p3", "p4", "p5", "p6", "p7", "p8"} {
+		testStats[test] = &TestStat{TestName: test, Passed: []int{1, 2}}
+	}
+	for _, test := range []string{"TestA", "TestB"} {
+		testStats[test] = &TestStat{TestName: test, Passed: []int{1}, Failed: []int{2}}
+	}
+	return RepoData{
+		Config: config.JobConfig{
+			Name:          name,
+			Repo:          fakeRepo,
+			SlackChannels: channels,
+		},
+		TestStats:          testStats,
+		BuildIDs:           []int{2, 1},
+		LastBuildStartTime: &startTime,
+	}
+}
+
+func TestShouldNotify(t *testing.T) {
+	monday := time.Date(2020, 8, 3, 12, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	sunday := monday.AddDate(0, 0, -1)
+	tests := []struct {
+		digest string
+		t      time.Time
+		want   bool
+	}{
+		{noDigest, monday, true},
+		{noDigest, sunday, false},
+		{dailyDigest, tuesday, true},
+		{dailyDigest, sunday, false},
+		{weeklyDigest, monday, true},
+		{weeklyDigest, tuesday, false},
+	}
+	for _, tt := range tests {
+		if got := shouldNotify(tt.digest, tt.t); got != tt.want {
+			t.Errorf("shouldNotify(%q, %v) = %v, want %v", tt.digest, tt.t.Weekday(), got, tt.want)
+		}
+	}
+}
+
+func TestFilterFlakyTestsForChannel(t *testing.T) {
+	rd := slackTestRepoData("job") // flaky rate is 0.2
+	tests := []struct {
+		name    string
+		channel config.SlackChannel
+		want    []string
+	}{
+		{"no filter", config.SlackChannel{}, []string{"TestA", "TestB"}},
+		{"rate above minimal", config.SlackChannel{MinFlakyRate: 0.1}, []string{"TestA", "TestB"}},
+		{"rate below minimal", config.SlackChannel{MinFlakyRate: 0.5}, nil},
+		{"test pattern", config.SlackChannel{TestPattern: "B$"}, []string{"TestB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterFlakyTestsForChannel(rd, tt.channel); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterFlakyTestsForChannel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendSlackNotifications(t *testing.T) {
+	all := config.SlackChannel{Name: "all", Identity: "C00000001"}
+	onlyC := config.SlackChannel{Name: "only-c", Identity: "C00000002", TestPattern: "TestC"}
+	stable := slackTestRepoData("stable", all, onlyC)
+	for _, ts := range stable.TestStats {
+		ts.Failed = nil
+	}
+
+	client := fakeslackutil.NewFakeSlackClient()
+	if err := sendSlackNotifications([]RepoData{stable}, client, nil, false); err != nil {
+		t.Fatalf("sendSlackNotifications() failed: %v", err)
+	}
+	// channels without filters are notified of jobs without flaky tests
+	allMessages := client.Messages(all.Identity)
+	if len(allMessages) != 1 || !strings.Contains(allMessages[0].Text, "there are 0 flaky tests in 'stable'") {
+		t.Errorf("got messages %+v in channel 'all', want one message with 0 flaky tests", allMessages)
+	}
+	if got := client.Messages(onlyC.Identity); len(got) != 0 {
+		t.Errorf("got %d messages in channel 'only-c', want none", len(got))
+	}
+
+	client = fakeslackutil.NewFakeSlackClient()
+	if err := sendSlackDigests(dailyDigest, []RepoData{stable}, client, nil, false); err != nil {
+		t.Fatalf("sendSlackDigests() failed: %v", err)
+	}
+	allMessages = client.Messages(all.Identity)
+	if len(allMessages) != 2 || !strings.Contains(allMessages[0].Text, "*stable* (fakerepo): 0 flaky tests") {
+		t.Errorf("got messages %+v in channel 'all', want a digest with 0 flaky tests", allMessages)
+	}
+	if got := client.Messages(onlyC.Identity); len(got) != 0 {
+		t.Errorf("got %d digest messages in channel 'only-c', want none", len(got))
+	}
+}
+
+func TestSendSlackDigests(t *testing.T) {
+	all := config.SlackChannel{Name: "all", Identity: "C00000001"}
+	onlyB := config.SlackChannel{Name: "only-b", Identity: "C00000002", TestPattern: "TestB"}
+	strict := config.SlackChannel{Name: "strict", Identity: "C00000003", MinFlakyRate: 0.5}
+	repoDataAll := []RepoData{
+		slackTestRepoData("job1", all, onlyB),
+		slackTestRepoData("job2", all, strict),
+	}
+
+	client := fakeslackutil.NewFakeSlackClient()
+	if err := sendSlackDigests(dailyDigest, repoDataAll, client, nil, false); err != nil {
+		t.Fatalf("sendSlackDigests() failed: %v", err)
+	}
+
+	allMessages := client.Messages(all.Identity)
+	if len(allMessages) != 3 {
+		t.Fatalf("got %d messages in channel 'all', want 3", len(allMessages))
+	}
+	summary := allMessages[0]
+	if summary.ThreadTS != "" || len(summary.Blocks) == 0 {
+		t.Errorf("summary message should be a top level message with blocks, got %+v", summary)
+	}
+	for _, job := range []string{"job1", "job2"} {
+		if !strings.Contains(summary.Text, job) {
+			t.Errorf("summary message doesn't mention '%s': %s", job, summary.Text)
+		}
+	}
+	for _, detail := range allMessages[1:] {
+		if detail.ThreadTS != "1.000000" {
+			t.Errorf("details should be in thread of summary message, got thread '%s'", detail.ThreadTS)
+		}
+	}
+
+	onlyBMessages := client.Messages(onlyB.Identity)
+	if len(onlyBMessages) != 2 {
+		t.Fatalf("got %d messages in channel 'only-b', want 2", len(onlyBMessages))
+	}
+	if detail := onlyBMessages[1].Text; strings.Contains(detail, "TestA") || !strings.Contains(detail, "TestB") {
+		t.Errorf("details in channel 'only-b' should only list TestB, got %s", detail)
+	}
+
+	if got := client.Messages(strict.Identity); len(got) != 0 {
+		t.Errorf("got %d messages in channel 'strict', want none", len(got))
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// annotation.go marks failed postsubmit and periodic runs that were rerun by
+// the retryer, with a file in the GCS directory of the run and metadata in its
+// finished.json for TestGrid. The ProwJob of the rerun is annotated with the
+// build ID of the failed run, which is how a failed rerun is told apart from a
+// new failure, as these jobs don't have a pull request for keeping track of
+// retries.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"knative.dev/test-infra/pkg/prow"
+)
+
+const (
+	// rerunMarkerFile is written in the GCS directory of runs rerun by the retryer
+	rerunMarkerFile = "flaky-test-retryer.json"
+	// rerunOfAnnotation is set on the ProwJob of reruns to the build ID of the
+	// failed run
+	rerunOfAnnotation = "flaky-test-retryer.knative.dev/rerun-of"
+	// rerunMetadataKey is set in the finished.json metadata of both the failed
+	// run and its rerun, so that TestGrid can show them
+	rerunMetadataKey = "flaky-test-retryer"
+)
+
+// RerunMarker is the content of rerunMarkerFile
+type RerunMarker struct {
+	Time        time.Time `json:"time"`
+	Reason      string    `json:"reason"`
+	FailedTests []string  `json:"failedTests"`
+	// FlakySources maps failed tests to the sources reporting them as flaky
+	FlakySources map[string][]string `json:"flakySources,omitempty"`
+}
+
+// rerunOf returns the build ID of the failed run the ProwJob reruns, or an
+// empty string if the ProwJob wasn't created by the retryer
+func rerunOf(prowJob []byte) (string, error) {
+	var pj struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(prowJob, &pj); err != nil {
+		return "", err
+	}
+	return pj.Metadata.Annotations[rerunOfAnnotation], nil
+}
+
+// isRerun checks whether the run was created by the retryer rerunning a failed
+// run, and returns the build ID of the failed run
+func (jd *JobData) isRerun() (bool, string, error) {
+	contents, err := readProwJob(jd)
+	if err != nil {
+		return false, "", fmt.Errorf("failed reading %s: %v", prowJobFile, err)
+	}
+	failedRun, err := rerunOf(contents)
+	if err != nil {
+		return false, "", fmt.Errorf("failed parsing %s: %v", prowJobFile, err)
+	}
+	return failedRun != "", failedRun, nil
+}
+
+// isMarkedRerun checks whether the run was already rerun by the retryer, e.g.
+// when the message is redelivered after the decision store lost its state
+func (jd *JobData) isMarkedRerun() (bool, error) {
+	build, err := jd.build()
+	if err != nil {
+		return false, err
+	}
+	return build.FileExists(rerunMarkerFile), nil
+}
+
+// markRerun writes the rerun marker in the GCS directory of the run
+func (jd *JobData) markRerun(failedTests []string, dryrun bool) error {
+	build, err := jd.build()
+	if err != nil {
+		return err
+	}
+	sources := make(map[string][]string)
+	for _, test := range failedTests {
+		if s, ok := jd.flakySources[test]; ok {
+			sources[test] = s
+		}
+	}
+	contents, err := json.MarshalIndent(RerunMarker{
+		Time:         time.Now(),
+		Reason:       "failed tests are flaky",
+		FailedTests:  failedTests,
+		FlakySources: sources,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dryrun {
+		logWithPrefix(jd, "[dry run] Rerun marker not written:\n%s\n", contents)
+		return nil
+	}
+	return build.WriteFile(rerunMarkerFile, contents)
+}
+
+// addRerunMetadata sets rerunMetadataKey in the finished.json metadata of the
+// run to value
+func (jd *JobData) addRerunMetadata(value string, dryrun bool) error {
+	build, err := jd.build()
+	if err != nil {
+		return err
+	}
+	if dryrun {
+		logWithPrefix(jd, "[dry run] %s metadata %q not set to %q\n", prow.FinishedJSON, rerunMetadataKey, value)
+		return nil
+	}
+	finished, err := build.ReadFile(prow.FinishedJSON)
+	if err != nil {
+		return fmt.Errorf("failed reading %s: %v", prow.FinishedJSON, err)
+	}
+	contents, err := withRerunMetadata(finished, value)
+	if err != nil {
+		return fmt.Errorf("failed parsing %s: %v", prow.FinishedJSON, err)
+	}
+	return build.WriteFile(prow.FinishedJSON, contents)
+}
+
+// withRerunMetadata returns finished.json with rerunMetadataKey set to value in
+// its metadata, keeping all other fields
+func withRerunMetadata(finished []byte, value string) ([]byte, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(finished, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	metadata, _ := fields["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata[rerunMetadataKey] = value
+	fields["metadata"] = metadata
+	return json.Marshal(fields)
+}
+
+// build returns the GCS build of the run
+func (jd *JobData) build() (*prow.Build, error) {
+	buildID, err := strconv.Atoi(jd.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid run ID %q: %v", jd.RunID, err)
+	}
+	return jd.prowJob().NewBuild(buildID), nil
+}
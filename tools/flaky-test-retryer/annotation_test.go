@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRerunOf(t *testing.T) {
+	cases := []struct {
+		name    string
+		prowJob string
+		want    string
+		wantErr bool
+	}{
+		{"rerun", `{"metadata": {"annotations": {"flaky-test-retryer.knative.dev/rerun-of": "1234"}}}`, "1234", false},
+		{"other annotations", `{"metadata": {"annotations": {"prow.k8s.io/job": "fakejob"}}}`, "", false},
+		{"no annotations", `{"metadata": {"name": "fake-uuid"}}`, "", false},
+		{"invalid json", `{"metadata"`, "", true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rerunOf([]byte(tt.prowJob))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rerunOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rerunOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRerunMetadata(t *testing.T) {
+	cases := []struct {
+		name     string
+		finished string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			"existing metadata",
+			`{"timestamp": 1596283200, "passed": false, "metadata": {"repo": "fakerepo"}}`,
+			map[string]interface{}{
+				"timestamp": float64(1596283200),
+				"passed":    false,
+				"metadata":  map[string]interface{}{"repo": "fakerepo", "flaky-test-retryer": "rerun"},
+			},
+			false,
+		},
+		{
+			"no metadata",
+			`{"passed": false}`,
+			map[string]interface{}{
+				"passed":   false,
+				"metadata": map[string]interface{}{"flaky-test-retryer": "rerun"},
+			},
+			false,
+		},
+		{"invalid json", `{"passed"`, nil, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			contents, err := withRerunMetadata([]byte(tt.finished), "rerun")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withRerunMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(contents, &got); err != nil {
+				t.Fatalf("failed parsing result: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("withRerunMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// audit.go keeps a record of every decision made by the retryer, and whether
+// retried jobs eventually succeeded. Decisions are written to a structured
+// audit log, counted in metrics, and summarized per job.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	// TODO: remove this import once "k8s.io/test-infra" import problems are fixed
+	// https://github.com/test-infra/test-infra/issues/912
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+const (
+	retrySucceeded = "success"
+	retryFailed    = "failure"
+
+	metricsPath = "/metrics"
+	summaryPath = "/summary"
+)
+
+// AuditEntry is a line of the audit log, recording a decision made for a job
+// run or the result of a retried job
+type AuditEntry struct {
+	Time          time.Time `json:"time"`
+	Org           string    `json:"org"`
+	Repo          string    `json:"repo"`
+	Pull          int       `json:"pull,omitempty"`
+	Job           string    `json:"job"`
+	RunID         string    `json:"runID"`
+	Action        string    `json:"action,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	FailedTests   []string  `json:"failedTests,omitempty"`
+	BlockingTests []string  `json:"blockingTests,omitempty"`
+	RetryResult   string    `json:"retryResult,omitempty"`
+}
+
+// JobSummary summarizes the decisions and retry results of a job
+type JobSummary struct {
+	Org              string         `json:"org"`
+	Repo             string         `json:"repo"`
+	Job              string         `json:"job"`
+	Decisions        map[string]int `json:"decisions"`
+	RetrySuccesses   int            `json:"retrySuccesses"`
+	RetryFailures    int            `json:"retryFailures"`
+	RetrySuccessRate float64        `json:"retrySuccessRate"`
+	// RetriedTests counts how often each failed test caused a retry
+	RetriedTests map[string]int `json:"retriedTests,omitempty"`
+}
+
+// Summary summarizes everything recorded by the Auditor since it started
+type Summary struct {
+	Since             time.Time    `json:"since"`
+	MessagesReceived  int          `json:"messagesReceived"`
+	MessagesSupported int          `json:"messagesSupported"`
+	Jobs              []JobSummary `json:"jobs"`
+}
+
+// pendingRetry is a retried job run waiting for the result of its rerun
+type pendingRetry struct {
+	runID   string
+	pull    int
+	summary *JobSummary
+}
+
+// retryKey identifies the runs of a job that are reruns of each other, in the
+// format of "org/repo#pull/job" for presubmit jobs. Job names are used for other
+// jobs, as messages of periodic jobs may not have refs.
+func retryKey(msg *prowapi.ReportMessage) (string, bool) {
+	switch msg.JobType {
+	case prowapi.PresubmitJob:
+		if len(msg.Refs) == 0 || len(msg.Refs[0].Pulls) == 0 {
+			return "", false
+		}
+		ref := msg.Refs[0]
+		return fmt.Sprintf("%s/%s#%d/%s", ref.Org, ref.Repo, ref.Pulls[0].Number, msg.JobName), true
+	case prowapi.PostsubmitJob, prowapi.PeriodicJob:
+		return msg.JobName, true
+	default:
+		return "", false
+	}
+}
+
+// Auditor records decisions and retry results, it's safe for concurrent use
+type Auditor struct {
+	mutex   sync.Mutex
+	log     io.Writer // audit log, entries are not written if nil
+	summary Summary
+	jobs    map[string]*JobSummary
+	// pending are retried job runs keyed by retryKey, they are only kept in memory so results of retries made before a restart are not counted
+	pending map[string]pendingRetry
+
+	received  *counter
+	supported *counter
+	decisions *counter
+	results   *counter
+}
+
+// NewAuditor creates an Auditor writing the audit log to w, which can be nil
+func NewAuditor(w io.Writer) *Auditor {
+	return &Auditor{
+		log:       w,
+		summary:   Summary{Since: time.Now()},
+		jobs:      make(map[string]*JobSummary),
+		pending:   make(map[string]pendingRetry),
+		received:  newCounter("messages_received_total", "Messages received from Pub/Sub and HTTP."),
+		supported: newCounter("messages_supported_total", "Messages fitting the retry criteria."),
+		decisions: newCounter("decisions_total", "Decisions made for failed job runs.", "org", "repo", "job", "action"),
+		results:   newCounter("retry_results_total", "Results of retried jobs.", "org", "repo", "job", "result"),
+	}
+}
+
+// MessageReceived counts a received message, and records the retry result if
+// the message reports the rerun of a retried job
+func (a *Auditor) MessageReceived(msg *prowapi.ReportMessage, timestamp time.Time) {
+	a.received.inc()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.summary.MessagesReceived++
+
+	var result string
+	switch msg.Status {
+	case prowapi.SuccessState:
+		result = retrySucceeded
+	case prowapi.FailureState:
+		result = retryFailed
+	default:
+		return
+	}
+	key, ok := retryKey(msg)
+	if !ok {
+		return
+	}
+	pr, ok := a.pending[key]
+	if !ok || pr.runID == msg.RunID {
+		return
+	}
+	delete(a.pending, key)
+	if result == retrySucceeded {
+		pr.summary.RetrySuccesses++
+	} else {
+		pr.summary.RetryFailures++
+	}
+	pr.summary.RetrySuccessRate = float64(pr.summary.RetrySuccesses) / float64(pr.summary.RetrySuccesses+pr.summary.RetryFailures)
+	a.results.inc(pr.summary.Org, pr.summary.Repo, msg.JobName, result)
+	a.write(AuditEntry{
+		Time:        timestamp,
+		Org:         pr.summary.Org,
+		Repo:        pr.summary.Repo,
+		Pull:        pr.pull,
+		Job:         msg.JobName,
+		RunID:       msg.RunID,
+		RetryResult: result,
+	})
+}
+
+// MessageSupported counts a message fitting the retry criteria
+func (a *Auditor) MessageSupported() {
+	a.supported.inc()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.summary.MessagesSupported++
+}
+
+// Record records the decision made for a job run
+func (a *Auditor) Record(jd *JobData, o *jobOutcome) {
+	ref := jd.Refs[0]
+	a.decisions.inc(ref.Org, ref.Repo, jd.JobName, o.action)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	jobKey := fmt.Sprintf("%s/%s/%s", ref.Org, ref.Repo, jd.JobName)
+	js, ok := a.jobs[jobKey]
+	if !ok {
+		js = &JobSummary{Org: ref.Org, Repo: ref.Repo, Job: jd.JobName, Decisions: make(map[string]int)}
+		a.jobs[jobKey] = js
+	}
+	js.Decisions[o.action]++
+	if o.action == actionRetried {
+		if js.RetriedTests == nil {
+			js.RetriedTests = make(map[string]int)
+		}
+		for _, test := range jd.failedTests {
+			js.RetriedTests[test]++
+		}
+		if key, ok := retryKey(jd.ReportMessage); ok {
+			a.pending[key] = pendingRetry{jd.RunID, jd.pullNumber(), js}
+		}
+	}
+	a.write(AuditEntry{
+		Time:          time.Now(),
+		Org:           ref.Org,
+		Repo:          ref.Repo,
+		Pull:          jd.pullNumber(),
+		Job:           jd.JobName,
+		RunID:         jd.RunID,
+		Action:        o.action,
+		Reason:        o.reason,
+		FailedTests:   jd.failedTests,
+		BlockingTests: o.blockingTests,
+	})
+}
+
+// write appends the entry to the audit log as a JSON line, mutex must be held
+func (a *Auditor) write(e AuditEntry) {
+	if a.log == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed marshalling audit entry: %v", err)
+		return
+	}
+	if _, err := a.log.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed writing audit entry: %v", err)
+	}
+}
+
+// Summary returns the summary of everything recorded, jobs are sorted by
+// org, repo and job
+func (a *Auditor) Summary() Summary {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	s := a.summary
+	s.Jobs = []JobSummary{}
+	for _, js := range a.jobs {
+		// copy maps, as they are still updated after the lock is released
+		c := *js
+		c.Decisions = make(map[string]int)
+		for k, v := range js.Decisions {
+			c.Decisions[k] = v
+		}
+		if js.RetriedTests != nil {
+			c.RetriedTests = make(map[string]int)
+			for k, v := range js.RetriedTests {
+				c.RetriedTests[k] = v
+			}
+		}
+		s.Jobs = append(s.Jobs, c)
+	}
+	sort.Slice(s.Jobs, func(i, j int) bool {
+		a, b := s.Jobs[i], s.Jobs[j]
+		if a.Org != b.Org {
+			return a.Org < b.Org
+		}
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Job < b.Job
+	})
+	return s
+}
+
+// newMetricsServer creates an HTTP handler serving the metrics and summary of
+// the auditor, separate from the webhook server so that metrics can be scraped
+// without exposing the endpoints receiving messages.
+func (a *Auditor) newMetricsServer() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, a.serveMetrics)
+	mux.HandleFunc(summaryPath, a.serveSummary)
+	return mux
+}
+
+// serveMetrics serves all metrics in Prometheus text format
+func (a *Auditor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, c := range []*counter{a.received, a.supported, a.decisions, a.results} {
+		c.write(w)
+	}
+}
+
+// serveSummary serves the summary in JSON
+func (a *Auditor) serveSummary(w http.ResponseWriter, r *http.Request) {
+	contents, err := json.MarshalIndent(a.Summary(), "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed marshalling summary: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(contents)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+// auditTestJob creates a presubmit job run of fakejob0 on fakeorg/fakerepo#127
+func auditTestJob(runID string, status prowapi.ProwJobState, failedTests ...string) *JobData {
+	return &JobData{
+		ReportMessage: &prowapi.ReportMessage{
+			RunID:   runID,
+			Status:  status,
+			JobType: prowapi.PresubmitJob,
+			JobName: "fakejob0",
+			Refs: []prowapi.Refs{{
+				Org:   fakeOrg,
+				Repo:  fakeRepo,
+				Pulls: []prowapi.Pull{{Number: fakePullID, SHA: fakeSHA}},
+			}},
+		},
+		failedTests: failedTests,
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuditor(&buf)
+	now := time.Now()
+
+	// first run failed and was retried
+	run1 := auditTestJob("1", prowapi.FailureState, "test0")
+	a.MessageReceived(run1.ReportMessage, now)
+	a.MessageSupported()
+	a.Record(run1, &jobOutcome{action: actionRetried})
+	// duplicate delivery of the retried run is not a retry result
+	a.MessageReceived(run1.ReportMessage, now)
+	// rerun succeeded
+	a.MessageReceived(auditTestJob("2", prowapi.SuccessState).ReportMessage, now)
+	// success is only counted once
+	a.MessageReceived(auditTestJob("3", prowapi.SuccessState).ReportMessage, now)
+	// another run blocked by non-flaky tests
+	run4 := auditTestJob("4", prowapi.FailureState, "test0", "test1")
+	a.MessageReceived(run4.ReportMessage, now)
+	a.MessageSupported()
+	a.Record(run4, &jobOutcome{action: actionBlocked, blockingTests: []string{"test1"}})
+
+	s := a.Summary()
+	if s.MessagesReceived != 5 || s.MessagesSupported != 2 {
+		t.Errorf("got %d received and %d supported messages, want 5 and 2", s.MessagesReceived, s.MessagesSupported)
+	}
+	want := []JobSummary{{
+		Org:              fakeOrg,
+		Repo:             fakeRepo,
+		Job:              "fakejob0",
+		Decisions:        map[string]int{actionRetried: 1, actionBlocked: 1},
+		RetrySuccesses:   1,
+		RetrySuccessRate: 1,
+		RetriedTests:     map[string]int{"test0": 1},
+	}}
+	if !reflect.DeepEqual(s.Jobs, want) {
+		t.Errorf("got job summaries %+v, want %+v", s.Jobs, want)
+	}
+
+	var entries []AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e AuditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d audit log entries, want 3", len(entries))
+	}
+	if entries[0].Action != actionRetried || entries[1].RetryResult != retrySucceeded || entries[2].Action != actionBlocked {
+		t.Errorf("got audit log entries %+v, want retried, retry succeeded, then blocked", entries)
+	}
+	if !reflect.DeepEqual(entries[2].BlockingTests, []string{"test1"}) {
+		t.Errorf("got blocking tests %v, want [test1]", entries[2].BlockingTests)
+	}
+}
+
+func TestAuditorPeriodic(t *testing.T) {
+	a := NewAuditor(nil)
+	run := &JobData{
+		ReportMessage: &prowapi.ReportMessage{
+			RunID:   "1",
+			Status:  prowapi.FailureState,
+			JobType: prowapi.PeriodicJob,
+			JobName: "ci-fakejob",
+			// extra refs loaded from the ProwJob
+			Refs: []prowapi.Refs{{Org: fakeOrg, Repo: fakeRepo}},
+		},
+		failedTests: []string{"test0"},
+	}
+	a.Record(run, &jobOutcome{action: actionRetried})
+	// messages of periodic jobs have no refs
+	a.MessageReceived(&prowapi.ReportMessage{
+		RunID:   "2",
+		Status:  prowapi.FailureState,
+		JobType: prowapi.PeriodicJob,
+		JobName: "ci-fakejob",
+	}, time.Now())
+
+	s := a.Summary()
+	if len(s.Jobs) != 1 || s.Jobs[0].RetryFailures != 1 || s.Jobs[0].RetrySuccessRate != 0 {
+		t.Errorf("got job summaries %+v, want one failed rerun", s.Jobs)
+	}
+	if got := a.results.get(fakeOrg, fakeRepo, "ci-fakejob", retryFailed); got != 1 {
+		t.Errorf("got %v failed retry results, want 1", got)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	a := NewAuditor(nil)
+	run := auditTestJob("1", prowapi.FailureState)
+	a.MessageReceived(run.ReportMessage, time.Now())
+	a.Record(run, &jobOutcome{action: actionOutOfRetries})
+
+	server := a.newMetricsServer()
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE flaky_test_retryer_messages_received_total counter",
+		"flaky_test_retryer_messages_received_total 1",
+		`flaky_test_retryer_decisions_total{org="fakeorg",repo="fakerepo",job="fakejob0",action="out of retries"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics don't contain %q:\n%s", want, body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, summaryPath, nil))
+	var s Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatalf("invalid summary: %v", err)
+	}
+	if len(s.Jobs) != 1 || s.Jobs[0].Decisions[actionOutOfRetries] != 1 {
+		t.Errorf("got summary %+v, want one job out of retries", s)
+	}
+}
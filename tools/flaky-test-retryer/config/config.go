@@ -0,0 +1,225 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// config.go contains retry policies for flaky-test-retryer, configured per
+// org, repo and job
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"knative.dev/test-infra/pkg/helpers"
+)
+
+const (
+	// DefaultMaxRetries is the retry budget used when it's not configured
+	DefaultMaxRetries = 3
+	// maxRetriesLimit is bounded by the retry comment format, which only
+	// supports a single digit
+	maxRetriesLimit = 9
+	// MaxBackoff is the longest configurable backoff, and the longest wait before
+	// any retry once the backoff is doubled
+	MaxBackoff = 30 * time.Minute
+)
+
+// Config contains the default retry settings, and rules overriding them for
+// specific orgs, repos or jobs
+type Config struct {
+	Default Settings `yaml:"default,omitempty"`
+	Rules   []Rule   `yaml:"rules,omitempty"`
+}
+
+// Rule overrides settings for jobs matching Org, Repo and Job. Empty Repo or
+// Job matches all repos or jobs.
+type Rule struct {
+	Org      string `yaml:"org"`
+	Repo     string `yaml:"repo,omitempty"`
+	Job      string `yaml:"job,omitempty"`
+	Settings `yaml:",inline"`
+}
+
+// Settings are retry settings, unset fields are inherited from less specific
+// rules and the default
+type Settings struct {
+	// MaxRetries is the number of retries allowed for a job on a commit
+	MaxRetries *int `yaml:"maxRetries,omitempty"`
+	// FlakyTestPatterns are regular expressions, failed tests with matching
+	// names are treated as flaky even if they are not in the flaky report
+	FlakyTestPatterns []string `yaml:"flakyTestPatterns,omitempty"`
+	// MaxNonFlakyFraction is the maximal fraction of failed tests, in range
+	// [0, 1], that can be non-flaky while still retrying
+	MaxNonFlakyFraction *float64 `yaml:"maxNonFlakyFraction,omitempty"`
+	// Backoff is the time to wait after a failure before the first retry,
+	// it doubles after each retry
+	Backoff *time.Duration `yaml:"backoff,omitempty"`
+	// OptOutLabels are PR labels disabling retries
+	OptOutLabels []string `yaml:"optOutLabels,omitempty"`
+	// FlakySources are flaky test reports used in addition to the report of
+	// the job's own repo, e.g. for tests of shared libraries flaking across repos
+	FlakySources []FlakySource `yaml:"flakySources,omitempty"`
+}
+
+// FlakySource is the flaky test report of a repo, written by a reporter job
+type FlakySource struct {
+	// Job is the reporter job, the default reporter job is used if empty
+	Job  string `yaml:"job,omitempty"`
+	Repo string `yaml:"repo"`
+}
+
+// Policy is the resolved retry policy of a job
+type Policy struct {
+	MaxRetries          int
+	FlakyTestPatterns   []*regexp.Regexp
+	MaxNonFlakyFraction float64
+	Backoff             time.Duration
+	OptOutLabels        []string
+	FlakySources        []FlakySource
+}
+
+// Load reads and validates the config file. An empty path gives the config
+// with all default settings.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading config file '%s': %v", path, err)
+	}
+	c := &Config{}
+	// unknown fields are treated as errors so that typos don't result in
+	// silently ignored settings
+	if err := yaml.UnmarshalStrict(contents, c); err != nil {
+		return nil, fmt.Errorf("failed parsing config file '%s': %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %v", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks that all rules have an org, and all settings are in range
+func (c *Config) Validate() error {
+	var errs []error
+	if err := c.Default.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("default: %v", err))
+	}
+	for i, r := range c.Rules {
+		if r.Org == "" {
+			errs = append(errs, fmt.Errorf("rule #%d: missing org", i))
+		}
+		if r.Repo == "" && r.Job != "" {
+			errs = append(errs, fmt.Errorf("rule #%d: job '%s' set without repo", i, r.Job))
+		}
+		if err := r.Settings.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rule #%d: %v", i, err))
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+func (s Settings) validate() error {
+	var errs []error
+	if s.MaxRetries != nil && (*s.MaxRetries < 0 || *s.MaxRetries > maxRetriesLimit) {
+		errs = append(errs, fmt.Errorf("invalid maxRetries '%d', must be in range [0, %d]", *s.MaxRetries, maxRetriesLimit))
+	}
+	for _, p := range s.FlakyTestPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			errs = append(errs, fmt.Errorf("invalid flakyTestPattern: %v", err))
+		}
+	}
+	if s.MaxNonFlakyFraction != nil && (*s.MaxNonFlakyFraction < 0 || *s.MaxNonFlakyFraction > 1) {
+		errs = append(errs, fmt.Errorf("invalid maxNonFlakyFraction '%v', must be in range [0, 1]", *s.MaxNonFlakyFraction))
+	}
+	if s.Backoff != nil && (*s.Backoff < 0 || *s.Backoff > MaxBackoff) {
+		errs = append(errs, fmt.Errorf("invalid backoff '%v', must be in range [0, %v]", *s.Backoff, MaxBackoff))
+	}
+	for _, l := range s.OptOutLabels {
+		if l == "" {
+			errs = append(errs, fmt.Errorf("empty optOutLabel"))
+		}
+	}
+	for i, fs := range s.FlakySources {
+		if fs.Repo == "" {
+			errs = append(errs, fmt.Errorf("flakySource #%d: missing repo", i))
+		}
+	}
+	return helpers.CombineErrors(errs)
+}
+
+// PolicyFor resolves the policy of a job. Settings of matching rules are
+// applied over the default from the least to the most specific, rules with
+// the same specificity are applied in order.
+func (c *Config) PolicyFor(org, repo, job string) Policy {
+	var matched []Rule
+	for _, r := range c.Rules {
+		if r.Org == org && (r.Repo == "" || r.Repo == repo) && (r.Job == "" || r.Job == job) {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].specificity() < matched[j].specificity()
+	})
+
+	p := Policy{MaxRetries: DefaultMaxRetries}
+	p.apply(c.Default)
+	for _, r := range matched {
+		p.apply(r.Settings)
+	}
+	return p
+}
+
+func (r Rule) specificity() int {
+	if r.Job != "" {
+		return 2
+	}
+	if r.Repo != "" {
+		return 1
+	}
+	return 0
+}
+
+func (p *Policy) apply(s Settings) {
+	if s.MaxRetries != nil {
+		p.MaxRetries = *s.MaxRetries
+	}
+	if s.FlakyTestPatterns != nil {
+		p.FlakyTestPatterns = nil
+		for _, pattern := range s.FlakyTestPatterns {
+			// patterns were validated when loading
+			p.FlakyTestPatterns = append(p.FlakyTestPatterns, regexp.MustCompile(pattern))
+		}
+	}
+	if s.MaxNonFlakyFraction != nil {
+		p.MaxNonFlakyFraction = *s.MaxNonFlakyFraction
+	}
+	if s.Backoff != nil {
+		p.Backoff = *s.Backoff
+	}
+	if s.OptOutLabels != nil {
+		p.OptOutLabels = s.OptOutLabels
+	}
+	if s.FlakySources != nil {
+		p.FlakySources = s.FlakySources
+	}
+}
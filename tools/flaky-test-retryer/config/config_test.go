@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validConfig = `
+default:
+  maxRetries: 2
+  optOutLabels: ["no-flaky-retry"]
+rules:
+  - org: knative
+    repo: serving
+    job: pull-knative-serving-integration-tests
+    backoff: 5m
+  - org: knative
+    repo: serving
+    maxRetries: 5
+    maxNonFlakyFraction: 0.25
+    flakyTestPatterns: ["^test/e2e\\."]
+    flakySources:
+      - repo: pkg
+      - job: ci-knative-pkg-flakes-reporter
+        repo: pkg
+  - org: knative
+    optOutLabels: ["do-not-retry"]
+`
+
+func writeConfigFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed writing config file: %v", err)
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retryer-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"valid", validConfig, ""},
+		{"empty", ``, ""},
+		{"unknown field", "default:\n  maxRetry: 2", "field maxRetry not found"},
+		{"missing org", "rules:\n  - repo: serving", "missing org"},
+		{"job without repo", "rules:\n  - org: knative\n    job: fakejob", "job 'fakejob' set without repo"},
+		{"invalid maxRetries", "default:\n  maxRetries: 10", "invalid maxRetries '10'"},
+		{"invalid maxNonFlakyFraction", "default:\n  maxNonFlakyFraction: 1.5", "invalid maxNonFlakyFraction '1.5'"},
+		{"invalid backoff", "default:\n  backoff: 2h", "invalid backoff '2h0m0s'"},
+		{"invalid flakyTestPattern", "default:\n  flakyTestPatterns: ['[']", "invalid flakyTestPattern"},
+		{"empty optOutLabel", "default:\n  optOutLabels: ['']", "empty optOutLabel"},
+		{"flakySource without repo", "default:\n  flakySources: [{job: fakejob}]", "flakySource #0: missing repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeConfigFile(t, dir, strings.Replace(tt.name, " ", "-", -1)+".yaml", tt.contents)
+			_, err := Load(p)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Load() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(dir, "notexist.yaml")); err == nil {
+		t.Error("Load() should fail on a missing file")
+	}
+	if c, err := Load(""); err != nil || !reflect.DeepEqual(c, &Config{}) {
+		t.Errorf("Load(\"\") = %v, %v, want empty config", c, err)
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retryer-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := Load(writeConfigFile(t, dir, "config.yaml", validConfig))
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	tests := []struct {
+		name                 string
+		org, repo, job       string
+		wantMaxRetries       int
+		wantPatterns         int
+		wantNonFlakyFraction float64
+		wantBackoff          time.Duration
+		wantOptOutLabels     []string
+		wantFlakySources     int
+	}{
+		{"default", "google", "go-github", "fakejob", 2, 0, 0, 0, []string{"no-flaky-retry"}, 0},
+		{"org rule", "knative", "eventing", "fakejob", 2, 0, 0, 0, []string{"do-not-retry"}, 0},
+		{"repo rule", "knative", "serving", "fakejob", 5, 1, 0.25, 0, []string{"do-not-retry"}, 2},
+		{"job rule", "knative", "serving", "pull-knative-serving-integration-tests", 5, 1, 0.25, 5 * time.Minute, []string{"do-not-retry"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := c.PolicyFor(tt.org, tt.repo, tt.job)
+			if p.MaxRetries != tt.wantMaxRetries {
+				t.Errorf("MaxRetries = %d, want %d", p.MaxRetries, tt.wantMaxRetries)
+			}
+			if len(p.FlakyTestPatterns) != tt.wantPatterns {
+				t.Errorf("got %d FlakyTestPatterns, want %d", len(p.FlakyTestPatterns), tt.wantPatterns)
+			}
+			if p.MaxNonFlakyFraction != tt.wantNonFlakyFraction {
+				t.Errorf("MaxNonFlakyFraction = %v, want %v", p.MaxNonFlakyFraction, tt.wantNonFlakyFraction)
+			}
+			if p.Backoff != tt.wantBackoff {
+				t.Errorf("Backoff = %v, want %v", p.Backoff, tt.wantBackoff)
+			}
+			if !reflect.DeepEqual(p.OptOutLabels, tt.wantOptOutLabels) {
+				t.Errorf("OptOutLabels = %v, want %v", p.OptOutLabels, tt.wantOptOutLabels)
+			}
+			if len(p.FlakySources) != tt.wantFlakySources {
+				t.Errorf("got %d FlakySources, want %d", len(p.FlakySources), tt.wantFlakySources)
+			}
+		})
+	}
+
+	if got := (&Config{}).PolicyFor("knative", "serving", "fakejob").MaxRetries; got != DefaultMaxRetries {
+		t.Errorf("MaxRetries of empty config = %d, want %d", got, DefaultMaxRetries)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// decision_store.go records the decisions made for each job run, so that
+// duplicate deliveries of the same message are ignored. Decisions are persisted
+// to a JSON file, so that they survive restarts.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	// TODO: remove this import once "k8s.io/test-infra" import problems are fixed
+	// https://github.com/test-infra/test-infra/issues/912
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+// decisionRetention is how long decisions are kept, Pub/Sub doesn't redeliver
+// messages older than 7 days
+const decisionRetention = 14 * 24 * time.Hour
+
+// Decision is the outcome of processing a job run
+type Decision struct {
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DecisionStore keeps track of job runs being processed and job runs already
+// decided. It's safe for concurrent use.
+type DecisionStore struct {
+	path      string // file for persisting decisions, decisions are only kept in memory if empty
+	mutex     sync.Mutex
+	decisions map[string]Decision
+	inFlight  map[string]bool
+	deferred  map[string]time.Time // job runs backing off, until when they are due
+}
+
+// NewDecisionStore creates a DecisionStore, loading existing decisions from path
+// if the file exists
+func NewDecisionStore(path string) (*DecisionStore, error) {
+	ds := &DecisionStore{
+		path:      path,
+		decisions: make(map[string]Decision),
+		inFlight:  make(map[string]bool),
+		deferred:  make(map[string]time.Time),
+	}
+	if path == "" {
+		return ds, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading decisions file '%s': %v", path, err)
+	}
+	if err := json.Unmarshal(contents, &ds.decisions); err != nil {
+		return nil, fmt.Errorf("failed parsing decisions file '%s': %v", path, err)
+	}
+	return ds, nil
+}
+
+// decisionKey identifies a job run, in the format of "org/repo#pull/job/runID"
+// for presubmit jobs, and "job/runID" for other jobs, as the refs of periodic
+// jobs are only known once read from GCS. It's computed before checking that the
+// message is supported, so refs may be missing.
+func decisionKey(jd *JobData) string {
+	if jd.JobType == prowapi.PresubmitJob && len(jd.Refs) > 0 {
+		return fmt.Sprintf("%s/%s#%d/%s/%s", jd.Refs[0].Org, jd.Refs[0].Repo, jd.pullNumber(), jd.JobName, jd.RunID)
+	}
+	return fmt.Sprintf("%s/%s", jd.JobName, jd.RunID)
+}
+
+// Begin marks the job run as being processed. It returns false if the job run
+// was already decided or is being processed by another worker.
+func (ds *DecisionStore) Begin(key string) bool {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if _, ok := ds.decisions[key]; ok || ds.inFlight[key] {
+		return false
+	}
+	ds.inFlight[key] = true
+	return true
+}
+
+// Abort unmarks the job run as being processed without recording a decision,
+// so that it can be processed again on redelivery
+func (ds *DecisionStore) Abort(key string) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	delete(ds.inFlight, key)
+}
+
+// Defer unmarks the job run as being processed without recording a decision,
+// and remembers that it's not due before the given time
+func (ds *DecisionStore) Defer(key string, until time.Time) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	delete(ds.inFlight, key)
+	ds.deferred[key] = until
+}
+
+// Deferred returns how long the job run is still deferred for at now, 0 if
+// it's due
+func (ds *DecisionStore) Deferred(key string, now time.Time) time.Duration {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	until, ok := ds.deferred[key]
+	if !ok {
+		return 0
+	}
+	if !now.Before(until) {
+		delete(ds.deferred, key)
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// Record saves the decision of the job run and persists all decisions
+func (ds *DecisionStore) Record(key, action string) error {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	delete(ds.inFlight, key)
+	ds.decisions[key] = Decision{Action: action, Timestamp: time.Now()}
+	return ds.save()
+}
+
+// Get returns the decision of the job run, and whether it was decided
+func (ds *DecisionStore) Get(key string) (Decision, bool) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	d, ok := ds.decisions[key]
+	return d, ok
+}
+
+// save writes all decisions to a temporary file then renames it, so that a
+// crash while writing doesn't corrupt the existing file
+func (ds *DecisionStore) save() error {
+	if ds.path == "" {
+		return nil
+	}
+	for key, d := range ds.decisions {
+		if time.Since(d.Timestamp) > decisionRetention {
+			delete(ds.decisions, key)
+		}
+	}
+	contents, err := json.MarshalIndent(ds.decisions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling decisions: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(ds.path), filepath.Base(ds.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary decisions file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed writing decisions file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed writing decisions file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), ds.path); err != nil {
+		return fmt.Errorf("failed saving decisions file '%s': %v", ds.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+func TestDecisionKey(t *testing.T) {
+	cases := []struct {
+		jobType prowapi.ProwJobType
+		want    string
+	}{
+		{prowapi.PresubmitJob, "fakeorg/fakerepo#111/fakejob/1234"},
+		{prowapi.PeriodicJob, "fakejob/1234"},
+	}
+	for _, tt := range cases {
+		jd := &JobData{ReportMessage: &prowapi.ReportMessage{
+			RunID:   "1234",
+			JobName: "fakejob",
+			JobType: tt.jobType,
+			Refs: []prowapi.Refs{{
+				Org:   "fakeorg",
+				Repo:  "fakerepo",
+				Pulls: []prowapi.Pull{{Number: 111}},
+			}},
+		}}
+		if got := decisionKey(jd); got != tt.want {
+			t.Errorf("decisionKey() of %s job = %q, want %q", tt.jobType, got, tt.want)
+		}
+	}
+	noRefs := &JobData{ReportMessage: &prowapi.ReportMessage{RunID: "1234", JobName: "fakejob", JobType: prowapi.PresubmitJob}}
+	if got := decisionKey(noRefs); got != "fakejob/1234" {
+		t.Errorf("decisionKey() of job without refs = %q, want %q", got, "fakejob/1234")
+	}
+}
+
+func TestDecisionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decisions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "decisions.json")
+
+	ds, err := NewDecisionStore(path)
+	if err != nil {
+		t.Fatalf("NewDecisionStore() failed: %v", err)
+	}
+	if !ds.Begin("key1") {
+		t.Fatal("Begin() on a new key should succeed")
+	}
+	if ds.Begin("key1") {
+		t.Error("Begin() on an in flight key should fail")
+	}
+	ds.Abort("key1")
+	if !ds.Begin("key1") {
+		t.Fatal("Begin() on an aborted key should succeed")
+	}
+	if err := ds.Record("key1", actionRetried); err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	if ds.Begin("key1") {
+		t.Error("Begin() on a decided key should fail")
+	}
+
+	// decisions are reloaded after restart
+	reloaded, err := NewDecisionStore(path)
+	if err != nil {
+		t.Fatalf("NewDecisionStore() failed reloading: %v", err)
+	}
+	if d, ok := reloaded.Get("key1"); !ok || d.Action != actionRetried {
+		t.Errorf("reloaded decision = %+v(%v), want action %q", d, ok, actionRetried)
+	}
+	if reloaded.Begin("key1") {
+		t.Error("Begin() on a decided key should fail after reloading")
+	}
+	if !reloaded.Begin("key2") {
+		t.Error("Begin() on a new key should succeed after reloading")
+	}
+}
+
+func TestDecisionStoreDefer(t *testing.T) {
+	ds, err := NewDecisionStore("")
+	if err != nil {
+		t.Fatalf("NewDecisionStore() failed: %v", err)
+	}
+	now := time.Date(2020, 8, 3, 12, 0, 0, 0, time.UTC)
+	if !ds.Begin("key1") {
+		t.Fatal("Begin() on a new key should succeed")
+	}
+	ds.Defer("key1", now.Add(time.Minute))
+	if got := ds.Deferred("key1", now.Add(20*time.Second)); got != 40*time.Second {
+		t.Errorf("Deferred() = %v, want 40s", got)
+	}
+	if !ds.Begin("key1") {
+		t.Error("Begin() on a deferred key should succeed")
+	}
+	if got := ds.Deferred("key1", now.Add(time.Minute)); got != 0 {
+		t.Errorf("Deferred() = %v once due, want 0", got)
+	}
+	if got := ds.Deferred("key2", now); got != 0 {
+		t.Errorf("Deferred() = %v for a key never deferred, want 0", got)
+	}
+}
+
+func TestNewDecisionStoreCorrupted(t *testing.T) {
+	f, err := ioutil.TempFile("", "decisions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("not json")
+	f.Close()
+	if _, err := NewDecisionStore(f.Name()); err == nil {
+		t.Error("NewDecisionStore() should fail on a corrupted file")
+	}
+}
@@ -32,7 +32,7 @@ import (
 )
 
 const (
-	maxRetries            = 3
+	maxLinks              = 3
 	maxFailedTestsToPrint = 8
 )
 
@@ -63,7 +63,7 @@ type entry struct {
 	retries int
 }
 
-func (e *entry) toString() string {
+func (e *entry) toString(maxRetries int) string {
 	return fmt.Sprintf("%s | %s | %d/%d", e.name, e.links, e.retries, maxRetries)
 }
 
@@ -73,7 +73,7 @@ func (e *entry) addLink(newLink string) {
 	if e.links != "" {
 		oldLinks = strings.Split(e.links, "<br>")
 	}
-	if len(oldLinks) >= maxRetries { // only keep last 2 if more than 2
+	if len(oldLinks) >= maxLinks { // only keep last 2 if more than 2
 		e.links = strings.Join(oldLinks[len(oldLinks)-2:], "<br>")
 	}
 	e.links = strings.Join(append(oldLinks, newLink), "<br>")
@@ -114,13 +114,47 @@ func NewGithubClient(githubAccount string, dryrun bool) (*GithubClient, error) {
 	return &GithubClient{ghc, user.GetID(), dryrun}, nil
 }
 
-// PostComment posts a new comment on the PR specified in JobData, retrying the job that triggered it.
-// The comment body is dynamically built based on previous retry comments on this PR, and any old
-// comments are removed before the new one is posted.
-func (gc *GithubClient) PostComment(jd *JobData, outliers []string) error {
+// PostComment posts a new comment on the PR specified in JobData, and returns whether the job that
+// triggered it should be retried, which is the case unless there are outliers or it already expended
+// maxRetries. The comment body is dynamically built based on previous retry comments on this PR, and
+// any old comments are removed before the new one is posted.
+func (gc *GithubClient) PostComment(jd *JobData, outliers []string, maxRetries int, trigger Trigger) (bool, error) {
+	oldComment, oldEntries, err := gc.getOldEntries(jd)
+	if err != nil {
+		return false, err
+	}
+	newComment, retry := buildNewComment(jd, oldEntries, outliers, maxRetries, trigger)
+	if gc.Dryrun {
+		logWithPrefix(jd, "[dry run] Comment not updated. See it here:\n%s\n", newComment)
+		return retry, nil
+	}
+	if oldComment != nil {
+		if err := gc.DeleteComment(jd.Refs[0].Org, jd.Refs[0].Repo, oldComment.GetID()); err != nil {
+			return false, err
+		}
+	}
+	if _, err = gc.CreateComment(jd.Refs[0].Org, jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number, newComment); err != nil {
+		return false, err
+	}
+	return retry, nil
+}
+
+// GetRetries returns the number of retries already attempted for the job on the current commit
+func (gc *GithubClient) GetRetries(jd *JobData) (int, error) {
+	_, entries, err := gc.getOldEntries(jd)
+	if err != nil {
+		return 0, err
+	}
+	return entries[jd.JobName].retries, nil
+}
+
+// getOldEntries gets our comment on the PR specified in JobData, and the retry entries in it.
+// Entries are only read if the comment was made for the current commit, and always include the
+// job in JobData.
+func (gc *GithubClient) getOldEntries(jd *JobData) (*github.IssueComment, map[string]*entry, error) {
 	oldComment, err := gc.getOldComment(jd.Refs[0].Org, jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	oldEntries := make(map[string]*entry)
 	if oldComment != nil {
@@ -129,7 +163,7 @@ func (gc *GithubClient) PostComment(jd *JobData, outliers []string) error {
 			testNameFromComment[1] == jd.Refs[0].Pulls[0].SHA {
 			oldEntries, err = parseEntries(oldComment.GetBody())
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
 		}
 	}
@@ -137,18 +171,7 @@ func (gc *GithubClient) PostComment(jd *JobData, outliers []string) error {
 	if _, ok := oldEntries[jd.JobName]; !ok {
 		oldEntries[jd.JobName] = &entry{name: jd.JobName}
 	}
-	newComment := buildNewComment(jd, oldEntries, outliers)
-	if gc.Dryrun {
-		logWithPrefix(jd, "[dry run] Comment not updated. See it here:\n%s\n", newComment)
-		return nil
-	}
-	if oldComment != nil {
-		if err := gc.DeleteComment(jd.Refs[0].Org, jd.Refs[0].Repo, oldComment.GetID()); err != nil {
-			return err
-		}
-	}
-	_, err = gc.CreateComment(jd.Refs[0].Org, jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number, newComment)
-	return err
+	return oldComment, oldEntries, nil
 }
 
 // getOldComment queries the GitHub PR specified and gets the comment made by us. If no comment
@@ -195,21 +218,23 @@ func parseEntries(body string) (map[string]*entry, error) {
 }
 
 // buildNewComment takes the old entry data, the job we are processing, and any outlying
-// non-flaky tests, building a comment body based on these parameters.
-func buildNewComment(jd *JobData, entries map[string]*entry, outliers []string) string {
+// non-flaky tests, building a comment body based on these parameters. It also returns
+// whether the job should be retried.
+func buildNewComment(jd *JobData, entries map[string]*entry, outliers []string, maxRetries int, trigger Trigger) (string, bool) {
 	var cmd string
 	var entryString []string
-	var appendLog bool
+	var appendLog, retry bool
 	if entries[jd.JobName].retries >= maxRetries {
-		cmd = buildOutOfRetriesString(jd.JobName)
+		cmd = buildOutOfRetriesString(jd.JobName, maxRetries)
 		appendLog = true
 		logWithPrefix(jd, "expended all %d retries\n", maxRetries)
 	} else if len(outliers) > 0 {
 		cmd = buildNoRetryString(jd.JobName, outliers)
 		logWithPrefix(jd, "%d failed tests are not flaky, cannot retry\n", len(outliers))
 	} else {
-		cmd = buildRetryString(jd.JobName, entries)
+		cmd = buildRetryString(jd.JobName, entries, maxRetries, trigger) + buildFlakySourcesString(jd)
 		appendLog = true
+		retry = true
 		logWithPrefix(jd, "all failed tests are flaky, triggering retry\n")
 	}
 	// print in sorted order so we can actually unit test the results
@@ -222,20 +247,43 @@ func buildNewComment(jd *JobData, entries map[string]*entry, outliers []string)
 		if test == jd.JobName && appendLog {
 			entries[test].addLink(fmt.Sprintf("[%s](%s)", jd.Timestamp, jd.URL))
 		}
-		entryString = append(entryString, entries[test].toString())
+		entryString = append(entryString, entries[test].toString(maxRetries))
 	}
-	return fmt.Sprintf(commentTemplate, fmt.Sprintf(testIdentifierPattern, jd.Refs[0].Pulls[0].SHA), strings.Join(entryString, "\n"), cmd)
+	return fmt.Sprintf(commentTemplate, fmt.Sprintf(testIdentifierPattern, jd.Refs[0].Pulls[0].SHA), strings.Join(entryString, "\n"), cmd), retry
 }
 
-// buildRetryString increments the retry counter and generates a /test string if we have
-// more retries available.
-func buildRetryString(job string, entries map[string]*entry) string {
+// buildRetryString increments the retry counter and generates the trigger's retry message if
+// we have more retries available.
+func buildRetryString(job string, entries map[string]*entry, maxRetries int, trigger Trigger) string {
 	if entries[job].retries++; entries[job].retries <= maxRetries {
-		return fmt.Sprintf("Automatically retrying due to test flakiness...\n/test %s", job)
+		return trigger.RetryMessage(job)
 	}
 	return ""
 }
 
+// buildFlakySourcesString lists the failed tests of the job with the sources reporting them as
+// flaky. Tests not reported by any source were tolerated by the job's retry policy.
+func buildFlakySourcesString(jd *JobData) string {
+	if len(jd.failedTests) == 0 {
+		return ""
+	}
+	extraFailedTests := ""
+	lastIndex := len(jd.failedTests)
+	if len(jd.failedTests) > maxFailedTestsToPrint {
+		lastIndex = maxFailedTestsToPrint
+		extraFailedTests = fmt.Sprintf("\n\nand %d more.", len(jd.failedTests)-maxFailedTestsToPrint)
+	}
+	var rows []string
+	for _, test := range jd.failedTests[:lastIndex] {
+		sources := "retry policy"
+		if s, ok := jd.flakySources[test]; ok {
+			sources = strings.Join(s, ", ")
+		}
+		rows = append(rows, fmt.Sprintf("%s | %s", test, sources))
+	}
+	return fmt.Sprintf("\n\nFailed test | Reported flaky by\n--- | ---\n%s%s", strings.Join(rows, "\n"), extraFailedTests)
+}
+
 // buildNoRetryString formats the tests that prevent us from retrying into a truncated list.
 func buildNoRetryString(job string, outliers []string) string {
 	noRetryFmt := "Failed non-flaky tests preventing automatic retry of %s:\n\n```\n%s\n```%s"
@@ -251,6 +299,6 @@ func buildNoRetryString(job string, outliers []string) string {
 
 //buildOutOfRetriesString notifies the author that the job has been retriggered maxRetries times
 // while still failing.
-func buildOutOfRetriesString(job string) string {
+func buildOutOfRetriesString(job string, maxRetries int) string {
 	return fmt.Sprintf("Job %s expended all %d retries without success.", job, maxRetries)
 }
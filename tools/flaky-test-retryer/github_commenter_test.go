@@ -19,11 +19,13 @@ package main
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-github/v27/github"
 	"knative.dev/test-infra/pkg/ghutil/fakeghutil"
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
 	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
 )
 
@@ -144,6 +146,7 @@ Failed non-flaky tests preventing automatic retry of fakejob0:
 		time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC),
 		nil,
 		nil,
+		nil,
 	}
 
 	fakeFailedTests = []string{"test0", "test1", "test2", "test3", "test4", "test5", "test6", "test7", "test8", "test9"}
@@ -215,10 +218,11 @@ func TestParseEntries(t *testing.T) {
 
 func TestBuildNewComment(t *testing.T) {
 	cases := []struct {
-		jd       *JobData
-		entries  map[string]*entry
-		outliers []string
-		wantBody string
+		jd        *JobData
+		entries   map[string]*entry
+		outliers  []string
+		wantBody  string
+		wantRetry bool
 	}{
 		{
 			&fakeJob,
@@ -227,6 +231,7 @@ func TestBuildNewComment(t *testing.T) {
 				"fakejob1": {"fakejob1", "[2009-11-10 23:00:00 +0000 UTC]()", 1}},
 			nil,
 			retryCommentBody,
+			true,
 		}, {
 			&fakeJob,
 			map[string]*entry{
@@ -234,6 +239,7 @@ func TestBuildNewComment(t *testing.T) {
 				"fakejob1": {"fakejob1", "[2009-11-10 23:00:00 +0000 UTC]()", 1}},
 			nil,
 			noMoreRetriesCommentBody,
+			false,
 		}, {
 			&fakeJob,
 			map[string]*entry{
@@ -241,6 +247,7 @@ func TestBuildNewComment(t *testing.T) {
 				"fakejob1": {"fakejob1", "[2009-11-10 23:00:00 +0000 UTC]()", 1}},
 			fakeFailedTests[:4],
 			failedShortCommentBody,
+			false,
 		}, {
 			&fakeJob,
 			map[string]*entry{
@@ -248,14 +255,41 @@ func TestBuildNewComment(t *testing.T) {
 				"fakejob1": {"fakejob1", "[2009-11-10 23:00:00 +0000 UTC]()", 1}},
 			fakeFailedTests,
 			failedLongCommentBody,
+			false,
 		},
 	}
 
 	for _, test := range cases {
-		gotBody := buildNewComment(test.jd, test.entries, test.outliers)
+		gotBody, gotRetry := buildNewComment(test.jd, test.entries, test.outliers, config.DefaultMaxRetries, &commentTrigger{})
 		if gotBody != test.wantBody {
 			t.Fatalf("build new comment: got body \n'%v'\n, want \n'%v'", gotBody, test.wantBody)
 		}
+		if gotRetry != test.wantRetry {
+			t.Errorf("build new comment: got retry %v, want %v", gotRetry, test.wantRetry)
+		}
+	}
+}
+
+func TestBuildFlakySourcesString(t *testing.T) {
+	jd := &JobData{
+		failedTests: []string{"test0", "test1", "test2"},
+		flakySources: map[string][]string{
+			"test0": {fakeRepo},
+			"test1": {fakeRepo, "pkg-reporter/pkg"},
+		},
+	}
+	want := "\n\nFailed test | Reported flaky by\n--- | ---\n" +
+		"test0 | fakerepo\ntest1 | fakerepo, pkg-reporter/pkg\ntest2 | retry policy"
+	if got := buildFlakySourcesString(jd); got != want {
+		t.Errorf("buildFlakySourcesString() = %q, want %q", got, want)
+	}
+
+	jd.failedTests = fakeFailedTests
+	if got := buildFlakySourcesString(jd); !strings.HasSuffix(got, "test7 | retry policy\n\nand 2 more.") {
+		t.Errorf("buildFlakySourcesString() = %q, want a list truncated to %d tests", got, maxFailedTestsToPrint)
+	}
+	if got := buildFlakySourcesString(&JobData{}); got != "" {
+		t.Errorf("buildFlakySourcesString() without failed tests = %q, want empty", got)
 	}
 }
 
@@ -285,7 +319,7 @@ func TestAppendComment(t *testing.T) {
 		fgc.CreateComment(fakeOrg, fakeRepo, fakePullID, test.oldCommentBody)
 		fj := fakeJob
 		fj.Refs[0].Pulls[0].SHA = test.commitSHA
-		fgc.PostComment(&fj, test.outliers)
+		fgc.PostComment(&fj, test.outliers, config.DefaultMaxRetries, &commentTrigger{})
 		actualComment, actualErr := fgc.getOldComment(fakeOrg, fakeRepo, fakePullID)
 		if actualErr != nil {
 			t.Fatalf("testing appending existing comment, with:\nold comment:\n%s\nfailed tests:'%v'\nwant: no error\ngot: %v",
@@ -297,3 +331,34 @@ func TestAppendComment(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRetries(t *testing.T) {
+	cases := []struct {
+		oldCommentBody string
+		commitSHA      string
+		want           int
+	}{
+		{"", fakeSHA, 0},
+		{retryCommentBody, fakeSHA, 1},
+		{noMoreRetriesCommentBody, fakeSHA, 3},
+		// counts are reset when commit hash changes
+		{noMoreRetriesCommentBody, fakeSHA + fakeSHA, 0},
+	}
+
+	for _, test := range cases {
+		fgc := getFakeGithubClient()
+		if test.oldCommentBody != "" {
+			fgc.CreateComment(fakeOrg, fakeRepo, fakePullID, test.oldCommentBody)
+		}
+		fj := fakeJob
+		fj.Refs = []prowapi.Refs{fakeJob.Refs[0]}
+		fj.Refs[0].Pulls = []prowapi.Pull{{Number: fakePullID, SHA: test.commitSHA}}
+		got, err := fgc.GetRetries(&fj)
+		if err != nil {
+			t.Fatalf("get retries: got error %v", err)
+		}
+		if got != test.want {
+			t.Errorf("get retries with SHA %q: got %d, want %d", test.commitSHA, got, test.want)
+		}
+	}
+}
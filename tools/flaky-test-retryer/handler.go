@@ -22,12 +22,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"knative.dev/test-infra/pkg/ghutil"
 
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
 	"knative.dev/test-infra/tools/flaky-test-retryer/subscriber"
 	// TODO: remove this import once "k8s.io/test-infra" import problems are fixed
 	// https://github.com/test-infra/test-infra/issues/912
@@ -36,17 +38,62 @@ import (
 
 const pubsubTopic = "flaky-test-retryer"
 
+// Actions recorded for job runs that were decided
+const (
+	actionSkipped      = "skipped"
+	actionBlocked      = "blocked"
+	actionOutOfRetries = "out of retries"
+	actionRetried      = "retried"
+	actionRerunFailed  = "rerun failed"
+)
+
+// deferredRetryDelay is how long a deferred job run waits before being handled
+// again when handling it failed, at most maxDeferredAttempts times. Messages of
+// deferred job runs were already acked, so they won't be redelivered.
+const (
+	deferredRetryDelay  = time.Minute
+	maxDeferredAttempts = 5
+)
+
+// backoffError is returned for job runs that have to wait before being retried.
+// Workers don't wait for the backoff, the job run is deferred and handled again
+// in the background once the delay has passed, so its message must not be
+// redelivered.
+type backoffError struct {
+	delay time.Duration
+}
+
+func (e *backoffError) Error() string {
+	return fmt.Sprintf("backing off for %v before retrying", e.delay)
+}
+
 // HandlerClient wraps the other clients we need when processing failed jobs.
 type HandlerClient struct {
 	context.Context
-	pubsub *subscriber.Client
-	github *GithubClient
+	pubsub    *subscriber.Client
+	github    *GithubClient
+	decisions *DecisionStore
+	policies  *config.Config
+	trigger   Trigger
+	audit     *Auditor
+	workers   chan struct{} // semaphore bounding the number of jobs handled concurrently
+	// requeue calls f in the background after delay, for handling deferred job runs again
+	requeue func(delay time.Duration, f func())
+	// handleJob decides and acts on a job run, HandleJob unless faked in tests
+	handleJob func(jd *JobData) (*jobOutcome, error)
 }
 
 // NewHandlerClient gives us a handler where we can listen for Pubsub messages and
-// post comments on GitHub.
-func NewHandlerClient(serviceAccount, githubAccount string, dryrun bool) (*HandlerClient, error) {
+// post comments on GitHub. Pubsub client is not created if usePubsub is false,
+// in which case messages can only be received through HTTP. Decisions are
+// persisted in stateFile, retry policies are read from policyFile, failed jobs
+// are rerun with trigger, decisions are recorded with audit, and at most
+// maxWorkers jobs are handled concurrently.
+func NewHandlerClient(serviceAccount, githubAccount, stateFile, policyFile string, trigger Trigger, audit *Auditor, maxWorkers int, usePubsub, dryrun bool) (*HandlerClient, error) {
 	ctx := context.Background()
+	if maxWorkers < 1 {
+		return nil, fmt.Errorf("max workers must be positive, got %d", maxWorkers)
+	}
 	if err := InitLogParser(serviceAccount); err != nil {
 		log.Fatalf("Failed authenticating GCS: '%v'", err)
 	}
@@ -54,77 +101,290 @@ func NewHandlerClient(serviceAccount, githubAccount string, dryrun bool) (*Handl
 	if err != nil {
 		return nil, fmt.Errorf("Github client: %v", err)
 	}
-	pubsubClient, err := subscriber.NewSubscriberClient(pubsubTopic)
+	var pubsubClient *subscriber.Client
+	if usePubsub {
+		if pubsubClient, err = subscriber.NewSubscriberClient(pubsubTopic); err != nil {
+			return nil, fmt.Errorf("Pubsub client: %v", err)
+		}
+	}
+	decisions, err := NewDecisionStore(stateFile)
+	if err != nil {
+		return nil, fmt.Errorf("decision store: %v", err)
+	}
+	policies, err := config.Load(policyFile)
 	if err != nil {
-		return nil, fmt.Errorf("Pubsub client: %v", err)
+		return nil, fmt.Errorf("retry policies: %v", err)
 	}
-	return &HandlerClient{
+	hc := &HandlerClient{
 		ctx,
 		pubsubClient,
 		githubClient,
-	}, nil
+		decisions,
+		policies,
+		trigger,
+		audit,
+		make(chan struct{}, maxWorkers),
+		func(delay time.Duration, f func()) { time.AfterFunc(delay, f) },
+		nil,
+	}
+	hc.handleJob = hc.HandleJob
+	return hc, nil
 }
 
-// Listen scans for incoming Pubsub messages, spawning a new goroutine for each
-// one that fits our criteria.
+// Listen scans for incoming Pubsub messages, handling the ones that fit our
+// criteria. Messages are only acked after they were handled successfully, or
+// after their job run was deferred.
 func (hc *HandlerClient) Listen() {
 	log.Printf("Listening for failed jobs...\n")
 	for {
-		log.Println("Starting ReceiveMessageAckAll")
-		hc.pubsub.ReceiveMessageAckAll(context.Background(), func(msg *prowapi.ReportMessage, timestamp time.Time) {
+		log.Println("Starting ReceiveMessage")
+		hc.pubsub.ReceiveMessage(context.Background(), func(msg *prowapi.ReportMessage, timestamp time.Time) error {
 			log.Printf("Message received for %q", msg.URL)
-			data := &JobData{msg, timestamp, nil, nil}
-			if data.IsSupported() {
-				go hc.HandleJob(data)
+			err := hc.HandleMessage(msg, timestamp)
+			var backoff *backoffError
+			if errors.As(err, &backoff) {
+				// the job run is handled again in the background, redelivering
+				// the message right away would only loop until it's due
+				return nil
 			}
+			return err
 		})
-		log.Println("Done with previous ReceiveMessageAckAll call")
+		log.Println("Done with previous ReceiveMessage call")
+	}
+}
+
+// HandleMessage handles the message if it fits our criteria, messages from both
+// Pubsub and HTTP go through here. It blocks until a worker is available, and
+// returns an error if the message should be redelivered, a backoffError if the
+// job run was deferred and will be handled again in the background.
+func (hc *HandlerClient) HandleMessage(msg *prowapi.ReportMessage, timestamp time.Time) error {
+	data := &JobData{msg, timestamp, nil, nil, nil}
+	key := decisionKey(data)
+	// Duplicates of deferred job runs are ignored before counting them or
+	// reading anything from GCS
+	if delay := hc.decisions.Deferred(key, time.Now()); delay > 0 {
+		return &backoffError{delay}
+	}
+	hc.audit.MessageReceived(msg, timestamp)
+	if !data.IsSupported() {
+		return nil
+	}
+	hc.audit.MessageSupported()
+	return hc.handleJobRun(data, key, 0)
+}
+
+// handleJobRun handles the job run unless it was already decided or is being
+// processed. attempt is 0 for job runs of received messages, and counts how
+// often a deferred job run was handled otherwise. Job runs backing off are
+// deferred, and handled again once due.
+func (hc *HandlerClient) handleJobRun(data *JobData, key string, attempt int) error {
+	if !hc.decisions.Begin(key) {
+		if d, ok := hc.decisions.Get(key); ok {
+			logWithPrefix(data, "already %s at %v, ignoring duplicate message\n", d.Action, d.Timestamp)
+		} else {
+			logWithPrefix(data, "already being processed, ignoring duplicate message\n")
+		}
+		return nil
 	}
+
+	hc.workers <- struct{}{}
+	defer func() { <-hc.workers }()
+	outcome, err := hc.handleJob(data)
+	var backoff *backoffError
+	if errors.As(err, &backoff) {
+		logWithPrefix(data, "backing off for %v before retrying\n", backoff.delay)
+		hc.deferJobRun(data, key, backoff.delay, 0)
+		return err
+	}
+	if err != nil {
+		// the message of a deferred job run was acked and won't be redelivered
+		if attempt > 0 && attempt < maxDeferredAttempts {
+			logWithPrefix(data, "failed handling deferred job run, trying again in %v: %v\n", deferredRetryDelay, err)
+			hc.deferJobRun(data, key, deferredRetryDelay, attempt)
+			return err
+		}
+		if attempt > 0 {
+			logWithPrefix(data, "failed handling deferred job run %d times, giving up: %v\n", attempt, err)
+		}
+		hc.decisions.Abort(key)
+		return err
+	}
+	hc.audit.Record(data, outcome)
+	if err := hc.decisions.Record(key, outcome.action); err != nil {
+		// The action was already taken, redelivering wouldn't help
+		logWithPrefix(data, "could not persist decision: %v", err)
+	}
+	return nil
+}
+
+// deferJobRun defers the job run, handling it again in the background after delay
+func (hc *HandlerClient) deferJobRun(data *JobData, key string, delay time.Duration, attempt int) {
+	hc.decisions.Defer(key, time.Now().Add(delay))
+	hc.requeue(delay, func() {
+		hc.handleJobRun(data, key, attempt+1)
+	})
+}
+
+// jobOutcome is the decision made for a job run
+type jobOutcome struct {
+	action        string
+	reason        string
+	blockingTests []string // failed tests preventing a retry
 }
 
 // HandleJob gets the job's failed tests and the current flaky tests,
-// compares them, and triggers a retest if all the failed tests are flaky.
-func (hc *HandlerClient) HandleJob(jd *JobData) {
+// compares them, and triggers a retest if the job's retry policy allows it.
+// It returns the decision made, or an error if the job should be handled again.
+func (hc *HandlerClient) HandleJob(jd *JobData) (*jobOutcome, error) {
 	logWithPrefix(jd, "fit all criteria - Starting analysis\n")
+	if jd.JobType != prowapi.PresubmitJob {
+		return hc.handleRerunJob(jd)
+	}
 
 	pull, err := hc.github.GetPullRequest(jd.Refs[0].Org, jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number)
 	if err != nil {
-		logWithPrefix(jd, "could not get Pull Request: %v", err)
-		return
+		return nil, fmt.Errorf("could not get Pull Request: %v", err)
 	}
 
 	if *pull.State != string(ghutil.PullRequestOpenState) {
 		logWithPrefix(jd, "Pull Request is not open: %q", *pull.State)
-		return
+		return &jobOutcome{action: actionSkipped, reason: "pull request is not open"}, nil
+	}
+
+	policy := hc.policies.PolicyFor(jd.Refs[0].Org, jd.Refs[0].Repo, jd.JobName)
+	if label := optOutLabel(policy, pull); label != "" {
+		logWithPrefix(jd, "Pull Request opted out of retries with label %q", label)
+		return &jobOutcome{action: actionSkipped, reason: fmt.Sprintf("opted out with label %q", label)}, nil
 	}
 
 	failedTests, err := jd.getFailedTests()
 	if err != nil {
-		logWithPrefix(jd, "could not get failed tests: %v", err)
-		return
+		return nil, fmt.Errorf("could not get failed tests: %v", err)
 	}
 	if len(failedTests) == 0 {
 		logWithPrefix(jd, "no failed tests, skipping\n")
-		return
+		return &jobOutcome{action: actionSkipped, reason: "no failed tests"}, nil
 	}
 	logWithPrefix(jd, "got %d failed tests", len(failedTests))
 
-	flakyTests, err := jd.getFlakyTests()
+	flakyTests, err := jd.getFlakyTests(policy.FlakySources)
 	if err != nil {
-		logWithPrefix(jd, "could not get flaky tests: %v", err)
-		return
+		return nil, fmt.Errorf("could not get flaky tests: %v", err)
 	}
-	logWithPrefix(jd, "got %d flaky tests from today's report\n", len(flakyTests))
+	logWithPrefix(jd, "got %d flaky tests from today's reports\n", len(flakyTests))
 
-	outliers := getNonFlakyTests(failedTests, flakyTests)
-	if err := hc.github.PostComment(jd, outliers); err != nil {
-		logWithPrefix(jd, "Could not post comment: %v", err)
+	outliers := getBlockingTests(policy, failedTests, flakyTests)
+	if len(outliers) == 0 {
+		retries, err := hc.github.GetRetries(jd)
+		if err != nil {
+			return nil, fmt.Errorf("could not get retries: %v", err)
+		}
+		if delay := retryDelay(policy, retries, jd.Timestamp, time.Now()); retries < policy.MaxRetries && delay > 0 {
+			return nil, &backoffError{delay}
+		}
+	}
+	retry, err := hc.github.PostComment(jd, outliers, policy.MaxRetries, hc.trigger)
+	if err != nil {
+		return nil, fmt.Errorf("could not post comment: %v", err)
+	}
+	if len(outliers) > 0 {
+		return &jobOutcome{action: actionBlocked, reason: "failed tests are not flaky", blockingTests: outliers}, nil
+	}
+	if !retry {
+		return &jobOutcome{action: actionOutOfRetries, reason: fmt.Sprintf("expended all %d retries", policy.MaxRetries)}, nil
 	}
+	// The retry was already counted in the comment, rerunning again on redelivery
+	// would exceed the retry budget, so failures are not redelivered
+	if err := hc.trigger.Rerun(jd); err != nil {
+		logWithPrefix(jd, "could not rerun job: %v", err)
+		return &jobOutcome{action: actionRerunFailed, reason: err.Error()}, nil
+	}
+	if err := jd.addRerunMetadata("rerun", hc.github.Dryrun); err != nil {
+		logWithPrefix(jd, "could not add rerun metadata: %v\n", err)
+	}
+	return &jobOutcome{action: actionRetried}, nil
+}
+
+// handleRerunJob handles a failed postsubmit or periodic job, which has no pull
+// request for commenting on. The job is rerun once if its retry policy allows it,
+// and the rerun is annotated with the failed run so that it is not rerun again.
+func (hc *HandlerClient) handleRerunJob(jd *JobData) (*jobOutcome, error) {
+	if _, ok := hc.trigger.(*commentTrigger); ok {
+		logWithPrefix(jd, "comment trigger cannot rerun %s jobs, skipping\n", jd.JobType)
+		return &jobOutcome{action: actionSkipped, reason: fmt.Sprintf("comment trigger cannot rerun %s jobs", jd.JobType)}, nil
+	}
+	policy := hc.policies.PolicyFor(jd.Refs[0].Org, jd.Refs[0].Repo, jd.JobName)
+	if policy.MaxRetries == 0 {
+		logWithPrefix(jd, "retries are disabled, skipping\n")
+		return &jobOutcome{action: actionSkipped, reason: "retries are disabled"}, nil
+	}
+
+	failedTests, err := jd.getFailedTests()
+	if err != nil {
+		return nil, fmt.Errorf("could not get failed tests: %v", err)
+	}
+	if len(failedTests) == 0 {
+		logWithPrefix(jd, "no failed tests, skipping\n")
+		return &jobOutcome{action: actionSkipped, reason: "no failed tests"}, nil
+	}
+	flakyTests, err := jd.getFlakyTests(policy.FlakySources)
+	if err != nil {
+		return nil, fmt.Errorf("could not get flaky tests: %v", err)
+	}
+	if outliers := getBlockingTests(policy, failedTests, flakyTests); len(outliers) > 0 {
+		logWithPrefix(jd, "%d failed tests are not flaky, cannot rerun\n", len(outliers))
+		return &jobOutcome{action: actionBlocked, reason: "failed tests are not flaky", blockingTests: outliers}, nil
+	}
+
+	rerun, failedRun, err := jd.isRerun()
+	if err != nil {
+		return nil, fmt.Errorf("could not check whether run is a rerun: %v", err)
+	}
+	if rerun {
+		logWithPrefix(jd, "run is the rerun of %s, not rerunning again\n", failedRun)
+		if err := jd.addRerunMetadata("rerun of "+failedRun, hc.github.Dryrun); err != nil {
+			logWithPrefix(jd, "could not add rerun metadata: %v\n", err)
+		}
+		return &jobOutcome{action: actionOutOfRetries, reason: "run is already a rerun"}, nil
+	}
+	marked, err := jd.isMarkedRerun()
+	if err != nil {
+		return nil, fmt.Errorf("could not check rerun marker: %v", err)
+	}
+	if marked {
+		logWithPrefix(jd, "run was already rerun, skipping\n")
+		return &jobOutcome{action: actionSkipped, reason: "run was already rerun"}, nil
+	}
+	if delay := retryDelay(policy, 0, jd.Timestamp, time.Now()); delay > 0 {
+		return nil, &backoffError{delay}
+	}
+	// Same as the retry comment for presubmit jobs, the marker is written before
+	// rerunning, so that a redelivered message never reruns the job twice
+	if err := jd.markRerun(failedTests, hc.github.Dryrun); err != nil {
+		return nil, fmt.Errorf("could not mark run as rerun: %v", err)
+	}
+	if err := hc.trigger.Rerun(jd); err != nil {
+		logWithPrefix(jd, "could not rerun job: %v", err)
+		return &jobOutcome{action: actionRerunFailed, reason: err.Error()}, nil
+	}
+	if err := jd.addRerunMetadata("rerun", hc.github.Dryrun); err != nil {
+		logWithPrefix(jd, "could not add rerun metadata: %v\n", err)
+	}
+	return &jobOutcome{action: actionRetried}, nil
 }
 
 // logWithPrefix wraps a call to log.Printf, prefixing the arguments with details
 // about the job passed in.
 func logWithPrefix(jd *JobData, format string, a ...interface{}) {
-	input := append([]interface{}{jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number, jd.JobName, jd.RunID}, a...)
+	var repo string
+	if len(jd.Refs) > 0 {
+		repo = jd.Refs[0].Repo
+	}
+	if jd.JobType != prowapi.PresubmitJob {
+		input := append([]interface{}{repo, jd.JobName, jd.RunID}, a...)
+		log.Printf("%s: %s/%s: "+format, input...)
+		return
+	}
+	input := append([]interface{}{repo, jd.pullNumber(), jd.JobName, jd.RunID}, a...)
 	log.Printf("%s/pull/%d: %s/%s: "+format, input...)
 }
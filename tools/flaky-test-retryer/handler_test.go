@@ -0,0 +1,340 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+// fakeTrigger records the job runs it reruns
+type fakeTrigger struct {
+	reruns []*JobData
+}
+
+func (ft *fakeTrigger) Rerun(jd *JobData) error {
+	ft.reruns = append(ft.reruns, jd)
+	return nil
+}
+
+func (ft *fakeTrigger) RetryMessage(job string) string {
+	return "rerun " + job
+}
+
+// fakeJobHandler returns the queued results of HandleJob in order, and the
+// last one once the queue is exhausted
+type fakeJobHandler struct {
+	mutex   sync.Mutex
+	calls   int
+	results []jobResult
+}
+
+type jobResult struct {
+	outcome *jobOutcome
+	err     error
+}
+
+func (f *fakeJobHandler) handle(jd *JobData) (*jobOutcome, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	r := f.results[len(f.results)-1]
+	if f.calls < len(f.results) {
+		r = f.results[f.calls]
+	}
+	f.calls++
+	return r.outcome, r.err
+}
+
+func (f *fakeJobHandler) callCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls
+}
+
+// requeued records the job runs requeued by the handler, so that tests
+// run them when they choose to instead of after the delay
+type requeued struct {
+	delays []time.Duration
+	funcs  []func()
+}
+
+func (r *requeued) requeue(delay time.Duration, f func()) {
+	r.delays = append(r.delays, delay)
+	r.funcs = append(r.funcs, f)
+}
+
+// runNext runs the oldest requeued job run
+func (r *requeued) runNext(t *testing.T) {
+	if len(r.funcs) == 0 {
+		t.Fatal("no job run was requeued")
+	}
+	f := r.funcs[0]
+	r.funcs = r.funcs[1:]
+	f()
+}
+
+func newTestHandlerClient(t *testing.T, handleJob func(*JobData) (*jobOutcome, error), maxWorkers int) (*HandlerClient, *requeued) {
+	setup()
+	decisions, err := NewDecisionStore("")
+	if err != nil {
+		t.Fatalf("NewDecisionStore() failed: %v", err)
+	}
+	r := &requeued{}
+	hc := &HandlerClient{
+		decisions: decisions,
+		policies:  &config.Config{},
+		trigger:   &fakeTrigger{},
+		audit:     NewAuditor(nil),
+		workers:   make(chan struct{}, maxWorkers),
+		requeue:   r.requeue,
+		handleJob: handleJob,
+	}
+	return hc, r
+}
+
+func reportMessage(runID string) *prowapi.ReportMessage {
+	msg := *fakeValidMessage
+	msg.RunID = runID
+	return &msg
+}
+
+func TestHandleMessageRecordsDecision(t *testing.T) {
+	jobs := &fakeJobHandler{results: []jobResult{{&jobOutcome{action: actionRetried}, nil}}}
+	hc, _ := newTestHandlerClient(t, jobs.handle, 1)
+
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); err != nil {
+		t.Fatalf("HandleMessage() failed: %v", err)
+	}
+	d, ok := hc.decisions.Get(decisionKey(&JobData{ReportMessage: reportMessage("1")}))
+	if !ok || d.Action != actionRetried {
+		t.Errorf("got decision %+v, %v, want %q", d, ok, actionRetried)
+	}
+	if got := hc.audit.decisions.get("fakeorg", fakeRepo, "fakejob", actionRetried); got != 1 {
+		t.Errorf("got %v audited decisions, want 1", got)
+	}
+
+	// duplicates of decided job runs are ignored, other runs are handled
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); err != nil {
+		t.Fatalf("HandleMessage() of duplicate failed: %v", err)
+	}
+	if got := jobs.callCount(); got != 1 {
+		t.Errorf("duplicate message handled, got %d calls, want 1", got)
+	}
+	if err := hc.HandleMessage(reportMessage("2"), time.Now()); err != nil {
+		t.Fatalf("HandleMessage() of other run failed: %v", err)
+	}
+	if got := jobs.callCount(); got != 2 {
+		t.Errorf("other run not handled, got %d calls, want 2", got)
+	}
+}
+
+func TestHandleMessageUnsupported(t *testing.T) {
+	jobs := &fakeJobHandler{results: []jobResult{{&jobOutcome{action: actionRetried}, nil}}}
+	hc, _ := newTestHandlerClient(t, jobs.handle, 1)
+
+	msg := reportMessage("1")
+	msg.Status = prowapi.SuccessState
+	if err := hc.HandleMessage(msg, time.Now()); err != nil {
+		t.Fatalf("HandleMessage() failed: %v", err)
+	}
+	if got := jobs.callCount(); got != 0 {
+		t.Errorf("unsupported message handled, got %d calls", got)
+	}
+	if got := hc.audit.received.get(); got != 1 {
+		t.Errorf("got %v received messages, want 1", got)
+	}
+	if got := hc.audit.supported.get(); got != 0 {
+		t.Errorf("got %v supported messages, want 0", got)
+	}
+}
+
+func TestHandleMessageAbortsOnError(t *testing.T) {
+	jobs := &fakeJobHandler{results: []jobResult{
+		{nil, errors.New("GCS is down")},
+		{&jobOutcome{action: actionBlocked}, nil},
+	}}
+	hc, requeued := newTestHandlerClient(t, jobs.handle, 1)
+
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); err == nil {
+		t.Fatal("HandleMessage() should fail so that the message is redelivered")
+	}
+	if len(requeued.funcs) != 0 {
+		t.Error("failed job run of a received message should be redelivered, not requeued")
+	}
+	// the redelivered message is handled again
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); err != nil {
+		t.Fatalf("HandleMessage() of redelivered message failed: %v", err)
+	}
+	if got := jobs.callCount(); got != 2 {
+		t.Errorf("got %d calls, want 2", got)
+	}
+	if d, ok := hc.decisions.Get(decisionKey(&JobData{ReportMessage: reportMessage("1")})); !ok || d.Action != actionBlocked {
+		t.Errorf("got decision %+v, %v, want %q", d, ok, actionBlocked)
+	}
+}
+
+func TestHandleMessageInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	hc, _ := newTestHandlerClient(t, func(jd *JobData) (*jobOutcome, error) {
+		started <- struct{}{}
+		<-release
+		return &jobOutcome{action: actionRetried}, nil
+	}, 2)
+
+	done := make(chan error)
+	go func() { done <- hc.HandleMessage(reportMessage("1"), time.Now()) }()
+	<-started
+
+	// a duplicate of the job run being processed returns right away
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); err != nil {
+		t.Errorf("HandleMessage() of in-flight duplicate failed: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("HandleMessage() failed: %v", err)
+	}
+}
+
+func TestHandleMessageWorkers(t *testing.T) {
+	started, release := make(chan string, 2), make(chan struct{})
+	hc, _ := newTestHandlerClient(t, func(jd *JobData) (*jobOutcome, error) {
+		started <- jd.RunID
+		<-release
+		return &jobOutcome{action: actionRetried}, nil
+	}, 1)
+
+	done := make(chan error, 2)
+	go func() { done <- hc.HandleMessage(reportMessage("1"), time.Now()) }()
+	first := <-started
+	go func() { done <- hc.HandleMessage(reportMessage("2"), time.Now()) }()
+
+	// the second job run waits for the only worker
+	select {
+	case id := <-started:
+		t.Fatalf("run %s handled while run %s holds the only worker", id, first)
+	case <-time.After(50 * time.Millisecond):
+	}
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("HandleMessage() failed: %v", err)
+	}
+	<-started
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatalf("HandleMessage() failed: %v", err)
+	}
+}
+
+func TestHandleMessageDefers(t *testing.T) {
+	jobs := &fakeJobHandler{results: []jobResult{
+		{nil, &backoffError{time.Hour}},
+		{&jobOutcome{action: actionRetried}, nil},
+	}}
+	hc, requeued := newTestHandlerClient(t, jobs.handle, 1)
+
+	var backoff *backoffError
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); !errors.As(err, &backoff) {
+		t.Fatalf("HandleMessage() error = %v, want backoffError", err)
+	}
+	if len(requeued.delays) != 1 || requeued.delays[0] != time.Hour {
+		t.Fatalf("got requeue delays %v, want [1h]", requeued.delays)
+	}
+
+	// duplicates of the deferred job run are not counted nor handled
+	if err := hc.HandleMessage(reportMessage("1"), time.Now()); !errors.As(err, &backoff) {
+		t.Errorf("HandleMessage() of deferred duplicate error = %v, want backoffError", err)
+	}
+	if got := hc.audit.received.get(); got != 1 {
+		t.Errorf("got %v received messages, want 1", got)
+	}
+
+	requeued.runNext(t)
+	if got := jobs.callCount(); got != 2 {
+		t.Errorf("got %d calls, want 2", got)
+	}
+	if d, ok := hc.decisions.Get(decisionKey(&JobData{ReportMessage: reportMessage("1")})); !ok || d.Action != actionRetried {
+		t.Errorf("got decision %+v, %v, want %q", d, ok, actionRetried)
+	}
+}
+
+func TestHandleMessageDeferredFailures(t *testing.T) {
+	jobs := &fakeJobHandler{results: []jobResult{
+		{nil, &backoffError{time.Hour}},
+		{nil, errors.New("GCS is down")},
+	}}
+	hc, requeued := newTestHandlerClient(t, jobs.handle, 1)
+
+	hc.HandleMessage(reportMessage("1"), time.Now())
+	// the message was acked, so failures of the deferred job run are retried in
+	// the background until giving up
+	for i := 1; i < maxDeferredAttempts; i++ {
+		requeued.runNext(t)
+		if len(requeued.funcs) != 1 || requeued.delays[len(requeued.delays)-1] != deferredRetryDelay {
+			t.Fatalf("attempt %d: failed deferred job run not requeued after %v", i, deferredRetryDelay)
+		}
+	}
+	requeued.runNext(t)
+	if len(requeued.funcs) != 0 {
+		t.Fatalf("deferred job run requeued after %d attempts", maxDeferredAttempts)
+	}
+	if got := jobs.callCount(); got != maxDeferredAttempts+1 {
+		t.Errorf("got %d calls, want %d", got, maxDeferredAttempts+1)
+	}
+	// given up job runs are handled again on a new message
+	key := decisionKey(&JobData{ReportMessage: reportMessage("1")})
+	hc.decisions.Deferred(key, time.Now().Add(2*time.Hour))
+	if !hc.decisions.Begin(key) {
+		t.Error("given up job run should be aborted")
+	}
+}
+
+func TestHandleJobRerunJobs(t *testing.T) {
+	cases := []struct {
+		name       string
+		trigger    Trigger
+		maxRetries int
+		want       string
+	}{
+		{"comment trigger", &commentTrigger{}, 1, "comment trigger cannot rerun"},
+		{"retries disabled", &fakeTrigger{}, 0, "retries are disabled"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			hc, _ := newTestHandlerClient(t, nil, 1)
+			hc.trigger = tt.trigger
+			hc.policies = &config.Config{Default: config.Settings{MaxRetries: &tt.maxRetries}}
+			msg := reportMessage("1")
+			msg.JobType = prowapi.PostsubmitJob
+			outcome, err := hc.HandleJob(&JobData{ReportMessage: msg})
+			if err != nil {
+				t.Fatalf("HandleJob() failed: %v", err)
+			}
+			if outcome.action != actionSkipped || !strings.Contains(outcome.reason, tt.want) {
+				t.Errorf("got outcome %+v, want skipped with reason %q", outcome, tt.want)
+			}
+			if ft, ok := tt.trigger.(*fakeTrigger); ok && len(ft.reruns) != 0 {
+				t.Errorf("got %d reruns, want none", len(ft.reruns))
+			}
+		})
+	}
+}
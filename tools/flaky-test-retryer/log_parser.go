@@ -20,15 +20,18 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"knative.dev/test-infra/pkg/junit"
 	"knative.dev/test-infra/pkg/prow"
 	"knative.dev/test-infra/tools/flaky-test-reporter/jsonreport"
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
 
 	// TODO: remove this import once "k8s.io/test-infra" import problems are fixed
 	// https://github.com/knative/test-infra/test-infra/issues/912
@@ -51,6 +54,8 @@ type JobData struct {
 	Timestamp    time.Time
 	failedTests  []string
 	flakyReports []jsonreport.Report
+	// flakySources maps flaky tests to the sources reporting them as flaky
+	flakySources map[string][]string
 }
 
 // IsSupported checks to make sure the message can be processed with the current flaky
@@ -62,10 +67,19 @@ func (jd *JobData) IsSupported() bool {
 		return false
 	}
 	// check type
-	if jd.JobType != prowapi.PresubmitJob {
-		log.Printf("%s: message did not originate from presubmit: %v\n", prefix, jd.JobType)
+	switch jd.JobType {
+	case prowapi.PresubmitJob, prowapi.PostsubmitJob, prowapi.PeriodicJob:
+	default:
+		log.Printf("%s: message did not originate from presubmit, postsubmit or periodic: %v\n", prefix, jd.JobType)
 		return false
 	}
+	// periodic jobs only reference repositories in the extra refs of their ProwJob
+	if len(jd.Refs) == 0 && jd.JobType == prowapi.PeriodicJob {
+		if err := jd.loadExtraRefs(); err != nil {
+			log.Printf("%s: error getting extra refs of periodic job: %v\n", prefix, err)
+			return false
+		}
+	}
 	// check repo
 	if len(jd.Refs) == 0 {
 		log.Printf("%s: message does not contain any repository references\n", prefix)
@@ -88,25 +102,66 @@ func (jd *JobData) IsSupported() bool {
 		return false
 	}
 	// make sure pull ID exists
-	if len(jd.Refs[0].Pulls) == 0 {
+	if jd.JobType == prowapi.PresubmitJob && len(jd.Refs[0].Pulls) == 0 {
 		log.Printf("%s: message does not contain any pull requests\n", prefix)
 		return false
 	}
 	return true
 }
 
+// pullNumber returns the pull request number of a presubmit job, or 0 for other jobs
+func (jd *JobData) pullNumber() int {
+	if jd.JobType != prowapi.PresubmitJob || len(jd.Refs) == 0 || len(jd.Refs[0].Pulls) == 0 {
+		return 0
+	}
+	return jd.Refs[0].Pulls[0].Number
+}
+
+// prowJob returns the job in GCS
+func (jd *JobData) prowJob() *prow.Job {
+	var org, repo string
+	if len(jd.Refs) > 0 {
+		org, repo = jd.Refs[0].Org, jd.Refs[0].Repo
+	}
+	return prow.NewJob(jd.JobName, string(jd.JobType), org, repo, jd.pullNumber())
+}
+
+// loadExtraRefs sets the refs of the job to the extra refs of its ProwJob
+func (jd *JobData) loadExtraRefs() error {
+	contents, err := readProwJob(jd)
+	if err != nil {
+		return err
+	}
+	pj := struct {
+		Spec struct {
+			ExtraRefs []prowapi.Refs `json:"extra_refs"`
+		} `json:"spec"`
+	}{}
+	if err := json.Unmarshal(contents, &pj); err != nil {
+		return fmt.Errorf("failed parsing %s: %v", prowJobFile, err)
+	}
+	jd.Refs = pj.Spec.ExtraRefs
+	return nil
+}
+
 // getFailedTests gets all the tests that failed in the given job.
 func (jd *JobData) getFailedTests() ([]string, error) {
 	// use cache if it is populated
 	if len(jd.failedTests) > 0 {
 		return jd.failedTests, nil
 	}
-	job := prow.NewJob(jd.JobName, string(jd.JobType), jd.Refs[0].Org, jd.Refs[0].Repo, jd.Refs[0].Pulls[0].Number)
-	// Check latest build instead of using jd.RunID, as there are times where
-	// devs initiated retry manually before retryer gets to it, and in this case
-	// scaning latest build can help retryer avoid initiating another retry
-	// since latest build has no test failure yet
-	buildID, err := job.GetLatestBuildNumber()
+	job := jd.prowJob()
+	var buildID int
+	var err error
+	if jd.JobType == prowapi.PresubmitJob {
+		// Check latest build instead of using jd.RunID, as there are times where
+		// devs initiated retry manually before retryer gets to it, and in this case
+		// scaning latest build can help retryer avoid initiating another retry
+		// since latest build has no test failure yet
+		buildID, err = job.GetLatestBuildNumber()
+	} else {
+		buildID, err = strconv.Atoi(jd.RunID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -155,9 +210,53 @@ func GetCombinedResultsForBuild(build *prow.Build) ([]*junit.TestSuites, error)
 	return allSuites, nil
 }
 
-// getFlakyTests gets the current flaky tests from the repo JobData originated from
-func (jd *JobData) getFlakyTests() ([]string, error) {
-	return client.GetFlakyTests(flakesRecorderJobName, jd.Refs[0].Repo)
+// getFlakyTests gets the union of the current flaky tests from the repo JobData
+// originated from and from the given additional sources, recording the sources
+// reporting each test. Additional sources failing to be read are skipped, so that
+// a misconfigured source doesn't prevent all retries.
+func (jd *JobData) getFlakyTests(sources []config.FlakySource) ([]string, error) {
+	tests, err := client.GetFlakyTests(flakesRecorderJobName, jd.Refs[0].Repo)
+	if err != nil {
+		return nil, err
+	}
+	jd.flakySources = make(map[string][]string)
+	jd.addFlakyTests(flakySourceName(config.FlakySource{Repo: jd.Refs[0].Repo}), tests)
+	for _, source := range sources {
+		job := source.Job
+		if job == "" {
+			job = flakesRecorderJobName
+		}
+		if job == flakesRecorderJobName && source.Repo == jd.Refs[0].Repo {
+			continue
+		}
+		flaky, err := client.GetFlakyTests(job, source.Repo)
+		if err != nil {
+			logWithPrefix(jd, "failed getting flaky tests of %s, skipping: %v\n", flakySourceName(source), err)
+			continue
+		}
+		for _, test := range flaky {
+			if _, ok := jd.flakySources[test]; !ok {
+				tests = append(tests, test)
+			}
+		}
+		jd.addFlakyTests(flakySourceName(source), flaky)
+	}
+	return tests, nil
+}
+
+func (jd *JobData) addFlakyTests(source string, tests []string) {
+	for _, test := range tests {
+		jd.flakySources[test] = append(jd.flakySources[test], source)
+	}
+}
+
+// flakySourceName names the source in the format of "repo" for the default
+// reporter job, and "job/repo" otherwise
+func flakySourceName(source config.FlakySource) string {
+	if source.Job == "" || source.Job == flakesRecorderJobName {
+		return source.Repo
+	}
+	return fmt.Sprintf("%s/%s", source.Job, source.Repo)
 }
 
 // compareTests compares lists of failed and flaky tests, and returns any outlying failed
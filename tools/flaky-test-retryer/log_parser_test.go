@@ -17,11 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
+	"knative.dev/test-infra/tools/flaky-test-reporter/jsonreport"
 	"knative.dev/test-infra/tools/flaky-test-reporter/jsonreport/fakejsonreport"
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
 	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
 )
 
@@ -55,7 +58,7 @@ var (
 
 func setup() {
 	client, _ = fakejsonreport.Initialize("")
-	client.CreateReport(fakeRepo, fakeFlakyTests, true)
+	client.CreateReport(fakeRepo, fakeFlakyTests, nil, true)
 }
 
 func testIsSupported(t *testing.T) {
@@ -74,7 +77,7 @@ func testIsSupported(t *testing.T) {
 					Number: 111,
 				}},
 			}},
-		}, time.Now(), nil, nil}, false},
+		}, time.Now(), nil, nil, nil}, false},
 		{&JobData{&prowapi.ReportMessage{ // wrong job type
 			JobName: "fakejob",
 			JobType: prowapi.PeriodicJob,
@@ -86,13 +89,13 @@ func testIsSupported(t *testing.T) {
 					Number: 111,
 				}},
 			}},
-		}, time.Now(), nil, nil}, false},
+		}, time.Now(), nil, nil, nil}, false},
 		{&JobData{&prowapi.ReportMessage{ // no refs
 			JobName: "fakejob",
 			JobType: prowapi.PresubmitJob,
 			Status:  prowapi.FailureState,
 			Refs:    nil,
-		}, time.Now(), nil, nil}, false},
+		}, time.Now(), nil, nil, nil}, false},
 		{&JobData{&prowapi.ReportMessage{ // no pulls
 			JobName: "fakejob",
 			JobType: prowapi.PresubmitJob,
@@ -102,9 +105,9 @@ func testIsSupported(t *testing.T) {
 				Repo:  fakeRepo,
 				Pulls: nil,
 			}},
-		}, time.Now(), nil, nil}, false},
-		{&JobData{fakeInvalidRepo, time.Now(), nil, nil}, false}, // invalid repo
-		{&JobData{fakeValidMessage, time.Now(), nil, nil}, true}, // valid message
+		}, time.Now(), nil, nil, nil}, false},
+		{&JobData{fakeInvalidRepo, time.Now(), nil, nil, nil}, false}, // invalid repo
+		{&JobData{fakeValidMessage, time.Now(), nil, nil, nil}, true}, // valid message
 	}
 	setup()
 	for _, test := range cases {
@@ -121,12 +124,12 @@ func testGetFlakyTests(t *testing.T) {
 		wantArray []string
 		wantErr   error
 	}{
-		{&JobData{fakeValidMessage, time.Now(), nil, nil}, fakeFlakyTests, nil},
-		{&JobData{fakeInvalidRepo, time.Now(), nil, nil}, []string{}, nil},
+		{&JobData{fakeValidMessage, time.Now(), nil, nil, nil}, fakeFlakyTests, nil},
+		{&JobData{fakeInvalidRepo, time.Now(), nil, nil, nil}, []string{}, nil},
 	}
 	setup()
 	for _, test := range data {
-		gotArray, gotErr := test.job.getFlakyTests()
+		gotArray, gotErr := test.job.getFlakyTests(nil)
 		if !reflect.DeepEqual(gotArray, test.wantArray) {
 			t.Fatalf("Get Flaky Tests: got array %v, want array %v", gotArray, test.wantArray)
 		}
@@ -139,6 +142,58 @@ func testGetFlakyTests(t *testing.T) {
 	}
 }
 
+// sourcesClient fakes the jsonreport client with flaky tests keyed by "job/repo"
+type sourcesClient struct {
+	jsonreport.Client
+	flaky map[string][]string
+}
+
+func (c *sourcesClient) GetFlakyTests(jobName, repo string) ([]string, error) {
+	tests, ok := c.flaky[jobName+"/"+repo]
+	if !ok {
+		return nil, fmt.Errorf("no report for %s/%s", jobName, repo)
+	}
+	return tests, nil
+}
+
+func TestGetFlakyTestsFromSources(t *testing.T) {
+	oldClient := client
+	defer func() { client = oldClient }()
+	client = &sourcesClient{flaky: map[string][]string{
+		flakesRecorderJobName + "/" + fakeRepo: {"test0", "test1"},
+		flakesRecorderJobName + "/pkg":         {"test1", "test2"},
+		"pkg-reporter/pkg":                     {"test3"},
+	}}
+
+	jd := &JobData{ReportMessage: fakeValidMessage}
+	got, err := jd.getFlakyTests([]config.FlakySource{
+		{Repo: "pkg"},
+		{Job: "pkg-reporter", Repo: "pkg"},
+		{Repo: "missing"}, // skipped
+		{Repo: fakeRepo},  // own repo is only read once
+	})
+	if err != nil {
+		t.Fatalf("getFlakyTests() failed: %v", err)
+	}
+	if want := []string{"test0", "test1", "test2", "test3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("getFlakyTests() = %v, want %v", got, want)
+	}
+	wantSources := map[string][]string{
+		"test0": {fakeRepo},
+		"test1": {fakeRepo, "pkg"},
+		"test2": {"pkg"},
+		"test3": {"pkg-reporter/pkg"},
+	}
+	if !reflect.DeepEqual(jd.flakySources, wantSources) {
+		t.Errorf("got flaky sources %v, want %v", jd.flakySources, wantSources)
+	}
+
+	invalid := &JobData{ReportMessage: fakeInvalidRepo}
+	if _, err := invalid.getFlakyTests(nil); err == nil {
+		t.Error("getFlakyTests() should fail without a report of the job's own repo")
+	}
+}
+
 func testGetNonFlakyTests(t *testing.T) {
 	cases := []struct {
 		failed, flaky, want []string
@@ -22,8 +22,13 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"strings"
 )
 
 const (
@@ -34,6 +39,17 @@ type EnvFlags struct {
 	ServiceAccount string // GCP service account file path
 	GithubAccount  string // github account file path
 	Dryrun         bool   // dry run toggle
+	Pubsub         bool   // whether to receive messages from Pubsub
+	HTTPAddress    string // address to receive messages over HTTP, disabled if empty
+	MetricsAddress string // address to serve metrics on, disabled if empty
+	WebhookSecret  string // file path of the secret validating messages received over HTTP
+	StateFile      string // file persisting decisions made for job runs
+	PolicyConfig   string // retry policy config file path
+	MaxWorkers     int    // maximum number of jobs handled concurrently
+	Trigger        string // how failed jobs are rerun
+	Kubeconfig     string // kubeconfig of the Prow cluster for rerunning jobs
+	ProwJobNS      string // namespace of ProwJobs in the Prow cluster
+	AuditLog       string // audit log file path
 }
 
 func initFlags() *EnvFlags {
@@ -42,6 +58,17 @@ func initFlags() *EnvFlags {
 	flag.StringVar(&f.ServiceAccount, "service-account", defaultServiceAccount, "JSON key file for GCS service account")
 	flag.StringVar(&f.GithubAccount, "github-account", "", "Token file for Github authentication")
 	flag.BoolVar(&f.Dryrun, "dry-run", false, "dry run switch")
+	flag.BoolVar(&f.Pubsub, "pubsub", true, "receive Prow report messages from Pubsub")
+	flag.StringVar(&f.HTTPAddress, "http-address", "", "address for receiving Prow report messages and GitHub webhooks over HTTP, e.g. ':8080'. Disabled if empty")
+	flag.StringVar(&f.MetricsAddress, "metrics-address", "", "address for serving metrics and the summary of decisions over HTTP, e.g. ':9090'. Disabled if empty")
+	flag.StringVar(&f.WebhookSecret, "github-webhook-secret", "", "file containing the secret for validating GitHub webhooks and report messages received over HTTP, required with --http-address")
+	flag.StringVar(&f.StateFile, "state-file", "", "JSON file persisting decisions made for job runs, so that duplicate messages are ignored across restarts. Decisions are only kept in memory if empty")
+	flag.StringVar(&f.PolicyConfig, "policy-config", "", "YAML file configuring retry policies per org, repo and job. Default policy is used for all jobs if empty")
+	flag.IntVar(&f.MaxWorkers, "max-workers", 10, "maximum number of jobs handled concurrently")
+	flag.StringVar(&f.Trigger, "trigger", commentTriggerName, fmt.Sprintf("how failed jobs are rerun, %q posts a /test command in the retry comment, %q reruns the failed ProwJob by creating a new ProwJob in the Prow cluster", commentTriggerName, prowTriggerName))
+	flag.StringVar(&f.Kubeconfig, "kubeconfig", "", "kubeconfig of the Prow cluster, only used by the prow trigger. Uses the in-cluster config if empty")
+	flag.StringVar(&f.ProwJobNS, "prowjob-namespace", "default", "namespace of ProwJobs in the Prow cluster, only used by the prow trigger")
+	flag.StringVar(&f.AuditLog, "audit-log", "", "file appended with a JSON line for every decision and retry result. Audit log is not written if empty")
 	flag.Parse()
 	return &f
 }
@@ -49,14 +76,68 @@ func initFlags() *EnvFlags {
 func main() {
 	flags := initFlags()
 
-	handler, err := NewHandlerClient(flags.ServiceAccount, flags.GithubAccount, flags.Dryrun)
+	if !flags.Pubsub && flags.HTTPAddress == "" {
+		log.Fatal("Nothing to listen to, either --pubsub or --http-address is required")
+	}
+	if flags.HTTPAddress != "" && flags.WebhookSecret == "" {
+		log.Fatal("--github-webhook-secret is required with --http-address")
+	}
+	if err := run(flags); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run listens for messages until one of the HTTP servers fails, the audit log
+// is closed before returning
+func run(flags *EnvFlags) error {
+	trigger, err := NewTrigger(flags.Trigger, flags.Kubeconfig, flags.ProwJobNS, flags.Dryrun)
 	if err != nil {
-		log.Fatalf("Coud not create handler: '%v'", err)
+		return fmt.Errorf("could not create trigger: '%v'", err)
+	}
+
+	var auditLog io.Writer
+	if flags.AuditLog != "" {
+		f, err := os.OpenFile(flags.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open audit log: '%v'", err)
+		}
+		defer f.Close()
+		auditLog = f
+	}
+	audit := NewAuditor(auditLog)
+
+	handler, err := NewHandlerClient(flags.ServiceAccount, flags.GithubAccount, flags.StateFile, flags.PolicyConfig, trigger, audit, flags.MaxWorkers, flags.Pubsub, flags.Dryrun)
+	if err != nil {
+		return fmt.Errorf("could not create handler: '%v'", err)
 	}
 
 	if flags.Dryrun {
 		log.Println("running in [dry run] mode")
 	}
 
-	handler.Listen()
+	errs := make(chan error, 2)
+	if flags.HTTPAddress != "" {
+		secret, err := ioutil.ReadFile(flags.WebhookSecret)
+		if err != nil {
+			return fmt.Errorf("could not read GitHub webhook secret: '%v'", err)
+		}
+		server, err := newWebhookServer(handler.HandleMessage, []byte(strings.TrimSpace(string(secret))))
+		if err != nil {
+			return fmt.Errorf("could not create HTTP server: '%v'", err)
+		}
+		log.Printf("Listening for HTTP requests on %q", flags.HTTPAddress)
+		go func() {
+			errs <- fmt.Errorf("HTTP server failed: '%v'", http.ListenAndServe(flags.HTTPAddress, server))
+		}()
+	}
+	if flags.MetricsAddress != "" {
+		log.Printf("Serving metrics on %q", flags.MetricsAddress)
+		go func() {
+			errs <- fmt.Errorf("metrics server failed: '%v'", http.ListenAndServe(flags.MetricsAddress, audit.newMetricsServer()))
+		}()
+	}
+	if flags.Pubsub {
+		go handler.Listen()
+	}
+	return <-errs
 }
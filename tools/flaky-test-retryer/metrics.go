@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// metrics.go contains counters exported in Prometheus text format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const metricsPrefix = "flaky_test_retryer_"
+
+// counter is a Prometheus counter with labels, it's safe for concurrent use
+type counter struct {
+	name   string
+	help   string
+	labels []string
+	mutex  sync.Mutex
+	values map[string]float64 // keyed by label values joined with "\x00"
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{
+		name:   metricsPrefix + name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+}
+
+// inc increments the counter with the given label values, which must match
+// the counter's labels in order
+func (c *counter) inc(labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[strings.Join(labelValues, "\x00")]++
+}
+
+// get returns the value of the counter with the given label values
+func (c *counter) get(labelValues ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[strings.Join(labelValues, "\x00")]
+}
+
+// write writes the counter in Prometheus text format, sorted by label values
+func (c *counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	var keys []string
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var pairs []string
+		if len(c.labels) > 0 {
+			for i, v := range strings.Split(key, "\x00") {
+				pairs = append(pairs, fmt.Sprintf("%s=%q", c.labels[i], v))
+			}
+		}
+		labels := ""
+		if len(pairs) > 0 {
+			labels = "{" + strings.Join(pairs, ",") + "}"
+		}
+		fmt.Fprintf(w, "%s%s %v\n", c.name, labels, c.values[key])
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// policy.go evaluates the retry policy of a job against its failed tests and
+// its pull request.
+
+package main
+
+import (
+	"time"
+
+	"github.com/google/go-github/v27/github"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
+)
+
+// optOutLabel returns the first label on the PR opting out of retries, or an
+// empty string if the PR didn't opt out
+func optOutLabel(p config.Policy, pull *github.PullRequest) string {
+	for _, label := range pull.Labels {
+		for _, optOut := range p.OptOutLabels {
+			if label.GetName() == optOut {
+				return optOut
+			}
+		}
+	}
+	return ""
+}
+
+// getBlockingTests returns the failed tests preventing a retry. Failed tests
+// matching the policy's flaky test patterns are treated as flaky, and non-flaky
+// tests are tolerated as long as they don't exceed the policy's fraction of
+// all failed tests.
+func getBlockingTests(p config.Policy, failedTests, flakyTests []string) []string {
+	flaky := append([]string{}, flakyTests...)
+	for _, test := range failedTests {
+		for _, pattern := range p.FlakyTestPatterns {
+			if pattern.MatchString(test) {
+				flaky = append(flaky, test)
+				break
+			}
+		}
+	}
+	outliers := getNonFlakyTests(failedTests, flaky)
+	if len(outliers) > 0 && float64(len(outliers)) <= p.MaxNonFlakyFraction*float64(len(failedTests)) {
+		return nil
+	}
+	return outliers
+}
+
+// retryDelay returns how long to wait before retrying a job that failed at
+// failedAt and was already retried the given times. The policy's backoff
+// doubles after each retry, up to config.MaxBackoff.
+func retryDelay(p config.Policy, retries int, failedAt, now time.Time) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	delay := p.Backoff
+	for i := 0; i < retries && delay < config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > config.MaxBackoff {
+		delay = config.MaxBackoff
+	}
+	if elapsed := now.Sub(failedAt); elapsed < delay {
+		return delay - elapsed
+	}
+	return 0
+}
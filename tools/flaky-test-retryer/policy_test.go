@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/config"
+)
+
+func TestOptOutLabel(t *testing.T) {
+	p := config.Policy{OptOutLabels: []string{"no-retry", "do-not-merge/hold"}}
+	label := func(name string) *github.Label { return &github.Label{Name: &name} }
+	cases := []struct {
+		name   string
+		labels []*github.Label
+		want   string
+	}{
+		{"no label", nil, ""},
+		{"other labels", []*github.Label{label("size/L")}, ""},
+		{"opted out", []*github.Label{label("size/L"), label("do-not-merge/hold")}, "do-not-merge/hold"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := optOutLabel(p, &github.PullRequest{Labels: tt.labels}); got != tt.want {
+				t.Errorf("optOutLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBlockingTests(t *testing.T) {
+	failed := []string{"e2e.TestA", "e2e.TestB", "unit.TestC", "unit.TestD"}
+	flaky := []string{"e2e.TestA"}
+	cases := []struct {
+		name   string
+		policy config.Policy
+		want   []string
+	}{
+		{"all or nothing", config.Policy{}, []string{"e2e.TestB", "unit.TestC", "unit.TestD"}},
+		{"flaky pattern", config.Policy{FlakyTestPatterns: []*regexp.Regexp{regexp.MustCompile(`^e2e\.`)}}, []string{"unit.TestC", "unit.TestD"}},
+		{"fraction exceeded", config.Policy{MaxNonFlakyFraction: 0.5}, []string{"e2e.TestB", "unit.TestC", "unit.TestD"}},
+		{"fraction tolerated", config.Policy{MaxNonFlakyFraction: 0.75}, nil},
+		{"pattern and fraction", config.Policy{
+			FlakyTestPatterns:   []*regexp.Regexp{regexp.MustCompile(`^e2e\.`)},
+			MaxNonFlakyFraction: 0.5,
+		}, nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getBlockingTests(tt.policy, failed, flaky); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getBlockingTests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	failedAt := time.Date(2020, 8, 3, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		backoff time.Duration
+		retries int
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{"no backoff", 0, 1, 0, 0},
+		{"first retry", time.Minute, 0, 20 * time.Second, 40 * time.Second},
+		{"doubled after retries", time.Minute, 2, time.Minute, 3 * time.Minute},
+		{"already elapsed", time.Minute, 1, 5 * time.Minute, 0},
+		{"capped at max backoff", 10 * time.Minute, 3, 0, config.MaxBackoff},
+		{"max retries of max backoff", config.MaxBackoff, 8, time.Minute, config.MaxBackoff - time.Minute},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := config.Policy{Backoff: tt.backoff}
+			if got := retryDelay(p, tt.retries, failedAt, failedAt.Add(tt.elapsed)); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
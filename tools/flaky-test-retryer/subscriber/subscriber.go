@@ -67,6 +67,36 @@ func (c *Client) ReceiveMessageAckAll(ctx context.Context, f func(*prowapi.Repor
 	})
 }
 
+// ReceiveMessage converts incoming pubsub messages to ReportMessage and executes `f` on them.
+// A message is only acknowledged after `f` succeeded, it's nacked if `f` returns an error so
+// that it can be redelivered. Messages that cannot be converted to ReportMessage are acked
+// and ignored, as redelivering them won't help.
+func (c *Client) ReceiveMessage(ctx context.Context, f func(*prowapi.ReportMessage, time.Time) error) error {
+	return c.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if c.process(msg, f) {
+			msg.Ack()
+			log.Printf("Message acked: %q", msg.ID)
+		} else {
+			msg.Nack()
+			log.Printf("Message nacked: %q", msg.ID)
+		}
+	})
+}
+
+// process executes `f` on the pubsub message, and returns whether the message should be acked
+func (c *Client) process(msg *pubsub.Message, f func(*prowapi.ReportMessage, time.Time) error) bool {
+	rmsg, err := c.toReportMessage(msg)
+	if err != nil {
+		log.Printf("Cannot convert pubsub message (%v) to Report message %v", msg, err)
+		return true
+	}
+	if err := f(rmsg, msg.PublishTime); err != nil {
+		log.Printf("Failed processing message %q: %v", msg.ID, err)
+		return false
+	}
+	return true
+}
+
 func (c *Client) toReportMessage(msg *pubsub.Message) (*prowapi.ReportMessage, error) {
 	rmsg := &prowapi.ReportMessage{}
 	if err := json.Unmarshal(msg.Data, rmsg); err != nil {
@@ -87,6 +87,37 @@ func TestSubscriberClient_ReceiveMessageAckAll(t *testing.T) {
 	}
 }
 
+func TestProcess(t *testing.T) {
+	validData := []byte(`{"runid":"1234","status":"failure","job_type":"presubmit","job_name":"fakejob"}`)
+	tests := []struct {
+		name      string
+		data      []byte
+		err       error
+		wantAck   bool
+		wantCalls int
+	}{
+		{"Processed", validData, nil, true, 1},
+		{"Processing failed", validData, errors.New("transient error"), false, 1},
+		{"Invalid Report", []byte(`Random Weird Format`), nil, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := getFakeSubscriber("fake subscriber")
+			calls := 0
+			f := func(message *prowapi.ReportMessage, timestamp time.Time) error {
+				calls++
+				return tt.err
+			}
+			if got := fs.process(&pubsub.Message{Data: tt.data}, f); got != tt.wantAck {
+				t.Errorf("process() acked = %v, want %v", got, tt.wantAck)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("process() called f %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
 func TestToReportMessage(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// trigger.go contains the backends rerunning failed jobs, either through a
+// /test command in the retry comment, or by creating a ProwJob rerunning only
+// the specific failed run, the same as Prow's rerun button.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	commentTriggerName = "comment"
+	prowTriggerName    = "prow"
+
+	prowJobFile = "prowjob.json"
+
+	// buildIDLabel is set by Prow on the ProwJob of a run, reruns get their own
+	buildIDLabel = "prow.k8s.io/build-id"
+	// idLabel is set to the name of the ProwJob
+	idLabel = "prow.k8s.io/id"
+)
+
+// prowJobsResource is the resource of ProwJobs in the Kubernetes API
+var prowJobsResource = schema.GroupVersionResource{Group: "prow.k8s.io", Version: "v1", Resource: "prowjobs"}
+
+// Trigger reruns failed jobs
+type Trigger interface {
+	// Rerun reruns the failed job, it's called after the retry is recorded in the
+	// retry comment or the rerun marker
+	Rerun(jd *JobData) error
+	// RetryMessage is the message of the retry comment for the job
+	RetryMessage(job string) string
+}
+
+// NewTrigger creates the trigger with the given name. kubeconfig and namespace
+// are only used by the prow trigger, an empty kubeconfig uses the in-cluster
+// config.
+func NewTrigger(name, kubeconfig, namespace string, dryrun bool) (Trigger, error) {
+	switch name {
+	case commentTriggerName:
+		return &commentTrigger{}, nil
+	case prowTriggerName:
+		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading Kubernetes config: %v", err)
+		}
+		client, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating Kubernetes client: %v", err)
+		}
+		return &prowTrigger{
+			prowJobs:    client.Resource(prowJobsResource).Namespace(namespace),
+			readProwJob: readProwJob,
+			dryrun:      dryrun,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown trigger %q, must be one of %q, %q", name, commentTriggerName, prowTriggerName)
+	}
+}
+
+// commentTrigger relies on Prow's trigger plugin, rerunning the job with a
+// /test command in the retry comment
+type commentTrigger struct{}
+
+func (ct *commentTrigger) Rerun(jd *JobData) error {
+	return nil
+}
+
+func (ct *commentTrigger) RetryMessage(job string) string {
+	return fmt.Sprintf("Automatically retrying due to test flakiness...\n/test %s", job)
+}
+
+// prowTrigger reruns the specific failed run by creating a new ProwJob with the
+// spec of the failed one, which Prow then runs, the retry comment is
+// informational only
+type prowTrigger struct {
+	prowJobs    dynamic.ResourceInterface // ProwJobs in the namespace Prow runs jobs from
+	readProwJob func(jd *JobData) ([]byte, error)
+	dryrun      bool
+}
+
+func (pt *prowTrigger) Rerun(jd *JobData) error {
+	contents, err := pt.readProwJob(jd)
+	if err != nil {
+		return fmt.Errorf("failed reading %s: %v", prowJobFile, err)
+	}
+	failed := &unstructured.Unstructured{}
+	if err := json.Unmarshal(contents, &failed.Object); err != nil {
+		return fmt.Errorf("failed parsing %s: %v", prowJobFile, err)
+	}
+	if failed.GetName() == "" {
+		return fmt.Errorf("%s has no ProwJob name", prowJobFile)
+	}
+	rerun, err := newRerunProwJob(failed, jd.RunID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed creating rerun of ProwJob %q: %v", failed.GetName(), err)
+	}
+	if pt.dryrun {
+		logWithPrefix(jd, "[dry run] ProwJob %q not rerun, would have created ProwJob %q\n", failed.GetName(), rerun.GetName())
+		return nil
+	}
+	if _, err := pt.prowJobs.Create(context.Background(), rerun, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed rerunning ProwJob %q: %v", failed.GetName(), err)
+	}
+	logWithPrefix(jd, "ProwJob %q rerun as %q\n", failed.GetName(), rerun.GetName())
+	return nil
+}
+
+func (pt *prowTrigger) RetryMessage(job string) string {
+	return fmt.Sprintf("Automatically retrying due to test flakiness, %s was rerun.", job)
+}
+
+// newRerunProwJob returns a new triggered ProwJob with the spec, labels and
+// annotations of the failed ProwJob, the same as Prow's rerun. The rerun is
+// annotated with the build ID of the failed run.
+func newRerunProwJob(failed *unstructured.Unstructured, failedRun string, now time.Time) (*unstructured.Unstructured, error) {
+	spec, ok, err := unstructured.NestedMap(failed.Object, "spec")
+	if err != nil || !ok {
+		return nil, fmt.Errorf("ProwJob has no valid spec: %v", err)
+	}
+	name := uuid.New().String()
+	labels := failed.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	delete(labels, buildIDLabel)
+	labels[idLabel] = name
+	annotations := failed.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[rerunOfAnnotation] = failedRun
+
+	rerun := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": spec,
+		"status": map[string]interface{}{
+			"state":     "triggered",
+			"startTime": now.UTC().Format(time.RFC3339),
+		},
+	}}
+	rerun.SetAPIVersion(prowJobsResource.GroupVersion().String())
+	rerun.SetKind("ProwJob")
+	rerun.SetName(name)
+	rerun.SetNamespace(failed.GetNamespace())
+	rerun.SetLabels(labels)
+	rerun.SetAnnotations(annotations)
+	return rerun, nil
+}
+
+// readProwJob reads the ProwJob of the failed run from GCS
+func readProwJob(jd *JobData) ([]byte, error) {
+	buildID, err := strconv.Atoi(jd.RunID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid run ID %q: %v", jd.RunID, err)
+	}
+	return jd.prowJob().NewBuild(buildID).ReadFile(prowJobFile)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const fakeKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: fake
+  context:
+    cluster: fake
+current-context: fake
+`
+
+const fakeProwJob = `{
+	"apiVersion": "prow.k8s.io/v1",
+	"kind": "ProwJob",
+	"metadata": {
+		"name": "fake-uuid",
+		"namespace": "default",
+		"labels": {"prow.k8s.io/job": "fakejob", "prow.k8s.io/build-id": "1234", "prow.k8s.io/id": "fake-uuid"},
+		"annotations": {"prow.k8s.io/job": "fakejob"}
+	},
+	"spec": {"type": "periodic", "job": "fakejob"},
+	"status": {"state": "failure", "build_id": "1234"}
+}`
+
+func TestNewTrigger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("failed creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	kubeconfig := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(kubeconfig, []byte(fakeKubeconfig), 0644); err != nil {
+		t.Fatalf("failed writing kubeconfig: %v", err)
+	}
+
+	for _, name := range []string{commentTriggerName, prowTriggerName} {
+		if _, err := NewTrigger(name, kubeconfig, "default", false); err != nil {
+			t.Errorf("NewTrigger(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := NewTrigger(prowTriggerName, filepath.Join(dir, "missing"), "default", false); err == nil {
+		t.Error("NewTrigger(\"prow\") with missing kubeconfig should fail")
+	}
+	if _, err := NewTrigger("unknown", "", "", false); err == nil {
+		t.Error("NewTrigger(\"unknown\") should fail")
+	}
+}
+
+func TestProwTriggerRerun(t *testing.T) {
+	cases := []struct {
+		name       string
+		prowJob    string
+		readErr    error
+		createErr  error
+		dryrun     bool
+		wantErr    string
+		wantCreate bool
+	}{
+		{"rerun", fakeProwJob, nil, nil, false, "", true},
+		{"dry run", fakeProwJob, nil, nil, true, "", false},
+		{"create rejected", fakeProwJob, nil, errors.New("forbidden"), false, "forbidden", true},
+		{"missing prowjob.json", "", errors.New("object doesn't exist"), nil, false, "failed reading prowjob.json", false},
+		{"missing name", `{"metadata": {}}`, nil, nil, false, "has no ProwJob name", false},
+		{"missing spec", `{"metadata": {"name": "fake-uuid"}}`, nil, nil, false, "no valid spec", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			if tt.createErr != nil {
+				client.PrependReactor("create", "prowjobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, tt.createErr
+				})
+			}
+			pt := &prowTrigger{
+				prowJobs: client.Resource(prowJobsResource).Namespace("default"),
+				readProwJob: func(jd *JobData) ([]byte, error) {
+					return []byte(tt.prowJob), tt.readErr
+				},
+				dryrun: tt.dryrun,
+			}
+			err := pt.Rerun(&fakeJob)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Rerun() failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Rerun() error = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			var created []*unstructured.Unstructured
+			for _, action := range client.Actions() {
+				if create, ok := action.(k8stesting.CreateAction); ok {
+					created = append(created, create.GetObject().(*unstructured.Unstructured))
+				}
+			}
+			if !tt.wantCreate {
+				if len(created) != 0 {
+					t.Errorf("got %d ProwJobs created, want none", len(created))
+				}
+				return
+			}
+			if len(created) != 1 {
+				t.Fatalf("got %d ProwJobs created, want 1", len(created))
+			}
+			if created[0].GetNamespace() != "default" {
+				t.Errorf("rerun created in namespace %q, want \"default\"", created[0].GetNamespace())
+			}
+		})
+	}
+}
+
+func TestNewRerunProwJob(t *testing.T) {
+	failed := &unstructured.Unstructured{}
+	if err := failed.UnmarshalJSON([]byte(fakeProwJob)); err != nil {
+		t.Fatalf("failed parsing ProwJob: %v", err)
+	}
+	now := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+	rerun, err := newRerunProwJob(failed, "1234", now)
+	if err != nil {
+		t.Fatalf("newRerunProwJob() failed: %v", err)
+	}
+
+	if rerun.GetName() == "" || rerun.GetName() == failed.GetName() {
+		t.Errorf("rerun should have a new name, got %q", rerun.GetName())
+	}
+	if rerun.GetKind() != "ProwJob" || rerun.GetAPIVersion() != "prow.k8s.io/v1" {
+		t.Errorf("got kind %q %q, want ProwJob prow.k8s.io/v1", rerun.GetAPIVersion(), rerun.GetKind())
+	}
+	labels := rerun.GetLabels()
+	if _, ok := labels[buildIDLabel]; ok {
+		t.Errorf("rerun should not keep the build ID label, got labels %v", labels)
+	}
+	if labels[idLabel] != rerun.GetName() {
+		t.Errorf("got %s label %q, want %q", idLabel, labels[idLabel], rerun.GetName())
+	}
+	if labels["prow.k8s.io/job"] != "fakejob" {
+		t.Errorf("rerun should keep the job label, got labels %v", labels)
+	}
+	annotations := rerun.GetAnnotations()
+	if annotations[rerunOfAnnotation] != "1234" || annotations["prow.k8s.io/job"] != "fakejob" {
+		t.Errorf("rerun should keep the annotations and be annotated with the failed run, got %v", annotations)
+	}
+	if job, _, _ := unstructured.NestedString(rerun.Object, "spec", "job"); job != "fakejob" {
+		t.Errorf("got spec.job %q, want \"fakejob\"", job)
+	}
+	if state, _, _ := unstructured.NestedString(rerun.Object, "status", "state"); state != "triggered" {
+		t.Errorf("got status.state %q, want \"triggered\"", state)
+	}
+	if start, _, _ := unstructured.NestedString(rerun.Object, "status", "startTime"); start != "2020-08-01T12:00:00Z" {
+		t.Errorf("got status.startTime %q, want \"2020-08-01T12:00:00Z\"", start)
+	}
+	if _, ok, _ := unstructured.NestedString(rerun.Object, "status", "build_id"); ok {
+		t.Error("rerun should not keep the status of the failed ProwJob")
+	}
+	// the failed ProwJob must not be modified
+	if _, ok := failed.GetAnnotations()[rerunOfAnnotation]; ok || failed.GetLabels()[buildIDLabel] != "1234" {
+		t.Errorf("failed ProwJob modified: %v", failed.Object["metadata"])
+	}
+}
+
+func TestRetryMessage(t *testing.T) {
+	if got := (&commentTrigger{}).RetryMessage("fakejob"); !strings.Contains(got, "/test fakejob") {
+		t.Errorf("comment trigger should retry with /test command, got %q", got)
+	}
+	if got := (&prowTrigger{}).RetryMessage("fakejob"); strings.Contains(got, "/test") {
+		t.Errorf("prow trigger should not retry with /test command, got %q", got)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// webhook.go accepts Prow report messages and GitHub status webhooks over HTTP,
+// as an alternative to Pub/Sub. Both are authenticated with an HMAC signature
+// of the payload, the same as GitHub webhooks. Messages received here go through
+// the same processing as Pub/Sub messages, and requests only succeed once the
+// message was handled, or its job run was deferred by a backoff and will be
+// handled again in the background.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v27/github"
+
+	// TODO: remove this import once "k8s.io/test-infra" import problems are fixed
+	// https://github.com/test-infra/test-infra/issues/912
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+const (
+	prowJobPath     = "/prowjob"
+	githubPath      = "/github"
+	statusEventType = "status"
+
+	// signatureHeader is the header of the HMAC signature of report messages,
+	// the same header as GitHub webhooks
+	signatureHeader = "X-Hub-Signature"
+)
+
+// rePresubmitURL matches the Prow URL of a presubmit run, capturing the GCS path,
+// "org_repo", pull request number, job name and run ID,
+// e.g. https://prow.knative.dev/view/gcs/knative-prow/pr-logs/pull/knative_serving/1234/pull-knative-serving-unit-tests/1290
+var rePresubmitURL = regexp.MustCompile(`/view/gcs/(.*/pr-logs/pull/([^/]+)/(\d+)/([^/]+)/(\d+))/?$`)
+
+// messageHandler processes a report message received at the given time, it
+// returns an error if the message should be delivered again
+type messageHandler func(msg *prowapi.ReportMessage, timestamp time.Time) error
+
+// webhookServer serves HTTP endpoints receiving messages
+type webhookServer struct {
+	handle messageHandler
+	secret []byte // secret for validating the signatures of GitHub webhooks and report messages
+}
+
+// newWebhookServer creates an HTTP handler with an endpoint for Prow report messages,
+// and an endpoint for GitHub status webhooks, both validated with secret.
+func newWebhookServer(handle messageHandler, secret []byte) (http.Handler, error) {
+	// GitHub skips validating signatures with an empty secret
+	if len(secret) == 0 {
+		return nil, errors.New("a secret is required for validating messages received over HTTP")
+	}
+	ws := &webhookServer{handle: handle, secret: secret}
+	mux := http.NewServeMux()
+	mux.HandleFunc(prowJobPath, ws.serveProwJob)
+	mux.HandleFunc(githubPath, ws.serveGithub)
+	return mux, nil
+}
+
+// serveProwJob accepts a prowapi.ReportMessage in JSON, the same payload as the Pub/Sub message,
+// signed with the secret in signatureHeader
+func (ws *webhookServer) serveProwJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed reading body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := github.ValidateSignature(r.Header.Get(signatureHeader), body, ws.secret); err != nil {
+		http.Error(w, fmt.Sprintf("invalid signature: %v", err), http.StatusUnauthorized)
+		return
+	}
+	msg := &prowapi.ReportMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		http.Error(w, fmt.Sprintf("cannot convert body to Report message: %v", err), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Report message received over HTTP for %q", msg.URL)
+	ws.respond(w, ws.handle(msg, time.Now()))
+}
+
+// serveGithub accepts GitHub webhooks, only status events are processed
+func (ws *webhookServer) serveGithub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := github.ValidatePayload(r, ws.secret)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	eventType := github.WebHookType(r)
+	if eventType != statusEventType {
+		// Not an error, GitHub sends events like ping that we are not interested in
+		log.Printf("Ignoring GitHub event %q", eventType)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("cannot parse GitHub event: %v", err), http.StatusBadRequest)
+		return
+	}
+	statusEvent := event.(*github.StatusEvent)
+	msg, err := statusEventToReportMessage(statusEvent)
+	if err != nil {
+		log.Printf("Ignoring GitHub status event: %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	log.Printf("GitHub status event received for %q", msg.URL)
+	timestamp := time.Now()
+	if statusEvent.CreatedAt != nil {
+		timestamp = statusEvent.GetCreatedAt().Time
+	}
+	ws.respond(w, ws.handle(msg, timestamp))
+}
+
+// respond reports the result of handling a message, a server error tells the
+// sender to deliver the message again, while deferred job runs are accepted
+func (ws *webhookServer) respond(w http.ResponseWriter, err error) {
+	var backoff *backoffError
+	if errors.As(err, &backoff) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed handling message: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// statusEventToReportMessage converts a GitHub status posted by Prow into a
+// ReportMessage. Job details are parsed from the target URL, as GitHub statuses
+// don't have the pull request number.
+func statusEventToReportMessage(se *github.StatusEvent) (*prowapi.ReportMessage, error) {
+	var state prowapi.ProwJobState
+	switch se.GetState() {
+	case "pending":
+		state = prowapi.PendingState
+	case "success":
+		state = prowapi.SuccessState
+	case "failure":
+		state = prowapi.FailureState
+	case "error":
+		state = prowapi.ErrorState
+	default:
+		return nil, fmt.Errorf("unknown state %q", se.GetState())
+	}
+	match := rePresubmitURL.FindStringSubmatch(se.GetTargetURL())
+	if match == nil {
+		return nil, fmt.Errorf("target URL %q is not a Prow presubmit run", se.GetTargetURL())
+	}
+	gcsPath, orgRepo, pullStr, jobName, runID := match[1], match[2], match[3], match[4], match[5]
+	org, repo := se.GetRepo().GetOwner().GetLogin(), se.GetRepo().GetName()
+	if orgRepo != org+"_"+repo {
+		return nil, fmt.Errorf("target URL %q doesn't match repository '%s/%s'", se.GetTargetURL(), org, repo)
+	}
+	pull, err := strconv.Atoi(pullStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request number %q: %v", pullStr, err)
+	}
+	return &prowapi.ReportMessage{
+		RunID:   runID,
+		Status:  state,
+		URL:     se.GetTargetURL(),
+		GCSPath: "gs://" + gcsPath,
+		Refs: []prowapi.Refs{{
+			Org:  org,
+			Repo: repo,
+			Pulls: []prowapi.Pull{{
+				Number: pull,
+				SHA:    se.GetSHA(),
+			}},
+		}},
+		JobType: prowapi.PresubmitJob,
+		JobName: jobName,
+	}, nil
+}
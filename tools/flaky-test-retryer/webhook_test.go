@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/test-infra/tools/flaky-test-retryer/prowapi"
+)
+
+const (
+	fakeSecret        = "fakesecret"
+	fakeMessage       = `{"job_name": "fakejob", "status": "failure", "job_type": "presubmit"}`
+	fakeTargetURL     = "https://prow.knative.dev/view/gcs/knative-prow/pr-logs/pull/fakeorg_fakerepo/111/fakejob/1234"
+	fakeStatusPayload = `{
+	"sha": "fakeSha",
+	"state": "failure",
+	"context": "fakejob",
+	"target_url": "` + fakeTargetURL + `",
+	"repository": {"name": "fakerepo", "owner": {"login": "fakeorg"}}
+}`
+)
+
+// recordingHandler records all messages passed to it, and returns err
+type recordingHandler struct {
+	messages []*prowapi.ReportMessage
+	err      error
+}
+
+func (rh *recordingHandler) handle(msg *prowapi.ReportMessage, timestamp time.Time) error {
+	rh.messages = append(rh.messages, msg)
+	return rh.err
+}
+
+func sign(payload, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestWebhookServer(t *testing.T, handle messageHandler) http.Handler {
+	server, err := newWebhookServer(handle, []byte(fakeSecret))
+	if err != nil {
+		t.Fatalf("newWebhookServer() failed: %v", err)
+	}
+	return server
+}
+
+// newProwJobRequest creates a request with a report message signed with secret
+func newProwJobRequest(method, body, secret string) *http.Request {
+	req := httptest.NewRequest(method, prowJobPath, strings.NewReader(body))
+	req.Header.Set(signatureHeader, sign(body, secret))
+	return req
+}
+
+func TestNewWebhookServer(t *testing.T) {
+	if _, err := newWebhookServer((&recordingHandler{}).handle, nil); err == nil {
+		t.Error("newWebhookServer() should fail without a secret")
+	}
+}
+
+func TestServeProwJob(t *testing.T) {
+	cases := []struct {
+		name       string
+		method     string
+		body       string
+		secret     string
+		handleErr  error
+		wantStatus int
+		wantJobs   []string
+	}{
+		{"valid message", http.MethodPost, fakeMessage, fakeSecret, nil, http.StatusOK, []string{"fakejob"}},
+		{"handling failed", http.MethodPost, fakeMessage, fakeSecret, errors.New("transient error"), http.StatusInternalServerError, []string{"fakejob"}},
+		{"invalid signature", http.MethodPost, fakeMessage, "wrong", nil, http.StatusUnauthorized, nil},
+		{"invalid json", http.MethodPost, `{"job_name": `, fakeSecret, nil, http.StatusBadRequest, nil},
+		{"wrong method", http.MethodGet, ``, fakeSecret, nil, http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &recordingHandler{err: tt.handleErr}
+			server := newTestWebhookServer(t, rh.handle)
+			req := newProwJobRequest(tt.method, tt.body, tt.secret)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var gotJobs []string
+			for _, msg := range rh.messages {
+				gotJobs = append(gotJobs, msg.JobName)
+			}
+			if !reflect.DeepEqual(gotJobs, tt.wantJobs) {
+				t.Errorf("got handled jobs %v, want %v", gotJobs, tt.wantJobs)
+			}
+		})
+	}
+}
+
+func TestServeProwJobBackoff(t *testing.T) {
+	rh := &recordingHandler{err: &backoffError{time.Minute}}
+	server := newTestWebhookServer(t, rh.handle)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, newProwJobRequest(http.MethodPost, fakeMessage, fakeSecret))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(rh.messages) != 1 {
+		t.Errorf("got %d handled messages, want 1", len(rh.messages))
+	}
+}
+
+func TestServeGithub(t *testing.T) {
+	secret := fakeSecret
+	cases := []struct {
+		name       string
+		event      string
+		payload    string
+		signature  string
+		wantStatus int
+		wantCount  int
+	}{
+		{"status event", "status", fakeStatusPayload, sign(fakeStatusPayload, secret), http.StatusOK, 1},
+		{"invalid signature", "status", fakeStatusPayload, sign(fakeStatusPayload, "wrong"), http.StatusBadRequest, 0},
+		{"ping event", "ping", `{"zen": "fake"}`, sign(`{"zen": "fake"}`, secret), http.StatusOK, 0},
+		{"not from Prow", "status", strings.Replace(fakeStatusPayload, fakeTargetURL, "https://example.com", 1),
+			sign(strings.Replace(fakeStatusPayload, fakeTargetURL, "https://example.com", 1), secret), http.StatusOK, 0},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			rh := &recordingHandler{}
+			server := newTestWebhookServer(t, rh.handle)
+			req := httptest.NewRequest(http.MethodPost, githubPath, strings.NewReader(tt.payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", tt.event)
+			req.Header.Set("X-Hub-Signature", tt.signature)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if len(rh.messages) != tt.wantCount {
+				t.Errorf("got %d handled messages, want %d", len(rh.messages), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestStatusEventToReportMessage(t *testing.T) {
+	rh := &recordingHandler{}
+	server := newTestWebhookServer(t, rh.handle)
+	req := httptest.NewRequest(http.MethodPost, githubPath, strings.NewReader(fakeStatusPayload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "status")
+	req.Header.Set("X-Hub-Signature", sign(fakeStatusPayload, fakeSecret))
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	if len(rh.messages) != 1 {
+		t.Fatalf("got %d handled messages, want 1", len(rh.messages))
+	}
+	want := &prowapi.ReportMessage{
+		RunID:   "1234",
+		Status:  prowapi.FailureState,
+		URL:     fakeTargetURL,
+		GCSPath: "gs://knative-prow/pr-logs/pull/fakeorg_fakerepo/111/fakejob/1234",
+		Refs: []prowapi.Refs{{
+			Org:  "fakeorg",
+			Repo: "fakerepo",
+			Pulls: []prowapi.Pull{{
+				Number: 111,
+				SHA:    "fakeSha",
+			}},
+		}},
+		JobType: prowapi.PresubmitJob,
+		JobName: "fakejob",
+	}
+	if !reflect.DeepEqual(rh.messages[0], want) {
+		t.Errorf("got message %+v, want %+v", rh.messages[0], want)
+	}
+}